@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/handlers"
+)
+
+// accessLogEntry is a request logged in the json access log format
+type accessLogEntry struct {
+	Time       string `json:"time"`
+	RemoteAddr string `json:"remote_addr"`
+	User       string `json:"user,omitempty"`
+	Method     string `json:"method"`
+	URI        string `json:"uri"`
+	Proto      string `json:"proto"`
+	Status     int    `json:"status"`
+	Size       int    `json:"size"`
+	Referer    string `json:"referer,omitempty"`
+	UserAgent  string `json:"user_agent,omitempty"`
+}
+
+// writeJSONAccessLog writes a request to w as a line of JSON
+func writeJSONAccessLog(w io.Writer, p handlers.LogFormatterParams) {
+	host, _, err := net.SplitHostPort(p.Request.RemoteAddr)
+	if err != nil {
+		host = p.Request.RemoteAddr
+	}
+	user := ""
+	if p.URL.User != nil {
+		user = p.URL.User.Username()
+	} else if u, _, ok := p.Request.BasicAuth(); ok {
+		user = u
+	}
+	json.NewEncoder(w).Encode(accessLogEntry{
+		Time:       p.TimeStamp.UTC().Format(time.RFC3339Nano),
+		RemoteAddr: host,
+		User:       user,
+		Method:     p.Request.Method,
+		URI:        p.Request.RequestURI,
+		Proto:      p.Request.Proto,
+		Status:     p.StatusCode,
+		Size:       p.Size,
+		Referer:    p.Request.Referer(),
+		UserAgent:  p.Request.UserAgent(),
+	})
+}
+
+// accessLog logs the requests served by h in our configured access log format
+func accessLog(h http.Handler) http.Handler {
+	switch cfg.Log.Access {
+	case "none":
+		return h
+	case "combined":
+		return handlers.CombinedLoggingHandler(logOutput, h)
+	case "json":
+		return handlers.CustomLoggingHandler(logOutput, h, writeJSONAccessLog)
+	default:
+		return handlers.LoggingHandler(logOutput, h)
+	}
+}
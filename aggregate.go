@@ -0,0 +1,162 @@
+package main
+
+import (
+	"math"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	// aggregates holds the hourly and daily aggregates for each station
+	aggregates = make(map[string]*aggregator)
+	// aggregateMetrics are the gauges exporting our aggregates
+	aggregateMetrics = make(MetricsMap)
+	// locations caches parsed station timezones
+	locations = make(map[string]*time.Location)
+)
+
+// aggregate is a running summary of the observations in a period
+type aggregate struct {
+	start      time.Time
+	count      int
+	tempSum    float64
+	tempMin    float64
+	tempMax    float64
+	precip     float64
+	precipSeen bool
+	gustMax    float64
+	gustSeen   bool
+}
+
+// add folds an observation into the aggregate, starting a new period if the
+// observation falls after the current one. Only the readings the observation
+// carries that are within their plausibility bounds are added.
+func (a *aggregate) add(o observation, start time.Time) {
+	if !start.Equal(a.start) {
+		*a = aggregate{
+			start:   start,
+			tempMin: math.Inf(1),
+			tempMax: math.Inf(-1),
+		}
+	}
+	v := o.values()
+	if t, ok := v["air_temperature"]; ok {
+		a.count++
+		a.tempSum += t
+		a.tempMin = math.Min(a.tempMin, t)
+		a.tempMax = math.Max(a.tempMax, t)
+	}
+	if p, ok := v["precip"]; ok {
+		a.precip += p
+		a.precipSeen = true
+	}
+	if g, ok := v["wind_gust"]; ok {
+		a.gustMax = math.Max(a.gustMax, g)
+		a.gustSeen = true
+	}
+}
+
+// values returns the aggregate's values keyed by metric name, leaving out
+// those no reading in the period has gone into
+func (a *aggregate) values() map[string]float64 {
+	v := make(map[string]float64)
+	if a.count > 0 {
+		v["air_temperature_avg"] = a.tempSum / float64(a.count)
+		v["air_temperature_min"] = a.tempMin
+		v["air_temperature_max"] = a.tempMax
+	}
+	if a.precipSeen {
+		v["precip_total"] = a.precip
+	}
+	if a.gustSeen {
+		v["wind_gust_max"] = a.gustMax
+	}
+	return v
+}
+
+// aggregator keeps the hourly and daily aggregates for a station
+type aggregator struct {
+	lastTimestamp float64
+	hour          aggregate
+	day           aggregate
+}
+
+// observe adds a new observation to the station's aggregates, returning false
+// if we've already seen it
+func (g *aggregator) observe(o observation, loc *time.Location) bool {
+	if o.Timestamp == g.lastTimestamp {
+		return false
+	}
+	g.lastTimestamp = o.Timestamp
+	t := time.Unix(int64(o.Timestamp), 0).In(loc)
+	g.hour.add(o, time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, loc))
+	g.day.add(o, time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc))
+	return true
+}
+
+// registerAggregates creates and registers the gauges for our aggregates
+func registerAggregates(labelNames []string) {
+	names := append(append([]string{}, labelNames...), "period")
+	help := map[string]string{
+		"air_temperature_avg": "Average air temperature over the current period",
+		"air_temperature_min": "Minimum air temperature over the current period",
+		"air_temperature_max": "Maximum air temperature over the current period",
+		"precip_total":        "Total precipitation over the current period",
+		"wind_gust_max":       "Maximum wind gust over the current period",
+	}
+	for name, h := range help {
+		aggregateMetrics[name] = prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: ns,
+				Subsystem: ss,
+				Name:      name,
+				Help:      h,
+			},
+			names,
+		)
+		weatherRegistry.MustRegister(aggregateMetrics[name])
+	}
+}
+
+// setAggregates adds a station's observation to its aggregates and updates our
+// aggregate gauges
+func setAggregates(stationID, timezone string, o observation, labels prometheus.Labels) {
+	g, ok := aggregates[stationID]
+	if !ok {
+		g = &aggregator{}
+		aggregates[stationID] = g
+	}
+	if !g.observe(o, location(timezone)) {
+		return
+	}
+	for period, a := range map[string]*aggregate{"hour": &g.hour, "day": &g.day} {
+		l := prometheus.Labels{"period": period}
+		for k, v := range labels {
+			l[k] = v
+		}
+		values := a.values()
+		for name, gauge := range aggregateMetrics {
+			// don't leave the previous period's value behind
+			if v, ok := values[name]; ok {
+				gauge.With(l).Set(v)
+			} else {
+				gauge.Delete(l)
+			}
+		}
+	}
+}
+
+// location returns the time.Location for a station's timezone, falling back
+// to UTC if it can't be loaded
+func location(timezone string) *time.Location {
+	if loc, ok := locations[timezone]; ok {
+		return loc
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	locations[timezone] = loc
+	return loc
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nalbury/tempest-exporter/pkg/weatherflow"
+)
+
+func TestAggregateAdd(t *testing.T) {
+	defer func(mode string) { cfg.BoundsMode = mode }(cfg.BoundsMode)
+	cfg.BoundsMode = "drop"
+	start := time.Date(2026, 10, 17, 5, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		obs  []observation
+		want map[string]float64
+	}{
+		{
+			name: "full observations",
+			obs: []observation{
+				{Observation: weatherflow.Observation{AirTemperature: 10, Precip: 0.5, WindGust: 4}},
+				{Observation: weatherflow.Observation{AirTemperature: 14, Precip: 0.25, WindGust: 6}},
+			},
+			want: map[string]float64{
+				"air_temperature_avg": 12,
+				"air_temperature_min": 10,
+				"air_temperature_max": 14,
+				"precip_total":        0.75,
+				"wind_gust_max":       6,
+			},
+		},
+		{
+			name: "readings missing from a partial observation are left out",
+			obs: []observation{
+				{Observation: weatherflow.Observation{AirTemperature: 10}, fields: map[string]bool{"air_temperature": true}},
+				{Observation: weatherflow.Observation{WindGust: 3}, fields: map[string]bool{"wind_gust": true}},
+			},
+			want: map[string]float64{
+				"air_temperature_avg": 10,
+				"air_temperature_min": 10,
+				"air_temperature_max": 10,
+				"wind_gust_max":       3,
+			},
+		},
+		{
+			name: "readings dropped by their bounds are left out",
+			obs: []observation{
+				{Observation: weatherflow.Observation{AirTemperature: 10, Precip: 1, WindGust: 2}},
+				{Observation: weatherflow.Observation{AirTemperature: 99, Precip: 500, WindGust: 300}},
+			},
+			want: map[string]float64{
+				"air_temperature_avg": 10,
+				"air_temperature_min": 10,
+				"air_temperature_max": 10,
+				"precip_total":        1,
+				"wind_gust_max":       2,
+			},
+		},
+		{
+			name: "nothing observed",
+			obs: []observation{
+				{Observation: weatherflow.Observation{}, fields: map[string]bool{"timestamp": true}},
+			},
+			want: map[string]float64{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var a aggregate
+			for _, o := range tt.obs {
+				o.applyBounds()
+				a.add(o, start)
+			}
+			got := a.values()
+			if len(got) != len(tt.want) {
+				t.Fatalf("values() = %v, want %v", got, tt.want)
+			}
+			for k, v := range tt.want {
+				if got[k] != v {
+					t.Errorf("values()[%s] = %v, want %v", k, got[k], v)
+				}
+			}
+		})
+	}
+}
+
+func TestAggregateNewPeriod(t *testing.T) {
+	var a aggregate
+	hour := time.Date(2026, 10, 17, 5, 0, 0, 0, time.UTC)
+	a.add(observation{Observation: weatherflow.Observation{AirTemperature: 20, Precip: 2}}, hour)
+	a.add(observation{Observation: weatherflow.Observation{AirTemperature: 10}, fields: map[string]bool{"air_temperature": true}}, hour.Add(time.Hour))
+	got := a.values()
+	if got["air_temperature_max"] != 10 {
+		t.Errorf("air_temperature_max = %v, want 10 after a new period starts", got["air_temperature_max"])
+	}
+	if _, ok := got["precip_total"]; ok {
+		t.Errorf("precip_total = %v, want none in a period without precipitation readings", got["precip_total"])
+	}
+}
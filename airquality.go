@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	// aqMetrics are the gauges exporting our air quality readings
+	aqMetrics = make(MetricsMap)
+	// aqClient is the http client used to read our air quality sensor, timing
+	// out so a sensor that stops answering doesn't stall our polls
+	aqClient = &http.Client{Timeout: 10 * time.Second}
+)
+
+// aqReading is a reading from an air quality sensor, in µg/m³
+type aqReading struct {
+	PM1  float64
+	PM25 float64
+	PM10 float64
+}
+
+// purpleAirResponse is the subset of the PurpleAir local /json API we use
+type purpleAirResponse struct {
+	PM1  float64 `json:"pm1_0_atm"`
+	PM25 float64 `json:"pm2_5_atm"`
+	PM10 float64 `json:"pm10_0_atm"`
+}
+
+// airGradientResponse is the subset of the AirGradient local
+// /measures/current API we use
+type airGradientResponse struct {
+	PM1  float64 `json:"pm01"`
+	PM25 float64 `json:"pm02"`
+	PM10 float64 `json:"pm10"`
+}
+
+// aqiBreakpoints are the US EPA PM2.5 AQI breakpoints as
+// {concentration low, concentration high, index low, index high}
+var aqiBreakpoints = [][4]float64{
+	{0, 9.0, 0, 50},
+	{9.1, 35.4, 51, 100},
+	{35.5, 55.4, 101, 150},
+	{55.5, 125.4, 151, 200},
+	{125.5, 225.4, 201, 300},
+	{225.5, 325.4, 301, 500},
+}
+
+// pm25AQI returns the US EPA air quality index for a PM2.5 concentration
+func pm25AQI(c float64) float64 {
+	c = math.Floor(c*10) / 10
+	for _, bp := range aqiBreakpoints {
+		if c <= bp[1] {
+			return math.Round((bp[3]-bp[2])/(bp[1]-bp[0])*(c-bp[0]) + bp[2])
+		}
+	}
+	return 500
+}
+
+// getAirQuality retrieves the current reading from the air quality sensor c
+// configures
+func getAirQuality(c airQualityConfig) (aqReading, error) {
+	var path string
+	var dst interface{}
+	var pa purpleAirResponse
+	var ag airGradientResponse
+	source := c.Source
+	switch source {
+	case "purpleair":
+		path, dst = "/json", &pa
+	case "airgradient":
+		path, dst = "/measures/current", &ag
+	default:
+		return aqReading{}, fmt.Errorf("unknown air quality source %q", source)
+	}
+	httpResp, err := aqClient.Get(strings.TrimSuffix(c.URL, "/") + path)
+	if err != nil {
+		return aqReading{}, fmt.Errorf("error getting data from %s sensor: %v", source, err)
+	}
+	defer httpResp.Body.Close()
+	if httpResp.StatusCode != http.StatusOK {
+		return aqReading{}, fmt.Errorf("error getting data from %s sensor: %s", source, httpResp.Status)
+	}
+	if err := json.NewDecoder(httpResp.Body).Decode(dst); err != nil {
+		return aqReading{}, fmt.Errorf("error parsing %s sensor response: %v", source, err)
+	}
+	if source == "purpleair" {
+		return aqReading(pa), nil
+	}
+	return aqReading(ag), nil
+}
+
+// registerAirQuality creates and registers our air quality gauges
+func registerAirQuality(labelNames []string) {
+	help := map[string]string{
+		"pm1_0":  "PM1.0 concentration in µg/m³",
+		"pm2_5":  "PM2.5 concentration in µg/m³",
+		"pm10_0": "PM10 concentration in µg/m³",
+		"aqi":    "US EPA air quality index derived from PM2.5",
+	}
+	for name, h := range help {
+		aqMetrics[name] = prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: ns,
+				Subsystem: "airquality",
+				Name:      name,
+				Help:      h,
+			},
+			labelNames,
+		)
+		weatherRegistry.MustRegister(aqMetrics[name])
+	}
+}
+
+// setAirQuality updates our air quality gauges from a sensor reading
+func setAirQuality(aq aqReading, labels prometheus.Labels) {
+	aqMetrics["pm1_0"].With(labels).Set(aq.PM1)
+	aqMetrics["pm2_5"].With(labels).Set(aq.PM25)
+	aqMetrics["pm10_0"].With(labels).Set(aq.PM10)
+	aqMetrics["aqi"].With(labels).Set(pm25AQI(aq.PM25))
+}
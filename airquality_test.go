@@ -0,0 +1,80 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestPM25AQI(t *testing.T) {
+	tests := []struct {
+		c, want float64
+	}{
+		{c: 0, want: 0},
+		{c: 9.0, want: 50},
+		{c: 9.05, want: 50},
+		{c: 35.4, want: 100},
+		{c: 55.5, want: 151},
+		{c: 500, want: 500},
+	}
+	for _, tt := range tests {
+		if got := pm25AQI(tt.c); got != tt.want {
+			t.Errorf("pm25AQI(%v) = %v, want %v", tt.c, got, tt.want)
+		}
+	}
+}
+
+func TestGetAirQuality(t *testing.T) {
+	tests := []struct {
+		name, source, path, body string
+		want                     aqReading
+	}{
+		{name: "purpleair", source: "purpleair", path: "/json", body: `{"pm1_0_atm":1.5,"pm2_5_atm":4,"pm10_0_atm":6.5}`, want: aqReading{PM1: 1.5, PM25: 4, PM10: 6.5}},
+		{name: "airgradient", source: "airgradient", path: "/measures/current", body: `{"pm01":2,"pm02":7.5,"pm10":9}`, want: aqReading{PM1: 2, PM25: 7.5, PM10: 9}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != tt.path {
+					http.NotFound(w, r)
+					return
+				}
+				w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+			got, err := getAirQuality(airQualityConfig{Source: tt.source, URL: srv.URL + "/"})
+			if err != nil {
+				t.Fatalf("getAirQuality() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("getAirQuality() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetAirQualityTimeout(t *testing.T) {
+	defer func(c *http.Client) { aqClient = c }(aqClient)
+	aqClient = &http.Client{Timeout: 100 * time.Millisecond}
+	// a sensor that accepts the request but never answers
+	done := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	defer srv.Close()
+	defer close(done)
+	errs := make(chan error, 1)
+	go func() {
+		_, err := getAirQuality(airQualityConfig{Source: "purpleair", URL: srv.URL})
+		errs <- err
+	}()
+	select {
+	case err := <-errs:
+		if err == nil {
+			t.Error("getAirQuality() error = nil, want a timeout")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("getAirQuality() didn't time out")
+	}
+}
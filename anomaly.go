@@ -0,0 +1,117 @@
+package main
+
+import (
+	"math"
+	"sort"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// anomalyMinDelta is the smallest jump away from the rolling median we treat
+// as anomalous for each metric we check, so flat series don't flag on noise
+var anomalyMinDelta = map[string]float64{
+	"air_temperature":      5,
+	"dew_point":            5,
+	"wet_bulb_temperature": 5,
+	"relative_humidity":    25,
+	"barometric_pressure":  5,
+	"sea_level_pressure":   5,
+	"station_pressure":     5,
+}
+
+var (
+	// anomalies holds our anomaly detector for each station
+	anomalies = make(map[string]*anomalyDetector)
+	// anomalyGauge flags metrics whose latest reading looks like a sensor fault
+	anomalyGauge *prometheus.GaugeVec
+)
+
+// anomalyDetector tracks recent readings for a station to spot sudden,
+// implausible jumps
+type anomalyDetector struct {
+	lastTimestamp float64
+	history       map[string][]float64
+}
+
+// newAnomalyGauge creates the anomaly flag gauge for the given station labels
+func newAnomalyGauge(labelNames []string) *prometheus.GaugeVec {
+	return prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: ns,
+			Subsystem: ss,
+			Name:      "anomaly",
+			Help:      "1 if the latest reading of metric jumped implausibly from its recent history",
+		},
+		append(append([]string{}, labelNames...), "metric"),
+	)
+}
+
+// observe checks a new observation against recent history, returning whether
+// each checked metric looks anomalous. Observations we've already seen return nil.
+func (d *anomalyDetector) observe(o observation) map[string]bool {
+	if d.history == nil {
+		d.history = make(map[string][]float64)
+	}
+	if o.Timestamp == d.lastTimestamp {
+		return nil
+	}
+	d.lastTimestamp = o.Timestamp
+
+	flags := make(map[string]bool)
+	values := o.values()
+	for name, minDelta := range anomalyMinDelta {
+		v, ok := values[name]
+		if !ok {
+			continue
+		}
+		h := d.history[name]
+		if len(h) >= 5 {
+			med := median(h)
+			dev := make([]float64, len(h))
+			for i, x := range h {
+				dev[i] = math.Abs(x - med)
+			}
+			// 1.4826 scales the MAD to a standard deviation for normal data
+			limit := math.Max(cfg.AnomalyThreshold*1.4826*median(dev), minDelta)
+			flags[name] = math.Abs(v-med) > limit
+		}
+		h = append(h, v)
+		if len(h) > cfg.AnomalyWindow {
+			h = h[len(h)-cfg.AnomalyWindow:]
+		}
+		d.history[name] = h
+	}
+	return flags
+}
+
+// setAnomalies runs a station's observation through its anomaly detector and
+// updates our anomaly gauges
+func setAnomalies(stationID string, o observation, labels prometheus.Labels) {
+	d, ok := anomalies[stationID]
+	if !ok {
+		d = &anomalyDetector{}
+		anomalies[stationID] = d
+	}
+	for name, flagged := range d.observe(o) {
+		l := prometheus.Labels{"metric": name}
+		for k, v := range labels {
+			l[k] = v
+		}
+		var v float64
+		if flagged {
+			v = 1
+		}
+		anomalyGauge.With(l).Set(v)
+	}
+}
+
+// median returns the median of xs without modifying it
+func median(xs []float64) float64 {
+	s := append([]float64{}, xs...)
+	sort.Float64s(s)
+	n := len(s)
+	if n%2 == 1 {
+		return s[n/2]
+	}
+	return (s[n/2-1] + s[n/2]) / 2
+}
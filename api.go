@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// apiPath is the path we serve our JSON API under
+const apiPath = "/api/v1/"
+
+// latestRecords holds the latest observation of each station as we send it
+// to sinks, for our JSON API
+var latestRecords = make(map[string]sinkRecord)
+
+// apiStation is a station we've had an observation from, as served by our
+// JSON API
+type apiStation struct {
+	StationID       string    `json:"station_id"`
+	StationName     string    `json:"station_name"`
+	PublicName      string    `json:"public_name,omitempty"`
+	Latitude        float64   `json:"latitude"`
+	Longitude       float64   `json:"longitude"`
+	Elevation       float64   `json:"elevation"`
+	Timezone        string    `json:"timezone,omitempty"`
+	LastObservation time.Time `json:"last_observation"`
+}
+
+// observationRecord returns the record of a station's observation that we
+// send to sinks and serve on our JSON API
+func observationRecord(stationID string, o observation, labels prometheus.Labels) sinkRecord {
+	return sinkRecord{
+		Kind:      "observation",
+		StationID: stationID,
+		Labels:    labels,
+		Values:    o.values(),
+		Time:      time.Unix(int64(o.Timestamp), 0),
+	}
+}
+
+// writeJSON writes v as the JSON body of a response
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// sortedStations returns the stations we've had an observation from, in order
+func sortedStations() []string {
+	ids := make([]string, 0, len(latestRecords))
+	for id := range latestRecords {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// observationHandler serves the latest observation of the station given by
+// the station query parameter, or of every station if it's not given, in the
+// units requested by the units query parameter or else our configured units
+func observationHandler(w http.ResponseWriter, req *http.Request) {
+	units, err := requestUnits(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	record := func(rec sinkRecord) sinkRecord {
+		if units == "imperial" {
+			return imperialRecord(rec)
+		}
+		return rec
+	}
+	metricsMu.RLock()
+	defer metricsMu.RUnlock()
+	if station := req.URL.Query().Get("station"); station != "" {
+		rec, ok := latestRecords[station]
+		if !ok {
+			http.Error(w, fmt.Sprintf("no observation from station %q", station), http.StatusNotFound)
+			return
+		}
+		writeJSON(w, record(rec))
+		return
+	}
+	recs := []sinkRecord{}
+	for _, id := range sortedStations() {
+		recs = append(recs, record(latestRecords[id]))
+	}
+	writeJSON(w, recs)
+}
+
+// stationsHandler serves the details of the stations we've had an
+// observation from. Their locations are always in degrees and meters, as the
+// WeatherFlow API reports them.
+func stationsHandler(w http.ResponseWriter, req *http.Request) {
+	metricsMu.RLock()
+	defer metricsMu.RUnlock()
+	list := []apiStation{}
+	for _, id := range sortedStations() {
+		r := stationResponses[id]
+		list = append(list, apiStation{
+			StationID:       id,
+			StationName:     r.StationName,
+			PublicName:      r.PublicName,
+			Latitude:        r.Latitude,
+			Longitude:       r.Longitude,
+			Elevation:       r.Elevation,
+			Timezone:        r.Timezone,
+			LastObservation: latestRecords[id].Time,
+		})
+	}
+	writeJSON(w, list)
+}
+
+// apiHandler serves our JSON API
+func apiHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(apiPath+"observation", observationHandler)
+	mux.HandleFunc(apiPath+"stations", stationsHandler)
+	return mux
+}
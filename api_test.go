@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestObservationHandlerUnits(t *testing.T) {
+	defer func(r map[string]sinkRecord) { latestRecords = r }(latestRecords)
+	defer func(u string) { cfg.Units = u }(cfg.Units)
+	cfg.Units = "metric"
+	latestRecords = map[string]sinkRecord{
+		"1": {Kind: "observation", StationID: "1", Values: map[string]float64{"air_temperature": 20, "wind_avg": 10, "relative_humidity": 50}},
+	}
+	tests := []struct {
+		query  string
+		status int
+		want   map[string]float64
+	}{
+		{query: "?station=1", status: http.StatusOK, want: map[string]float64{"air_temperature": 20, "wind_avg": 10, "relative_humidity": 50}},
+		{query: "?station=1&units=imperial", status: http.StatusOK, want: map[string]float64{"air_temperature_fahrenheit": 68, "wind_avg_mph": 22.36936, "relative_humidity": 50}},
+		{query: "?station=1&units=kelvin", status: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			observationHandler(w, httptest.NewRequest(http.MethodGet, apiPath+"observation"+tt.query, nil))
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d", w.Code, tt.status)
+			}
+			if tt.want == nil {
+				return
+			}
+			var rec sinkRecord
+			if err := json.NewDecoder(w.Body).Decode(&rec); err != nil {
+				t.Fatal(err)
+			}
+			if len(rec.Values) != len(tt.want) {
+				t.Fatalf("values = %v, want %v", rec.Values, tt.want)
+			}
+			for k, v := range tt.want {
+				if got, ok := rec.Values[k]; !ok || math.Abs(got-v) > 1e-9 {
+					t.Errorf("values[%s] = %v, want %v", k, got, v)
+				}
+			}
+		})
+	}
+	if v := latestRecords["1"].Values["air_temperature"]; v != 20 {
+		t.Errorf("latest air_temperature = %v after serving imperial units, want 20", v)
+	}
+}
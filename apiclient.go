@@ -0,0 +1,259 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/nalbury/tempest-exporter/pkg/weatherflow"
+)
+
+// defaultRetryAfter is how long we back off when rate limited without a usable
+// Retry-After header
+const defaultRetryAfter = time.Minute
+
+var (
+	// apiClient is our WeatherFlow API client
+	apiClient = newAPIClient(http.DefaultTransport)
+	// rateLimitMu guards rateLimitedUntil
+	rateLimitMu sync.Mutex
+	// rateLimitedUntil is when the API said we can make requests again after
+	// rate limiting our token
+	rateLimitedUntil time.Time
+	// validatorsMu guards validators
+	validatorsMu sync.Mutex
+	// validators holds the cache validators and body of the latest response
+	// from each API URL that sent them, for conditional requests
+	validators = make(map[string]validated)
+)
+
+// validated is a response we can revalidate with a conditional request
+type validated struct {
+	etag         string
+	lastModified string
+	body         []byte
+}
+
+// apiTransport makes our API requests resilient: it fails fast while we're
+// backing off from rate limiting, retries failed requests, and makes
+// conditional requests, answering a 304 Not Modified with the body it
+// revalidated. It keeps its own copy of our API settings, so requests don't
+// read our config and can be made without holding configMu.
+type apiTransport struct {
+	next         http.RoundTripper
+	timeout      time.Duration
+	retries      int
+	retryBackoff time.Duration
+}
+
+// RoundTrip implements http.RoundTripper
+func (t apiTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if until, ok := rateLimited(time.Now()); ok {
+		return nil, rateLimitedError{until}
+	}
+	reqURL := req.URL.String()
+	resp, body, err := t.conditionalGet(req)
+	for n := 0; n < t.retries && retryable(resp, err); n++ {
+		time.Sleep(t.retryDelay(n))
+		resp, body, err = t.conditionalGet(req)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return nil, rateLimitedError{setRateLimited(resp, time.Now())}
+	}
+	if body, err = revalidate(reqURL, resp, body); err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotModified {
+		resp.StatusCode, resp.Status = http.StatusOK, "200 OK"
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	return resp, nil
+}
+
+// conditionalGet makes a request, asking for a 304 Not Modified response if
+// it hasn't changed since the validators of our last response, and reads its
+// body within our API timeout
+func (t apiTransport) conditionalGet(req *http.Request) (*http.Response, []byte, error) {
+	ctx, cancel := context.WithTimeout(req.Context(), t.timeout)
+	defer cancel()
+	req = req.Clone(ctx)
+	validatorsMu.Lock()
+	v, ok := validators[req.URL.String()]
+	validatorsMu.Unlock()
+	if ok && v.etag != "" {
+		req.Header.Set("If-None-Match", v.etag)
+	}
+	if ok && v.lastModified != "" {
+		req.Header.Set("If-Modified-Since", v.lastModified)
+	}
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	return resp, body, nil
+}
+
+// newAPIClient returns a WeatherFlow API client authorized with our token,
+// making requests with transport
+func newAPIClient(transport http.RoundTripper) *weatherflow.Client {
+	c := weatherflow.NewClient(cfg.Token)
+	c.HTTPClient = &http.Client{Transport: apiTransport{
+		next:         instrumentedTransport{transport},
+		timeout:      time.Duration(cfg.API.Timeout),
+		retries:      cfg.API.Retries,
+		retryBackoff: time.Duration(cfg.API.RetryBackoff),
+	}}
+	return c
+}
+
+// revalidate returns the body to use for a response: our stored body if it
+// was not modified, or else its own body, storing its validators if it has any
+func revalidate(reqURL string, resp *http.Response, body []byte) ([]byte, error) {
+	validatorsMu.Lock()
+	defer validatorsMu.Unlock()
+	if resp.StatusCode == http.StatusNotModified {
+		v, ok := validators[reqURL]
+		if !ok {
+			return nil, fmt.Errorf("got 304 Not Modified for a request we have no response for")
+		}
+		return v.body, nil
+	}
+	etag, lastModified := resp.Header.Get("ETag"), resp.Header.Get("Last-Modified")
+	if resp.StatusCode == http.StatusOK && (etag != "" || lastModified != "") {
+		validators[reqURL] = validated{etag: etag, lastModified: lastModified, body: body}
+	} else {
+		delete(validators, reqURL)
+	}
+	return body, nil
+}
+
+// rateLimitedError is returned for requests rate limited by the API, or not
+// made because we're still backing off from rate limiting
+type rateLimitedError struct {
+	until time.Time
+}
+
+// Error implements error
+func (e rateLimitedError) Error() string {
+	return fmt.Sprintf("rate limited by the WeatherFlow API until %s", e.until.Format(time.RFC3339))
+}
+
+// rateLimited returns when we can make requests again, if we're backing off
+// from rate limiting
+func rateLimited(now time.Time) (time.Time, bool) {
+	rateLimitMu.Lock()
+	defer rateLimitMu.Unlock()
+	return rateLimitedUntil, now.Before(rateLimitedUntil)
+}
+
+// setRateLimited records a rate limited response, returning when we can make
+// requests again
+func setRateLimited(resp *http.Response, now time.Time) time.Time {
+	apiRateLimited.Inc()
+	rateLimitMu.Lock()
+	defer rateLimitMu.Unlock()
+	rateLimitedUntil = now.Add(retryAfter(resp.Header.Get("Retry-After"), now))
+	return rateLimitedUntil
+}
+
+// retryAfter parses a Retry-After header, which is either a number of seconds
+// or an HTTP date
+func retryAfter(h string, now time.Time) time.Duration {
+	if s, err := strconv.Atoi(h); err == nil && s >= 0 {
+		return time.Duration(s) * time.Second
+	}
+	if t, err := http.ParseTime(h); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return defaultRetryAfter
+}
+
+// setupAPIClient configures our API client from our config. Requests go
+// through the proxy in our config if set, or else the one in the
+// HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables.
+func setupAPIClient() error {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	if cfg.API.ProxyURL != "" {
+		u, err := url.Parse(cfg.API.ProxyURL)
+		if err != nil {
+			return fmt.Errorf("invalid api proxy url: %v", err)
+		}
+		t.Proxy = http.ProxyURL(u)
+	}
+	tlsConfig, err := apiTLSConfig()
+	if err != nil {
+		return err
+	}
+	t.TLSClientConfig = tlsConfig
+	apiClient = newAPIClient(t)
+	return nil
+}
+
+// tlsVersions are the TLS versions our minimum TLS version can be set to
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// apiTLSConfig returns the TLS config for API requests, trusting the system's
+// CAs plus any in our CA file
+func apiTLSConfig() (*tls.Config, error) {
+	c := &tls.Config{
+		MinVersion:         tlsVersions[cfg.API.TLSMinVersion],
+		InsecureSkipVerify: cfg.API.InsecureSkipVerify,
+	}
+	if cfg.API.InsecureSkipVerify {
+		slog.Warn("not verifying the WeatherFlow API's TLS certificate")
+	}
+	if cfg.API.CAFile == "" {
+		return c, nil
+	}
+	pem, err := ioutil.ReadFile(cfg.API.CAFile)
+	if err != nil {
+		return nil, fmt.Errorf("error reading api ca file: %v", err)
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in api ca file %s", cfg.API.CAFile)
+	}
+	c.RootCAs = pool
+	return c, nil
+}
+
+// retryDelay returns how long to wait before retrying a request for the nth
+// time, doubling from our retry backoff with up to 50% jitter so retries from
+// several exporters don't line up
+func (t apiTransport) retryDelay(n int) time.Duration {
+	d := t.retryBackoff << n
+	return d + time.Duration(rand.Int63n(int64(d)/2+1))
+}
+
+// retryable returns whether a failed request is worth retrying: it got no
+// response at all, or a server error
+func retryable(resp *http.Response, err error) bool {
+	return err != nil || resp.StatusCode >= 500
+}
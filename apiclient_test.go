@@ -0,0 +1,38 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRetryDelay(t *testing.T) {
+	tests := []struct {
+		backoff  time.Duration
+		n        int
+		min, max time.Duration
+	}{
+		{backoff: time.Second, n: 0, min: time.Second, max: 1500 * time.Millisecond},
+		{backoff: time.Second, n: 2, min: 4 * time.Second, max: 6 * time.Second},
+		{backoff: 0, n: 3, min: 0, max: 0},
+	}
+	for _, tt := range tests {
+		tr := apiTransport{retryBackoff: tt.backoff}
+		for i := 0; i < 20; i++ {
+			if d := tr.retryDelay(tt.n); d < tt.min || d > tt.max {
+				t.Fatalf("retryDelay(%d) with a %s backoff = %s, want between %s and %s", tt.n, tt.backoff, d, tt.min, tt.max)
+			}
+		}
+	}
+}
+
+func TestValidateRetryBackoff(t *testing.T) {
+	c := defaultConfig()
+	c.Token = "token"
+	if err := c.validate(); err != nil {
+		t.Fatalf("validate() error = %v", err)
+	}
+	c.API.RetryBackoff = duration(-time.Second)
+	if err := c.validate(); err == nil {
+		t.Error("validate() accepted a negative retry_backoff")
+	}
+}
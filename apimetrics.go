@@ -0,0 +1,74 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	// apiRequestDuration is the latency of our WeatherFlow API requests
+	apiRequestDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: ns,
+			Subsystem: "exporter",
+			Name:      "api_request_duration_seconds",
+			Help:      "Duration of WeatherFlow API requests",
+			Buckets:   []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10},
+		},
+		[]string{"endpoint"},
+	)
+	// apiRequests counts our WeatherFlow API requests by response status
+	apiRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: ns,
+			Subsystem: "exporter",
+			Name:      "api_requests_total",
+			Help:      "WeatherFlow API requests by endpoint and HTTP status code, with code \"error\" for requests that got no response",
+		},
+		[]string{"endpoint", "code"},
+	)
+	// apiRateLimited counts the requests rate limited by the WeatherFlow API
+	apiRateLimited = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: ns,
+			Subsystem: "exporter",
+			Name:      "api_rate_limited_total",
+			Help:      "WeatherFlow API requests rejected with 429 Too Many Requests",
+		},
+	)
+)
+
+// instrumentedTransport records the duration and status of each request
+type instrumentedTransport struct {
+	next http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper
+func (t instrumentedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	endpoint := apiEndpoint(req.URL.Path)
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	apiRequestDuration.WithLabelValues(endpoint).Observe(time.Since(start).Seconds())
+	code := "error"
+	if err == nil {
+		code = strconv.Itoa(resp.StatusCode)
+	}
+	apiRequests.WithLabelValues(endpoint, code).Inc()
+	return resp, err
+}
+
+// apiEndpoint returns the API endpoint of a request path without its IDs, like
+// "observations/station"
+func apiEndpoint(path string) string {
+	var parts []string
+	for _, p := range strings.Split(strings.TrimPrefix(path, "/swd/rest/"), "/") {
+		if _, err := strconv.Atoi(p); err != nil && p != "" {
+			parts = append(parts, p)
+		}
+	}
+	return strings.Join(parts, "/")
+}
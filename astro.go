@@ -0,0 +1,146 @@
+package main
+
+import (
+	"math"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// synodicMonth is the average length of a lunar cycle, in days
+const synodicMonth = 29.530588853
+
+// sunriseElevation is the solar elevation (in degrees) at sunrise and sunset,
+// accounting for refraction and the size of the sun's disc
+const sunriseElevation = -0.833
+
+// civilTwilightElevation is the solar elevation (in degrees) at the end of
+// civil twilight
+const civilTwilightElevation = -6.0
+
+var (
+	// isDaylight is 1 while the sun is up at the station
+	isDaylight *prometheus.GaugeVec
+	// moonPhase, moonIllumination and moonAge describe the current moon
+	moonPhase        *prometheus.GaugeVec
+	moonIllumination *prometheus.GaugeVec
+	moonAge          *prometheus.GaugeVec
+)
+
+// rad converts degrees to radians
+func rad(d float64) float64 {
+	return d * math.Pi / 180
+}
+
+// deg converts radians to degrees
+func deg(r float64) float64 {
+	return r * 180 / math.Pi
+}
+
+// daysSinceJ2000 returns the (fractional) days between the J2000 epoch and t
+func daysSinceJ2000(t time.Time) float64 {
+	return float64(t.Unix())/86400 - 10957.5
+}
+
+// solarElevation returns the elevation of the sun above the horizon, in
+// degrees, at the given time and location. It uses the low precision
+// algorithm from the Astronomical Almanac, accurate to about a minute of arc.
+func solarElevation(t time.Time, latitude, longitude float64) float64 {
+	d := daysSinceJ2000(t)
+	// mean anomaly, mean longitude and ecliptic longitude of the sun
+	g := rad(357.529 + 0.98560028*d)
+	q := 280.459 + 0.98564736*d
+	l := rad(q + 1.915*math.Sin(g) + 0.020*math.Sin(2*g))
+	// obliquity of the ecliptic
+	e := rad(23.439 - 0.00000036*d)
+
+	ra := math.Atan2(math.Cos(e)*math.Sin(l), math.Cos(l))
+	dec := math.Asin(math.Sin(e) * math.Sin(l))
+	// local sidereal time and hour angle
+	gmst := math.Mod(18.697374558+24.06570982441908*d, 24)
+	ha := rad(gmst*15+longitude) - ra
+
+	lat := rad(latitude)
+	return deg(math.Asin(math.Sin(lat)*math.Sin(dec) + math.Cos(lat)*math.Cos(dec)*math.Cos(ha)))
+}
+
+// moon returns the phase of the moon at t as a fraction of the lunar cycle
+// (0 new, 0.5 full) and the illuminated fraction of its disc. It uses the
+// sun's and moon's ecliptic longitudes from Meeus' low precision formulae.
+func moon(t time.Time) (phase, illumination float64) {
+	d := daysSinceJ2000(t)
+	// sun's ecliptic longitude
+	g := rad(357.529 + 0.98560028*d)
+	ls := 280.459 + 0.98564736*d + 1.915*math.Sin(g) + 0.020*math.Sin(2*g)
+	// moon's mean longitude, mean anomaly and the main periodic terms of its
+	// ecliptic longitude
+	lm := 218.316 + 13.176396*d
+	mm := rad(134.963 + 13.064993*d)
+	dm := rad(297.850 + 12.190749*d)
+	lm += 6.289*math.Sin(mm) + 1.274*math.Sin(2*dm-mm) + 0.658*math.Sin(2*dm) - 0.186*math.Sin(g)
+
+	elong := math.Mod(lm-ls, 360)
+	if elong < 0 {
+		elong += 360
+	}
+	return elong / 360, (1 - math.Cos(rad(elong))) / 2
+}
+
+// registerAstro creates and registers our astronomical gauges
+func registerAstro(labelNames []string) {
+	isDaylight = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: ns,
+			Subsystem: ss,
+			Name:      "is_daylight",
+			Help:      "1 if the sun is up at the station, 0 otherwise",
+		},
+		labelNames,
+	)
+	moonPhase = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: ns,
+			Subsystem: ss,
+			Name:      "moon_phase",
+			Help:      "Phase of the moon as a fraction of the lunar cycle (0 new, 0.25 first quarter, 0.5 full, 0.75 last quarter)",
+		},
+		labelNames,
+	)
+	moonIllumination = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: ns,
+			Subsystem: ss,
+			Name:      "moon_illumination_ratio",
+			Help:      "Illuminated fraction of the moon's disc",
+		},
+		labelNames,
+	)
+	moonAge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: ns,
+			Subsystem: ss,
+			Name:      "moon_age_days",
+			Help:      "Days since the last new moon",
+		},
+		labelNames,
+	)
+	weatherRegistry.MustRegister(isDaylight, moonPhase, moonIllumination, moonAge)
+}
+
+// setAstro updates our astronomical gauges for a station at time t
+func setAstro(r response, t time.Time, labels prometheus.Labels) {
+	limit := sunriseElevation
+	if cfg.DaylightTwilight == "civil" {
+		limit = civilTwilightElevation
+	}
+	var day float64
+	if solarElevation(t, r.Latitude, r.Longitude) > limit {
+		day = 1
+	}
+	isDaylight.With(labels).Set(day)
+
+	phase, illumination := moon(t)
+	moonPhase.With(labels).Set(phase)
+	moonIllumination.With(labels).Set(illumination)
+	moonAge.With(labels).Set(phase * synodicMonth)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestSolarElevation(t *testing.T) {
+	tests := []struct {
+		name     string
+		t        time.Time
+		lat, lon float64
+		want     float64
+	}{
+		// 90 - latitude + the sun's declination at the solstice
+		{name: "greenwich solstice noon", t: time.Date(2026, 6, 21, 12, 0, 0, 0, time.UTC), lat: 51.48, want: 61.96},
+		{name: "greenwich solstice midnight", t: time.Date(2026, 6, 21, 0, 0, 0, 0, time.UTC), lat: 51.48, want: -15.08},
+		{name: "equator equinox noon", t: time.Date(2026, 3, 20, 12, 7, 0, 0, time.UTC), lat: 0, lon: 0, want: 90},
+		{name: "west of greenwich", t: time.Date(2026, 6, 21, 17, 0, 0, 0, time.UTC), lat: 51.48, lon: -75, want: 61.96},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := solarElevation(tt.t, tt.lat, tt.lon); math.Abs(got-tt.want) > 0.5 {
+				t.Errorf("solarElevation() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMoon(t *testing.T) {
+	tests := []struct {
+		name                string
+		t                   time.Time
+		phase, illumination float64
+	}{
+		{name: "new moon", t: time.Date(2024, 1, 11, 11, 57, 0, 0, time.UTC), phase: 0, illumination: 0},
+		{name: "first quarter", t: time.Date(2024, 1, 18, 3, 52, 0, 0, time.UTC), phase: 0.25, illumination: 0.5},
+		{name: "full moon", t: time.Date(2024, 1, 25, 17, 54, 0, 0, time.UTC), phase: 0.5, illumination: 1},
+		{name: "last quarter", t: time.Date(2024, 2, 2, 23, 18, 0, 0, time.UTC), phase: 0.75, illumination: 0.5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			phase, illumination := moon(tt.t)
+			// new moons fall either side of 0
+			if d := math.Abs(phase - tt.phase); math.Min(d, 1-d) > 0.01 {
+				t.Errorf("moon() phase = %v, want %v", phase, tt.phase)
+			}
+			if math.Abs(illumination-tt.illumination) > 0.02 {
+				t.Errorf("moon() illumination = %v, want %v", illumination, tt.illumination)
+			}
+		})
+	}
+}
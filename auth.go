@@ -0,0 +1,30 @@
+package main
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// basicAuth wraps h to require the username and password configured in our
+// basic auth settings
+func basicAuth(h http.Handler) http.Handler {
+	hash := []byte(cfg.BasicAuth.PasswordHash)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if probePaths[r.URL.Path] {
+			h.ServeHTTP(w, r)
+			return
+		}
+		user, pass, ok := r.BasicAuth()
+		userOK := subtle.ConstantTimeCompare([]byte(user), []byte(cfg.BasicAuth.Username)) == 1
+		// always check the password so a wrong username takes as long
+		passOK := bcrypt.CompareHashAndPassword(hash, []byte(pass)) == nil
+		if !ok || !userOK || !passOK {
+			w.Header().Set("WWW-Authenticate", `Basic realm="tempest-exporter"`)
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
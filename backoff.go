@@ -0,0 +1,64 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"time"
+)
+
+// maxBackoff is the longest we wait before retrying a failing station
+const maxBackoff = 5 * time.Minute
+
+// backoff tracks the consecutive failures of a station
+type backoff struct {
+	failures int
+	next     time.Time
+}
+
+// backoffs holds the backoff state of each failing station
+var backoffs = make(map[string]*backoff)
+
+// shouldPoll returns whether a station is due to be polled, or is still
+// backing off after failures
+func shouldPoll(station string, now time.Time) bool {
+	b, ok := backoffs[station]
+	return !ok || !now.Before(b.next)
+}
+
+// pollFailed records a failed poll of a station, doubling the time until we
+// retry it with each consecutive failure, or waiting until the API allows
+// requests again if we were rate limited
+func pollFailed(station string, now time.Time, err error) time.Duration {
+	b, ok := backoffs[station]
+	if !ok {
+		b = &backoff{}
+		backoffs[station] = b
+	}
+	return b.fail(now, err)
+}
+
+// fail records a failure, returning how long to wait before retrying
+func (b *backoff) fail(now time.Time, err error) time.Duration {
+	b.failures++
+	d := time.Duration(cfg.PollInterval)
+	for i := 1; i < b.failures && d < maxBackoff; i++ {
+		d *= 2
+	}
+	if d > maxBackoff {
+		d = maxBackoff
+	}
+	var rl rateLimitedError
+	if errors.As(err, &rl) && rl.until.Sub(now) > d {
+		d = rl.until.Sub(now).Round(time.Second)
+	}
+	b.next = now.Add(d)
+	return d
+}
+
+// pollSucceeded clears a station's backoff after a successful poll
+func pollSucceeded(station string) {
+	if b, ok := backoffs[station]; ok {
+		slog.Info("station recovered", "station_id", station, "failed_polls", b.failures)
+		delete(backoffs, station)
+	}
+}
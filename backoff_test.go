@@ -0,0 +1,26 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBackoffFail(t *testing.T) {
+	defer func(d duration) { cfg.PollInterval = d }(cfg.PollInterval)
+	cfg.PollInterval = duration(time.Minute)
+	now := time.Unix(1700000000, 0)
+	var b backoff
+	for i, want := range []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute, maxBackoff, maxBackoff} {
+		if d := b.fail(now, errors.New("failed")); d != want {
+			t.Errorf("fail() #%d = %s, want %s", i+1, d, want)
+		}
+	}
+	if !b.next.Equal(now.Add(maxBackoff)) {
+		t.Errorf("next = %s, want %s", b.next, now.Add(maxBackoff))
+	}
+	rl := rateLimitedError{until: now.Add(time.Hour)}
+	if d := b.fail(now, rl); d != time.Hour {
+		t.Errorf("fail() when rate limited = %s, want 1h", d)
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// bound is the range of plausible values for a metric
+type bound struct {
+	Min float64 `json:"min"`
+	Max float64 `json:"max"`
+}
+
+// boundsConfig holds plausibility bounds keyed by metric name
+type boundsConfig map[string]bound
+
+// defaultBounds are the plausibility bounds applied to readings unless
+// overridden with WEATHERFLOW_BOUNDS
+var defaultBounds = map[string]bound{
+	"air_temperature":      {-60, 60},
+	"dew_point":            {-80, 60},
+	"feels_like":           {-90, 80},
+	"heat_index":           {-60, 80},
+	"wind_chill":           {-90, 60},
+	"wet_bulb_temperature": {-60, 60},
+	"relative_humidity":    {0, 100},
+	"barometric_pressure":  {500, 1100},
+	"sea_level_pressure":   {850, 1100},
+	"station_pressure":     {500, 1100},
+	"wind_avg":             {0, 120},
+	"wind_gust":            {0, 120},
+	"wind_lull":            {0, 120},
+	"wind_direction":       {0, 360},
+	"uv":                   {0, 20},
+	"solar_radiation":      {0, 1800},
+	"brightness":           {0, 200000},
+	"precip":               {0, 100},
+}
+
+var (
+	// bounds are the plausibility bounds in effect
+	bounds = defaultBounds
+	// readingsRejected counts readings that fell outside their plausibility bounds
+	readingsRejected = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: ns,
+			Subsystem: "exporter",
+			Name:      "readings_rejected_total",
+			Help:      "Readings outside their plausibility bounds",
+		},
+		[]string{"metric"},
+	)
+)
+
+// UnmarshalText parses bounds in the form "metric=min:max,metric=min:max".
+// Either side of the range may be left empty to leave it unbounded.
+func (b *boundsConfig) UnmarshalText(text []byte) error {
+	*b = make(boundsConfig)
+	for _, item := range strings.Split(string(text), ",") {
+		kv := strings.SplitN(strings.TrimSpace(item), "=", 2)
+		if len(kv) != 2 {
+			return fmt.Errorf("invalid bound %q, expected metric=min:max", item)
+		}
+		r := strings.SplitN(kv[1], ":", 2)
+		if len(r) != 2 {
+			return fmt.Errorf("invalid range %q for %s, expected min:max", kv[1], kv[0])
+		}
+		bd := bound{Min: math.Inf(-1), Max: math.Inf(1)}
+		var err error
+		if r[0] != "" {
+			if bd.Min, err = strconv.ParseFloat(r[0], 64); err != nil {
+				return fmt.Errorf("invalid minimum for %s: %v", kv[0], err)
+			}
+		}
+		if r[1] != "" {
+			if bd.Max, err = strconv.ParseFloat(r[1], 64); err != nil {
+				return fmt.Errorf("invalid maximum for %s: %v", kv[0], err)
+			}
+		}
+		(*b)[kv[0]] = bd
+	}
+	return nil
+}
+
+// UnmarshalJSON parses bounds from a string or an object of ranges
+func (b *boundsConfig) UnmarshalJSON(text []byte) error {
+	return unmarshalJSONOrText(text, b, (*map[string]bound)(b))
+}
+
+// UnmarshalJSON parses a range, leaving either side unbounded if it's missing
+func (bd *bound) UnmarshalJSON(text []byte) error {
+	type plain bound
+	p := plain{Min: math.Inf(-1), Max: math.Inf(1)}
+	if err := json.Unmarshal(text, &p); err != nil {
+		return err
+	}
+	*bd = bound(p)
+	return nil
+}
+
+// mergeBounds returns our default bounds with overrides applied on top
+func mergeBounds(overrides boundsConfig) (map[string]bound, error) {
+	b := make(map[string]bound)
+	for k, v := range defaultBounds {
+		b[k] = v
+	}
+	for k, v := range overrides {
+		if v.Min > v.Max {
+			return nil, fmt.Errorf("invalid bounds for %s: min is greater than max", k)
+		}
+		b[k] = v
+	}
+	return b, nil
+}
+
+// inBounds returns whether a reading is within its plausibility bounds
+func inBounds(metric string, v float64) bool {
+	b, ok := bounds[metric]
+	return !ok || (v >= b.Min && v <= b.Max)
+}
+
+// applyBounds applies our plausibility bounds to an observation's readings,
+// clamping them or leaving them out, so everything that uses the observation
+// sees the same readings
+func (o *observation) applyBounds() {
+	values := o.values()
+	for name, v := range values {
+		bv, ok := checkBounds(name, v)
+		if ok {
+			if bv != v {
+				setFieldValue(&o.Observation, name, bv)
+			}
+			continue
+		}
+		if o.fields == nil {
+			o.fields = make(map[string]bool)
+			for n := range values {
+				o.fields[n] = true
+			}
+		}
+		delete(o.fields, name)
+		o.dropped = append(o.dropped, name)
+	}
+}
+
+// checkBounds returns the value to use for a reading, and false if the
+// reading should be dropped
+func checkBounds(metric string, v float64) (float64, bool) {
+	if inBounds(metric, v) {
+		return v, true
+	}
+	readingsRejected.WithLabelValues(metric).Inc()
+	if cfg.BoundsMode != "clamp" {
+		return v, false
+	}
+	b := bounds[metric]
+	return math.Max(b.Min, math.Min(b.Max, v)), true
+}
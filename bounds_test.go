@@ -0,0 +1,152 @@
+package main
+
+import (
+	"math"
+	"testing"
+
+	"github.com/nalbury/tempest-exporter/pkg/weatherflow"
+)
+
+func TestBoundsConfigUnmarshalText(t *testing.T) {
+	tests := []struct {
+		text string
+		want boundsConfig
+		err  bool
+	}{
+		{text: "air_temperature=-40:50", want: boundsConfig{"air_temperature": {-40, 50}}},
+		{text: "uv=:15, precip=0:", want: boundsConfig{"uv": {math.Inf(-1), 15}, "precip": {0, math.Inf(1)}}},
+		{text: "air_temperature", err: true},
+		{text: "air_temperature=-40", err: true},
+		{text: "air_temperature=a:50", err: true},
+		{text: "air_temperature=-40:b", err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			var b boundsConfig
+			err := b.UnmarshalText([]byte(tt.text))
+			if (err != nil) != tt.err {
+				t.Fatalf("UnmarshalText() error = %v, want error %v", err, tt.err)
+			}
+			if tt.err {
+				return
+			}
+			if len(b) != len(tt.want) {
+				t.Fatalf("UnmarshalText() = %v, want %v", b, tt.want)
+			}
+			for k, v := range tt.want {
+				if b[k] != v {
+					t.Errorf("UnmarshalText()[%s] = %v, want %v", k, b[k], v)
+				}
+			}
+		})
+	}
+}
+
+func TestBoundsConfigUnmarshalJSON(t *testing.T) {
+	var b boundsConfig
+	if err := b.UnmarshalJSON([]byte(`{"uv": {"max": 15}}`)); err != nil {
+		t.Fatal(err)
+	}
+	if want := (bound{math.Inf(-1), 15}); b["uv"] != want {
+		t.Errorf("UnmarshalJSON()[uv] = %v, want %v", b["uv"], want)
+	}
+}
+
+func TestMergeBounds(t *testing.T) {
+	b, err := mergeBounds(boundsConfig{"uv": {0, 15}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b["uv"] != (bound{0, 15}) {
+		t.Errorf("merged uv bound = %v, want {0 15}", b["uv"])
+	}
+	if b["air_temperature"] != defaultBounds["air_temperature"] {
+		t.Errorf("merged air_temperature bound = %v, want the default", b["air_temperature"])
+	}
+	if _, err := mergeBounds(boundsConfig{"uv": {15, 0}}); err == nil {
+		t.Error("mergeBounds() accepted a minimum greater than the maximum")
+	}
+}
+
+func TestCheckBounds(t *testing.T) {
+	defer func(mode string) { cfg.BoundsMode = mode }(cfg.BoundsMode)
+	tests := []struct {
+		mode   string
+		metric string
+		v      float64
+		want   float64
+		ok     bool
+	}{
+		{mode: "drop", metric: "air_temperature", v: 20, want: 20, ok: true},
+		{mode: "drop", metric: "air_temperature", v: 80, want: 80, ok: false},
+		{mode: "drop", metric: "timestamp", v: 1e9, want: 1e9, ok: true},
+		{mode: "clamp", metric: "air_temperature", v: 80, want: 60, ok: true},
+		{mode: "clamp", metric: "relative_humidity", v: -3, want: 0, ok: true},
+	}
+	for _, tt := range tests {
+		cfg.BoundsMode = tt.mode
+		got, ok := checkBounds(tt.metric, tt.v)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("checkBounds(%s, %v) in %s mode = %v, %v, want %v, %v", tt.metric, tt.v, tt.mode, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestApplyBounds(t *testing.T) {
+	defer func(mode string) { cfg.BoundsMode = mode }(cfg.BoundsMode)
+	tests := []struct {
+		name   string
+		mode   string
+		o      observation
+		metric string
+		want   float64
+		ok     bool
+	}{
+		{
+			name:   "in bounds",
+			mode:   "drop",
+			o:      observation{Observation: weatherflow.Observation{AirTemperature: 20}},
+			metric: "air_temperature", want: 20, ok: true,
+		},
+		{
+			name:   "dropped",
+			mode:   "drop",
+			o:      observation{Observation: weatherflow.Observation{AirTemperature: 80}},
+			metric: "air_temperature", ok: false,
+		},
+		{
+			name:   "dropped from a partial observation",
+			mode:   "drop",
+			o:      observation{Observation: weatherflow.Observation{RelativeHumidity: 120}, fields: map[string]bool{"relative_humidity": true}},
+			metric: "relative_humidity", ok: false,
+		},
+		{
+			name:   "clamped",
+			mode:   "clamp",
+			o:      observation{Observation: weatherflow.Observation{AirTemperature: 80}},
+			metric: "air_temperature", want: 60, ok: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg.BoundsMode = tt.mode
+			o := tt.o
+			o.applyBounds()
+			if o.has(tt.metric) != tt.ok {
+				t.Fatalf("has(%s) = %v, want %v", tt.metric, o.has(tt.metric), tt.ok)
+			}
+			// sinks, our API and history all get the bounded readings
+			v, ok := observationRecord("1", o, nil).Values[tt.metric]
+			if ok != tt.ok || v != tt.want {
+				t.Errorf("record value of %s = %v, %v, want %v, %v", tt.metric, v, ok, tt.want, tt.ok)
+			}
+			if contains(o.dropped, tt.metric) == tt.ok {
+				t.Errorf("dropped = %v, want %s dropped %v", o.dropped, tt.metric, !tt.ok)
+			}
+			// and derived values are computed from the clamped ones
+			if tt.ok && tt.metric == "air_temperature" && o.AirTemperature != tt.want {
+				t.Errorf("AirTemperature = %v, want %v", o.AirTemperature, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,967 @@
+package main
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"gopkg.in/yaml.v3"
+)
+
+// config holds the exporter's settings. Each setting can be set in a YAML
+// config file under the name in its json tag, overridden with the environment
+// variable in its env tag, and in turn by the command line flag in its flag
+// tag.
+type config struct {
+	ListenAddresses     []string             `json:"listen_address" env:"WEATHERFLOW_LISTEN_ADDRESS" flag:"web.listen-address" description:"Addresses to serve metrics on, like localhost:6969, [::1]:6969 or :6969 for every interface"`
+	TelemetryPath       string               `json:"telemetry_path" env:"WEATHERFLOW_TELEMETRY_PATH" flag:"web.telemetry-path" description:"Path to serve weather metrics under"`
+	WebConfigFile       string               `json:"web_config_file" env:"WEATHERFLOW_WEB_CONFIG_FILE" flag:"web.config.file" description:"Exporter toolkit web config file enabling TLS and other server settings"`
+	BasicAuth           basicAuthConfig      `json:"basic_auth" description:"Basic authentication for every endpoint"`
+	Log                 logConfig            `json:"log" description:"The exporter's own logs"`
+	DisableCompression  bool                 `json:"disable_compression" env:"WEATHERFLOW_DISABLE_COMPRESSION" description:"Don't gzip responses, even for clients that accept it"`
+	EnableLifecycle     bool                 `json:"enable_lifecycle" env:"WEATHERFLOW_ENABLE_LIFECYCLE" flag:"web.enable-lifecycle" description:"Serve the /-/reload and /-/quit admin endpoints"`
+	EnablePprof         bool                 `json:"enable_pprof" env:"WEATHERFLOW_ENABLE_PPROF" flag:"web.enable-pprof" description:"Serve Go profiling endpoints under /debug/pprof/"`
+	PollInterval        duration             `json:"poll_interval" env:"WEATHERFLOW_POLL_INTERVAL" flag:"weatherflow.poll-interval" description:"How often to poll the API in the background"`
+	Source              string               `json:"source" env:"WEATHERFLOW_SOURCE" flag:"weatherflow.source" enum:"api,udp" description:"Whether to poll the WeatherFlow API or listen for hub broadcasts on the local network"`
+	Collection          string               `json:"collection" env:"WEATHERFLOW_COLLECTION" flag:"weatherflow.collection" enum:"poll,scrape" description:"Whether to poll the API in the background or when /metrics is scraped"`
+	ScrapeCacheTTL      duration             `json:"scrape_cache_ttl" env:"WEATHERFLOW_SCRAPE_CACHE_TTL" description:"How long data fetched on a scrape is reused for later scrapes"`
+	UDP                 udpConfig            `json:"udp" description:"Listening for hub broadcasts"`
+	API                 apiConfig            `json:"api" description:"Requests to the WeatherFlow API"`
+	Token               string               `json:"token" env:"WEATHERFLOW_API_TOKEN" secret:"true" description:"WeatherFlow API token"`
+	TokenFile           string               `json:"token_file" env:"WEATHERFLOW_API_TOKEN_FILE" description:"File to read the WeatherFlow API token from, like a mounted secret"`
+	Stations            []string             `json:"stations" env:"WEATHERFLOW_STATION_ID" flag:"weatherflow.station-id" description:"IDs of the stations to export, every station on the account if unset"`
+	DiscoveryInterval   duration             `json:"discovery_interval" env:"WEATHERFLOW_DISCOVERY_INTERVAL" description:"How often to refresh the stations on the account when no stations are set"`
+	StationLabels       string               `json:"station_labels" env:"WEATHERFLOW_STATION_LABELS" enum:"all,id" description:"Whether metrics carry every descriptive station label or only station_id, leaving the rest on tempest_station_info"`
+	Labels              labelsConfig         `json:"labels" description:"Which descriptive station labels are exported"`
+	Units               string               `json:"units" env:"WEATHERFLOW_UNITS" enum:"metric,imperial" description:"Units metrics are served in unless a scrape asks for others with the units query parameter"`
+	MetricNames         string               `json:"metric_names" env:"WEATHERFLOW_METRIC_NAMES" enum:"legacy,conventional" description:"Whether metrics keep their original names or get Prometheus conventional names with unit suffixes"`
+	SampleTimestamps    bool                 `json:"sample_timestamps" env:"WEATHERFLOW_SAMPLE_TIMESTAMPS" description:"Stamp readings with their observation's time and serve OpenMetrics to scrapers that accept it"`
+	StaticLabels        stringMap            `json:"static_labels" env:"WEATHERFLOW_STATIC_LABELS" description:"Constant labels added to every metric, like site=cabin"`
+	StationStaticLabels map[string]stringMap `json:"station_static_labels" description:"Constant labels added to a station's metrics by station ID, overriding static_labels"`
+	StationPairs        stationPairs         `json:"station_pairs" env:"WEATHERFLOW_STATION_PAIRS" description:"Station pairs to export differences between"`
+	Bounds              boundsConfig         `json:"bounds" env:"WEATHERFLOW_BOUNDS" description:"Plausibility bounds per metric, overriding the defaults"`
+	BoundsMode          string               `json:"bounds_mode" env:"WEATHERFLOW_BOUNDS_MODE" enum:"drop,clamp" description:"Whether readings outside their bounds are dropped or clamped"`
+	AnomalyWindow       int                  `json:"anomaly_window" env:"WEATHERFLOW_ANOMALY_WINDOW" minimum:"5" description:"Number of recent readings used to detect spikes"`
+	AnomalyThreshold    float64              `json:"anomaly_threshold" env:"WEATHERFLOW_ANOMALY_THRESHOLD" minimum:"0" description:"Median absolute deviations a reading may move before it is flagged"`
+	DegreeDays          degreeDaysConfig     `json:"degree_days" description:"Growing, heating and cooling degree day accumulation"`
+	MetarIDs            stringMap            `json:"metar_ids" env:"WEATHERFLOW_METAR_IDS" description:"Identifiers stations are reported under on /metar by station ID, like 12345=KXYZ, ZZZZ if unset"`
+	RecordsFile         string               `json:"records_file" env:"WEATHERFLOW_RECORDS_FILE" description:"File to persist station records in"`
+	History             historyConfig        `json:"history" description:"Recent observations kept for export as CSV"`
+	DaylightTwilight    string               `json:"daylight_twilight" env:"WEATHERFLOW_DAYLIGHT_TWILIGHT" enum:"none,civil" description:"Whether civil twilight counts as daylight"`
+	GeohashPrecision    int                  `json:"geohash_precision" env:"WEATHERFLOW_GEOHASH_PRECISION" minimum:"0" maximum:"12" description:"Length of the geohash label on the info metric, 0 to disable"`
+	OfflineAfter        duration             `json:"offline_after" env:"WEATHERFLOW_OFFLINE_AFTER" description:"How old a station's latest observation can get before it is considered offline"`
+	StaleAfter          duration             `json:"stale_after" env:"WEATHERFLOW_STALE_AFTER" description:"How old a station's latest observation can get before its readings stop being exported, 0 to always export them"`
+	WebhookURL          string               `json:"webhook_url" env:"WEATHERFLOW_WEBHOOK_URL" description:"URL to POST station online/offline notifications to"`
+	Notify              notifyConfig         `json:"notify" description:"Silencing and rate limiting of webhook notifications"`
+	AirQuality          airQualityConfig     `json:"air_quality" description:"Co-located air quality sensor"`
+	Proxy               proxyConfig          `json:"proxy" description:"Caching proxy for the WeatherFlow observations API"`
+	Devices             devicesConfig        `json:"devices" description:"Polling of each device's own observations"`
+	Battery             batteryConfig        `json:"battery" description:"Device battery monitoring"`
+	Forecast            forecastConfig       `json:"forecast" description:"Forecast polling"`
+	ErrorReport         errorReportConfig    `json:"error_report" description:"Opt-in error reporting"`
+	HTTPSink            httpSinkConfig       `json:"http_sink" description:"Templated HTTP POST of each observation and event"`
+	CWOP                cwopConfig           `json:"cwop" description:"Upload of a station's observations to CWOP through APRS-IS"`
+	PWSWeather          pwsWeatherConfig     `json:"pwsweather" description:"Upload of a station's observations to PWSWeather"`
+	Windy               windyConfig          `json:"windy" description:"Upload of a station's observations to Windy"`
+	MQTT                mqttConfig           `json:"mqtt" description:"Publishing of observations to an MQTT broker"`
+	InfluxDB            influxConfig         `json:"influxdb" description:"Writing of observations to InfluxDB"`
+	Graphite            graphiteConfig       `json:"graphite" description:"Sending of observations to a Graphite carbon server"`
+	StatsD              statsdConfig         `json:"statsd" description:"Sending of observations to a StatsD or DogStatsD agent"`
+	RemoteWrite         remoteWriteConfig    `json:"remote_write" description:"Pushing of our weather metrics with the Prometheus remote write protocol"`
+	Pushgateway         pushgatewayConfig    `json:"pushgateway" description:"Pushing of our weather metrics to a Prometheus Pushgateway after each poll"`
+	OTLP                otlpConfig           `json:"otlp" description:"Exporting of our weather metrics over OTLP"`
+	Histograms          string               `json:"histograms" env:"WEATHERFLOW_HISTOGRAMS" enum:"none,classic,native,both" description:"Whether to export wind and lightning distributions as classic histograms, native histograms or both"`
+	LowMemory           bool                 `json:"low_memory" env:"WEATHERFLOW_LOW_MEMORY" flag:"low-memory" description:"Use smaller defaults and a soft memory limit for small hosts like a Raspberry Pi Zero"`
+	Collectors          collectorsConfig     `json:"collectors" description:"Runtime collectors on /internal/metrics"`
+	MemoryLimitMB       int                  `json:"memory_limit_mb" env:"WEATHERFLOW_MEMORY_LIMIT_MB" minimum:"0" description:"Soft memory limit in MiB, 0 for none; GOMEMLIMIT takes precedence"`
+	Relabel             relabelRules         `json:"relabel" env:"WEATHERFLOW_RELABEL" description:"Rules to rename metrics, drop metrics or labels, and map label values"`
+}
+
+// notifyConfig configures when webhook notifications are sent
+type notifyConfig struct {
+	QuietHours []string    `json:"quiet_hours" env:"WEATHERFLOW_NOTIFY_QUIET_HOURS" description:"Windows of station local time like 22:00-07:00 in which notifications are silenced"`
+	Cooldown   durationMap `json:"cooldown" env:"WEATHERFLOW_NOTIFY_COOLDOWN" description:"Minimum time between notifications of each event for a station, like offline=30m,online=30m"`
+	Repeat     duration    `json:"repeat" env:"WEATHERFLOW_NOTIFY_REPEAT" description:"How often to repeat the offline notification while a station stays offline, 0 to disable"`
+}
+
+// basicAuthConfig configures basic authentication
+type basicAuthConfig struct {
+	Username     string `json:"username" env:"WEATHERFLOW_BASIC_AUTH_USERNAME" description:"Username required to access the exporter"`
+	PasswordHash string `json:"password_hash" env:"WEATHERFLOW_BASIC_AUTH_PASSWORD_HASH" secret:"true" description:"Bcrypt hash of the password required to access the exporter"`
+}
+
+// apiConfig configures requests to the WeatherFlow API
+type apiConfig struct {
+	Timeout            duration `json:"timeout" env:"WEATHERFLOW_API_TIMEOUT" description:"Timeout for each WeatherFlow API request"`
+	Retries            int      `json:"retries" env:"WEATHERFLOW_API_RETRIES" minimum:"0" description:"How many times to retry a request that failed to connect or got a server error"`
+	ProxyURL           string   `json:"proxy_url" env:"WEATHERFLOW_API_PROXY_URL" description:"Proxy for WeatherFlow API requests, overriding HTTP_PROXY and HTTPS_PROXY"`
+	CAFile             string   `json:"ca_file" env:"WEATHERFLOW_API_CA_FILE" description:"PEM file of extra CA certificates to trust for WeatherFlow API requests, like a proxy's"`
+	TLSMinVersion      string   `json:"tls_min_version" env:"WEATHERFLOW_API_TLS_MIN_VERSION" enum:"1.0,1.1,1.2,1.3" description:"Minimum TLS version for WeatherFlow API requests"`
+	InsecureSkipVerify bool     `json:"insecure_skip_verify" env:"WEATHERFLOW_API_INSECURE_SKIP_VERIFY" description:"Don't verify the WeatherFlow API's certificate, only for testing"`
+	RetryBackoff       duration `json:"retry_backoff" env:"WEATHERFLOW_API_RETRY_BACKOFF" description:"Delay before the first retry, doubling for each retry after it"`
+}
+
+// logConfig configures the exporter's own logs
+type logConfig struct {
+	Level          string   `json:"level" env:"WEATHERFLOW_LOG_LEVEL" flag:"log.level" enum:"debug,info,warn,error" description:"Only log messages at this level or above"`
+	Format         string   `json:"format" env:"WEATHERFLOW_LOG_FORMAT" flag:"log.format" enum:"text,json" description:"Whether to log logfmt style text or JSON lines"`
+	Access         string   `json:"access" env:"WEATHERFLOW_LOG_ACCESS" flag:"log.access" enum:"common,combined,json,none" description:"Format of the HTTP request log, or none to not log requests"`
+	File           string   `json:"file" env:"WEATHERFLOW_LOG_FILE" flag:"log.file" description:"File to write logs to instead of stdout"`
+	MaxSizeMB      int      `json:"max_size_mb" env:"WEATHERFLOW_LOG_MAX_SIZE_MB" minimum:"0" description:"Size in MiB at which the log file is rotated, 0 to not rotate by size"`
+	RotateInterval duration `json:"rotate_interval" env:"WEATHERFLOW_LOG_ROTATE_INTERVAL" description:"How often the log file is rotated, 0 to not rotate by time"`
+	MaxBackups     int      `json:"max_backups" env:"WEATHERFLOW_LOG_MAX_BACKUPS" minimum:"0" description:"Number of rotated log files to keep, 0 to keep them all"`
+	MaxAge         duration `json:"max_age" env:"WEATHERFLOW_LOG_MAX_AGE" description:"How long rotated log files are kept, 0 to keep them regardless of age"`
+	Syslog         string   `json:"syslog" env:"WEATHERFLOW_LOG_SYSLOG" flag:"log.syslog" description:"Send logs to syslog instead of stdout: local for the local daemon, or a udp://, tcp:// or unix:// address"`
+}
+
+// labelsConfig chooses which descriptive station labels are exported
+type labelsConfig struct {
+	Allow []string `json:"allow" env:"WEATHERFLOW_LABELS_ALLOW" description:"Station labels to export, all of them if unset; station_id is always exported"`
+	Deny  []string `json:"deny" env:"WEATHERFLOW_LABELS_DENY" description:"Station labels not to export, like latitude and longitude for privacy"`
+}
+
+// collectorsConfig chooses which runtime collectors export the exporter's
+// own process and Go runtime metrics
+type collectorsConfig struct {
+	Go      bool `json:"go" env:"WEATHERFLOW_COLLECTORS_GO" flag:"collector.go" description:"Export Go runtime metrics like go_goroutines and go_memstats_*"`
+	Process bool `json:"process" env:"WEATHERFLOW_COLLECTORS_PROCESS" flag:"collector.process" description:"Export process metrics like process_resident_memory_bytes"`
+}
+
+// udpConfig configures listening for hub broadcasts
+type udpConfig struct {
+	Listen   string `json:"listen" env:"WEATHERFLOW_UDP_LISTEN" flag:"udp.listen-address" description:"UDP address to listen for hub broadcasts on"`
+	Timezone string `json:"timezone" env:"WEATHERFLOW_UDP_TIMEZONE" description:"Timezone of the stations heard, for daily aggregates and records"`
+}
+
+// airQualityConfig configures a co-located air quality sensor
+type airQualityConfig struct {
+	Source    string `json:"source" env:"WEATHERFLOW_AQ_SOURCE" enum:",purpleair,airgradient" description:"Type of air quality sensor"`
+	URL       string `json:"url" env:"WEATHERFLOW_AQ_URL" description:"Base URL of the sensor's local API"`
+	StationID string `json:"station_id" env:"WEATHERFLOW_AQ_STATION_ID" description:"Station the sensor is co-located with, defaults to the first station"`
+}
+
+// proxyConfig configures the caching API proxy
+type proxyConfig struct {
+	Enabled bool     `json:"enabled" env:"WEATHERFLOW_PROXY_ENABLED" description:"Serve cached API responses on /proxy/observations/station/{id}"`
+	TTL     duration `json:"ttl" env:"WEATHERFLOW_PROXY_TTL" description:"How long a cached response is served before it is refreshed"`
+}
+
+// devicesConfig configures polling of each device's own observations
+type devicesConfig struct {
+	Enabled  bool     `json:"enabled" env:"WEATHERFLOW_DEVICES_ENABLED" description:"Poll each device's own observations for device metrics and battery monitoring"`
+	Interval duration `json:"interval" env:"WEATHERFLOW_DEVICES_INTERVAL" description:"How often to poll each device's observations"`
+}
+
+// batteryConfig configures device battery monitoring
+type batteryConfig struct {
+	LowVoltage  floatMap `json:"low_voltage" env:"WEATHERFLOW_BATTERY_LOW_VOLTAGE" description:"Voltage below which a device's battery is low, keyed by device type (ST, AR, SK)"`
+	Hysteresis  float64  `json:"hysteresis" env:"WEATHERFLOW_BATTERY_HYSTERESIS" minimum:"0" description:"Volts above the low threshold a battery must recover to before it is no longer low"`
+	TrendWindow duration `json:"trend_window" env:"WEATHERFLOW_BATTERY_TREND_WINDOW" description:"How much voltage history is used to classify a battery as charging, discharging or steady"`
+}
+
+// forecastConfig configures forecast polling
+type forecastConfig struct {
+	Enabled         bool     `json:"enabled" env:"WEATHERFLOW_FORECAST_ENABLED" description:"Poll the forecast API for each station"`
+	Interval        duration `json:"interval" env:"WEATHERFLOW_FORECAST_INTERVAL" description:"How often to poll the forecast API"`
+	RainProbability float64  `json:"rain_probability" env:"WEATHERFLOW_FORECAST_RAIN_PROBABILITY" minimum:"0" maximum:"100" description:"Chance of precipitation, in percent, at which rain is considered expected"`
+	Hours           int      `json:"hours" env:"WEATHERFLOW_FORECAST_HOURS" minimum:"0" maximum:"240" description:"Number of hours ahead to export the hourly forecast for, 0 to disable"`
+	Days            int      `json:"days" env:"WEATHERFLOW_FORECAST_DAYS" minimum:"0" maximum:"10" description:"Number of days ahead to export the daily forecast for, 0 to disable"`
+}
+
+// degreeDaysConfig configures degree day accumulation
+type degreeDaysConfig struct {
+	GrowingBase float64 `json:"growing_base" env:"WEATHERFLOW_GDD_BASE" description:"Base air temperature in °C for growing degree days"`
+	Base        float64 `json:"base" env:"WEATHERFLOW_DEGREE_DAYS_BASE" description:"Base air temperature in °C for heating and cooling degree days"`
+	SeasonStart string  `json:"season_start" env:"WEATHERFLOW_GDD_SEASON_START" description:"Month and day the growing season starts each year, like 04-01"`
+	File        string  `json:"file" env:"WEATHERFLOW_DEGREE_DAYS_FILE" description:"File to persist degree days in, so they survive restarts"`
+}
+
+// historyConfig configures the recent observations we keep for export as CSV
+type historyConfig struct {
+	Retention duration `json:"retention" env:"WEATHERFLOW_HISTORY_RETENTION" description:"How long to keep observations for /export.csv, 0 to keep none"`
+	File      string   `json:"file" env:"WEATHERFLOW_HISTORY_FILE" description:"File to persist the history in, so it survives restarts"`
+}
+
+// errorReportConfig configures opt-in error reporting
+type errorReportConfig struct {
+	DSN string `json:"dsn" env:"WEATHERFLOW_ERROR_REPORT_DSN" secret:"true" description:"Sentry DSN to report errors to"`
+	URL string `json:"url" env:"WEATHERFLOW_ERROR_REPORT_URL" description:"Generic endpoint to POST error reports to as JSON"`
+}
+
+// httpSinkConfig configures the templated http sink
+type httpSinkConfig struct {
+	URL          string    `json:"url" env:"WEATHERFLOW_HTTP_SINK_URL" description:"URL to POST each observation and event to"`
+	Template     string    `json:"template" env:"WEATHERFLOW_HTTP_SINK_TEMPLATE" description:"Go template for the request body, defaults to the record as JSON"`
+	TemplateFile string    `json:"template_file" env:"WEATHERFLOW_HTTP_SINK_TEMPLATE_FILE" description:"File to read the body template from instead"`
+	Headers      stringMap `json:"headers" env:"WEATHERFLOW_HTTP_SINK_HEADERS" secret:"true" description:"Request headers like Key=Value,Key=Value, whose values are Go templates"`
+	Timeout      duration  `json:"timeout" env:"WEATHERFLOW_HTTP_SINK_TIMEOUT" description:"Timeout for each request"`
+}
+
+// cwopConfig configures uploads to the Citizen Weather Observer Program
+type cwopConfig struct {
+	Callsign string   `json:"callsign" env:"WEATHERFLOW_CWOP_CALLSIGN" description:"CWOP station ID or amateur radio callsign to upload as, uploads are off if unset"`
+	Passcode int      `json:"passcode" env:"WEATHERFLOW_CWOP_PASSCODE" secret:"true" description:"APRS-IS passcode for a callsign, -1 for CWOP station IDs"`
+	Station  string   `json:"station" env:"WEATHERFLOW_CWOP_STATION" description:"Station to upload, defaults to the only configured station"`
+	Server   string   `json:"server" env:"WEATHERFLOW_CWOP_SERVER" description:"APRS-IS server to upload to as host:port"`
+	Interval duration `json:"interval" env:"WEATHERFLOW_CWOP_INTERVAL" description:"Minimum time between uploads, at least 5m"`
+}
+
+// pwsWeatherConfig configures uploads to PWSWeather
+type pwsWeatherConfig struct {
+	StationID string   `json:"station_id" env:"WEATHERFLOW_PWSWEATHER_STATION_ID" description:"PWSWeather station ID to upload as, uploads are off if unset"`
+	APIKey    string   `json:"api_key" env:"WEATHERFLOW_PWSWEATHER_API_KEY" secret:"true" description:"PWSWeather API key for the station"`
+	Station   string   `json:"station" env:"WEATHERFLOW_PWSWEATHER_STATION" description:"Station to upload, defaults to the only configured station"`
+	Interval  duration `json:"interval" env:"WEATHERFLOW_PWSWEATHER_INTERVAL" description:"Minimum time between uploads, at least 1m"`
+}
+
+// windyConfig configures uploads to Windy
+type windyConfig struct {
+	APIKey       string   `json:"api_key" env:"WEATHERFLOW_WINDY_API_KEY" secret:"true" description:"Windy stations API key, uploads are off if unset"`
+	StationIndex int      `json:"station_index" env:"WEATHERFLOW_WINDY_STATION_INDEX" minimum:"0" description:"Index of the station among those registered with the API key"`
+	Station      string   `json:"station" env:"WEATHERFLOW_WINDY_STATION" description:"Station to upload, defaults to the only configured station"`
+	Interval     duration `json:"interval" env:"WEATHERFLOW_WINDY_INTERVAL" description:"Minimum time between uploads, at least 5m"`
+}
+
+// mqttConfig configures publishing to an MQTT broker
+type mqttConfig struct {
+	Broker             string        `json:"broker" env:"WEATHERFLOW_MQTT_BROKER" description:"MQTT broker to publish to, like tcp://localhost:1883 or tls://host:8883, publishing is off if unset"`
+	Username           string        `json:"username" env:"WEATHERFLOW_MQTT_USERNAME" description:"User name to log in to the broker with"`
+	Password           string        `json:"password" env:"WEATHERFLOW_MQTT_PASSWORD" secret:"true" description:"Password to log in to the broker with"`
+	ClientID           string        `json:"client_id" env:"WEATHERFLOW_MQTT_CLIENT_ID" description:"Client ID to connect to the broker with"`
+	TLS                mqttTLSConfig `json:"tls" description:"TLS settings for tls:// brokers"`
+	QoS                int           `json:"qos" env:"WEATHERFLOW_MQTT_QOS" minimum:"0" maximum:"2" description:"QoS to publish at"`
+	Retain             bool          `json:"retain" env:"WEATHERFLOW_MQTT_RETAIN" description:"Whether observations are published as retained messages"`
+	Publish            string        `json:"publish" env:"WEATHERFLOW_MQTT_PUBLISH" enum:"none,json,fields,both" description:"Whether to publish each observation as JSON, as a topic per reading, or both"`
+	TopicTemplate      string        `json:"topic_template" env:"WEATHERFLOW_MQTT_TOPIC_TEMPLATE" description:"Go template for the topic of each observation as JSON"`
+	FieldTopicTemplate string        `json:"field_topic_template" env:"WEATHERFLOW_MQTT_FIELD_TOPIC_TEMPLATE" description:"Go template for the topic of each reading"`
+	Topic              string        `json:"topic" env:"WEATHERFLOW_MQTT_TOPIC" description:"Prefix of our status and Home Assistant state topics"`
+	HomeAssistant      bool          `json:"home_assistant" env:"WEATHERFLOW_MQTT_HOME_ASSISTANT" description:"Whether to publish Home Assistant discovery configs for each reading"`
+	DiscoveryPrefix    string        `json:"discovery_prefix" env:"WEATHERFLOW_MQTT_DISCOVERY_PREFIX" description:"Home Assistant's discovery topic prefix"`
+}
+
+// mqttTLSConfig configures TLS connections to an MQTT broker
+type mqttTLSConfig struct {
+	CAFile             string `json:"ca_file" env:"WEATHERFLOW_MQTT_TLS_CA_FILE" description:"CA certificates to verify the broker with, instead of the system's"`
+	CertFile           string `json:"cert_file" env:"WEATHERFLOW_MQTT_TLS_CERT_FILE" description:"Client certificate to authenticate to the broker with"`
+	KeyFile            string `json:"key_file" env:"WEATHERFLOW_MQTT_TLS_KEY_FILE" description:"Key of the client certificate"`
+	InsecureSkipVerify bool   `json:"insecure_skip_verify" env:"WEATHERFLOW_MQTT_TLS_INSECURE_SKIP_VERIFY" description:"Whether to skip verifying the broker's certificate"`
+}
+
+// influxConfig configures writing observations to InfluxDB
+type influxConfig struct {
+	URL             string `json:"url" env:"WEATHERFLOW_INFLUXDB_URL" description:"InfluxDB server to write to, like http://localhost:8086, writing is off if unset"`
+	Version         string `json:"version" env:"WEATHERFLOW_INFLUXDB_VERSION" enum:"1,2" description:"Version of the InfluxDB write API to use"`
+	Measurement     string `json:"measurement" env:"WEATHERFLOW_INFLUXDB_MEASUREMENT" description:"Measurement to write observations as"`
+	Database        string `json:"database" env:"WEATHERFLOW_INFLUXDB_DATABASE" description:"Database to write to with the v1 API"`
+	RetentionPolicy string `json:"retention_policy" env:"WEATHERFLOW_INFLUXDB_RETENTION_POLICY" description:"Retention policy to write to with the v1 API, the database's default if unset"`
+	Username        string `json:"username" env:"WEATHERFLOW_INFLUXDB_USERNAME" description:"User name to authenticate to the v1 API with"`
+	Password        string `json:"password" env:"WEATHERFLOW_INFLUXDB_PASSWORD" secret:"true" description:"Password to authenticate to the v1 API with"`
+	Org             string `json:"org" env:"WEATHERFLOW_INFLUXDB_ORG" description:"Organization to write to with the v2 API"`
+	Bucket          string `json:"bucket" env:"WEATHERFLOW_INFLUXDB_BUCKET" description:"Bucket to write to with the v2 API"`
+	Token           string `json:"token" env:"WEATHERFLOW_INFLUXDB_TOKEN" secret:"true" description:"API token to authenticate with"`
+}
+
+// graphiteConfig configures sending observations to a Graphite carbon server
+type graphiteConfig struct {
+	Address      string `json:"address" env:"WEATHERFLOW_GRAPHITE_ADDRESS" description:"Carbon plaintext listener to send to as host:port, like localhost:2003, sending is off if unset"`
+	PathTemplate string `json:"path_template" env:"WEATHERFLOW_GRAPHITE_PATH_TEMPLATE" description:"Go template for the metric path of each reading"`
+}
+
+// statsdConfig configures sending observations to a StatsD or DogStatsD agent
+type statsdConfig struct {
+	Address string    `json:"address" env:"WEATHERFLOW_STATSD_ADDRESS" description:"StatsD agent to send to over UDP as host:port, like localhost:8125, sending is off if unset"`
+	Flavor  string    `json:"flavor" env:"WEATHERFLOW_STATSD_FLAVOR" enum:"statsd,dogstatsd" description:"Whether to send plain StatsD, with the station in each name, or DogStatsD with the station's labels as tags"`
+	Prefix  string    `json:"prefix" env:"WEATHERFLOW_STATSD_PREFIX" description:"Prefix of each gauge's name"`
+	Tags    stringMap `json:"tags" env:"WEATHERFLOW_STATSD_TAGS" description:"Extra DogStatsD tags like key=value,key=value"`
+}
+
+// remoteWriteConfig configures pushing our weather metrics with the Prometheus
+// remote write protocol
+type remoteWriteConfig struct {
+	URL         string    `json:"url" env:"WEATHERFLOW_REMOTE_WRITE_URL" description:"Remote write endpoint to push to, pushing is off if unset"`
+	Interval    duration  `json:"interval" env:"WEATHERFLOW_REMOTE_WRITE_INTERVAL" description:"How often to push"`
+	Timeout     duration  `json:"timeout" env:"WEATHERFLOW_REMOTE_WRITE_TIMEOUT" description:"Timeout for each push"`
+	Username    string    `json:"username" env:"WEATHERFLOW_REMOTE_WRITE_USERNAME" description:"User name to authenticate to the endpoint with"`
+	Password    string    `json:"password" env:"WEATHERFLOW_REMOTE_WRITE_PASSWORD" secret:"true" description:"Password to authenticate to the endpoint with"`
+	BearerToken string    `json:"bearer_token" env:"WEATHERFLOW_REMOTE_WRITE_BEARER_TOKEN" secret:"true" description:"Bearer token to authenticate to the endpoint with"`
+	Headers     stringMap `json:"headers" env:"WEATHERFLOW_REMOTE_WRITE_HEADERS" secret:"true" description:"Extra request headers like Key=Value,Key=Value, e.g. X-Scope-OrgID for Mimir"`
+}
+
+// pushgatewayConfig configures pushing our weather metrics to a Pushgateway
+type pushgatewayConfig struct {
+	URL      string    `json:"url" env:"WEATHERFLOW_PUSHGATEWAY_URL" description:"Pushgateway to push to after each poll, pushing is off if unset"`
+	Job      string    `json:"job" env:"WEATHERFLOW_PUSHGATEWAY_JOB" description:"Job label of the group we push"`
+	Grouping stringMap `json:"grouping" env:"WEATHERFLOW_PUSHGATEWAY_GROUPING" description:"Further grouping labels of the group we push, like instance=garage"`
+	Username string    `json:"username" env:"WEATHERFLOW_PUSHGATEWAY_USERNAME" description:"User name to authenticate to the Pushgateway with"`
+	Password string    `json:"password" env:"WEATHERFLOW_PUSHGATEWAY_PASSWORD" secret:"true" description:"Password to authenticate to the Pushgateway with"`
+}
+
+// otlpConfig configures exporting our weather metrics over OTLP
+type otlpConfig struct {
+	Endpoint string    `json:"endpoint" env:"WEATHERFLOW_OTLP_ENDPOINT" description:"OTLP endpoint to export to, like http://localhost:4318, exporting is off if unset"`
+	Protocol string    `json:"protocol" env:"WEATHERFLOW_OTLP_PROTOCOL" enum:"grpc,http/protobuf" description:"Whether to export over gRPC or HTTP"`
+	Headers  stringMap `json:"headers" env:"WEATHERFLOW_OTLP_HEADERS" secret:"true" description:"Extra request headers like Key=Value,Key=Value"`
+	Interval duration  `json:"interval" env:"WEATHERFLOW_OTLP_INTERVAL" description:"How often to export"`
+	Timeout  duration  `json:"timeout" env:"WEATHERFLOW_OTLP_TIMEOUT" description:"Timeout for each export"`
+}
+
+// duration is a time.Duration that is configured as a string like "10m"
+type duration time.Duration
+
+// UnmarshalText parses a duration string
+func (d *duration) UnmarshalText(b []byte) error {
+	v, err := time.ParseDuration(string(b))
+	if err != nil {
+		return err
+	}
+	*d = duration(v)
+	return nil
+}
+
+// MarshalText formats a duration as a string
+func (d duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// floatMap is a map of numbers configured as "key=value,key=value"
+type floatMap map[string]float64
+
+// UnmarshalText parses a floatMap, adding to any values already set
+func (m *floatMap) UnmarshalText(b []byte) error {
+	if *m == nil {
+		*m = make(floatMap)
+	}
+	for _, item := range strings.Split(string(b), ",") {
+		kv := strings.SplitN(strings.TrimSpace(item), "=", 2)
+		if len(kv) != 2 {
+			return fmt.Errorf("invalid value %q, expected key=value", item)
+		}
+		v, err := strconv.ParseFloat(kv[1], 64)
+		if err != nil {
+			return fmt.Errorf("invalid value for %s: %v", kv[0], err)
+		}
+		(*m)[kv[0]] = v
+	}
+	return nil
+}
+
+// UnmarshalJSON parses a floatMap from a string or an object
+func (m *floatMap) UnmarshalJSON(b []byte) error {
+	return unmarshalJSONOrText(b, m, (*map[string]float64)(m))
+}
+
+// stringMap is a map of strings configured as "key=value,key=value"
+type stringMap map[string]string
+
+// UnmarshalText parses a stringMap, adding to any values already set
+func (m *stringMap) UnmarshalText(b []byte) error {
+	if *m == nil {
+		*m = make(stringMap)
+	}
+	for _, item := range strings.Split(string(b), ",") {
+		kv := strings.SplitN(strings.TrimSpace(item), "=", 2)
+		if len(kv) != 2 {
+			return fmt.Errorf("invalid value %q, expected key=value", item)
+		}
+		(*m)[kv[0]] = kv[1]
+	}
+	return nil
+}
+
+// UnmarshalJSON parses a stringMap from a string or an object
+func (m *stringMap) UnmarshalJSON(b []byte) error {
+	return unmarshalJSONOrText(b, m, (*map[string]string)(m))
+}
+
+// durationMap is a map of durations configured as "key=10m,key=1h"
+type durationMap map[string]duration
+
+// UnmarshalText parses a durationMap, adding to any values already set
+func (m *durationMap) UnmarshalText(b []byte) error {
+	if *m == nil {
+		*m = make(durationMap)
+	}
+	for _, item := range strings.Split(string(b), ",") {
+		kv := strings.SplitN(strings.TrimSpace(item), "=", 2)
+		if len(kv) != 2 {
+			return fmt.Errorf("invalid value %q, expected key=duration", item)
+		}
+		var d duration
+		if err := d.UnmarshalText([]byte(kv[1])); err != nil {
+			return fmt.Errorf("invalid value for %s: %v", kv[0], err)
+		}
+		(*m)[kv[0]] = d
+	}
+	return nil
+}
+
+// UnmarshalJSON parses a durationMap from a string or an object
+func (m *durationMap) UnmarshalJSON(b []byte) error {
+	return unmarshalJSONOrText(b, m, (*map[string]duration)(m))
+}
+
+// unmarshalJSONOrText parses a JSON string with u's UnmarshalText, so config
+// files can use the same format as environment variables, or any other JSON
+// value into v
+func unmarshalJSONOrText(b []byte, u encoding.TextUnmarshaler, v interface{}) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		return u.UnmarshalText([]byte(s))
+	}
+	return json.Unmarshal(b, v)
+}
+
+var (
+	// cfg is the active configuration
+	cfg config
+	// configFile is the YAML config file to load, if any
+	configFile string
+	// flagValues holds the settings given as command line flags, keyed by
+	// flag name
+	flagValues = make(map[string]string)
+)
+
+// defaultConfig returns a config with our defaults
+func defaultConfig() config {
+	return config{
+		ListenAddresses:   []string{"localhost:6969"},
+		TelemetryPath:     "/metrics",
+		PollInterval:      duration(15 * time.Second),
+		DiscoveryInterval: duration(time.Hour),
+		API: apiConfig{
+			Timeout:       duration(10 * time.Second),
+			Retries:       2,
+			RetryBackoff:  duration(time.Second),
+			TLSMinVersion: "1.2",
+		},
+		Log:              logConfig{Level: "info", Format: "text", Access: "common", MaxSizeMB: 100, MaxBackups: 7},
+		StationLabels:    "all",
+		Units:            "metric",
+		MetricNames:      "legacy",
+		Source:           "api",
+		Collection:       "poll",
+		ScrapeCacheTTL:   duration(10 * time.Second),
+		UDP:              udpConfig{Listen: ":50222", Timezone: "Local"},
+		BoundsMode:       "drop",
+		AnomalyWindow:    15,
+		AnomalyThreshold: 5,
+		DaylightTwilight: "none",
+		Histograms:       "none",
+		OfflineAfter:     duration(10 * time.Minute),
+		Proxy:            proxyConfig{TTL: duration(time.Minute)},
+		Collectors:       collectorsConfig{Go: true, Process: true},
+		DegreeDays:       degreeDaysConfig{GrowingBase: 10, Base: 18, SeasonStart: "01-01"},
+		Devices:          devicesConfig{Enabled: true, Interval: duration(5 * time.Minute)},
+		Battery: batteryConfig{
+			LowVoltage:  floatMap{"ST": 2.39, "AR": 3.0, "SK": 3.0},
+			Hysteresis:  0.05,
+			TrendWindow: duration(time.Hour),
+		},
+		Forecast: forecastConfig{
+			Interval:        duration(30 * time.Minute),
+			RainProbability: 50,
+			Hours:           12,
+			Days:            3,
+		},
+		HTTPSink: httpSinkConfig{Timeout: duration(10 * time.Second)},
+		CWOP: cwopConfig{
+			Passcode: -1,
+			Server:   "cwop.aprs.net:14580",
+			Interval: duration(10 * time.Minute),
+		},
+		PWSWeather:  pwsWeatherConfig{Interval: duration(5 * time.Minute)},
+		Windy:       windyConfig{Interval: duration(5 * time.Minute)},
+		InfluxDB:    influxConfig{Version: "2", Measurement: "tempest"},
+		Pushgateway: pushgatewayConfig{Job: "tempest"},
+		History:     historyConfig{Retention: duration(24 * time.Hour)},
+		Graphite:    graphiteConfig{PathTemplate: "tempest.{{.StationID}}.{{.Field}}"},
+		StatsD:      statsdConfig{Flavor: "dogstatsd", Prefix: "tempest"},
+		OTLP: otlpConfig{
+			Protocol: "http/protobuf",
+			Interval: duration(time.Minute),
+			Timeout:  duration(30 * time.Second),
+		},
+		RemoteWrite: remoteWriteConfig{
+			Interval: duration(time.Minute),
+			Timeout:  duration(30 * time.Second),
+		},
+		MQTT: mqttConfig{
+			ClientID:           "tempest-exporter",
+			Publish:            "json",
+			TopicTemplate:      "tempest/{{.StationID}}/observation",
+			FieldTopicTemplate: "tempest/{{.StationID}}/{{.Field}}",
+			Topic:              "tempest",
+			HomeAssistant:      true,
+			DiscoveryPrefix:    "homeassistant",
+		},
+	}
+}
+
+// loadConfig returns our defaults overridden by our config file, if any, and
+// then by any environment variables set. In low memory mode these are applied
+// over our low memory defaults.
+func loadConfig() (config, error) {
+	c := defaultConfig()
+	if err := c.load(); err != nil {
+		return c, err
+	}
+	if c.LowMemory {
+		c = defaultConfig()
+		lowMemoryDefaults(&c)
+		if err := c.load(); err != nil {
+			return c, err
+		}
+	}
+	return c, c.validate()
+}
+
+// load applies our config file, environment variables and flags to c
+func (c *config) load() error {
+	if configFile != "" {
+		if err := loadFile(c, configFile); err != nil {
+			return err
+		}
+	}
+	if err := loadEnv(reflect.ValueOf(c).Elem()); err != nil {
+		return err
+	}
+	return loadFlags(reflect.ValueOf(c).Elem())
+}
+
+// registerFlags adds a flag to fs for each config setting with a flag tag,
+// along with the config file flags
+func registerFlags(fs *flag.FlagSet) {
+	usage := "YAML config file to load (env WEATHERFLOW_CONFIG_FILE)"
+	fs.StringVar(&configFile, "config.file", os.Getenv("WEATHERFLOW_CONFIG_FILE"), usage)
+	fs.StringVar(&configFile, "config", os.Getenv("WEATHERFLOW_CONFIG_FILE"), usage)
+	registerFieldFlags(fs, reflect.TypeOf(config{}))
+}
+
+// registerFieldFlags adds a flag to fs for each field of the struct t with a
+// flag tag. Values are checked as they're parsed and applied by loadFlags.
+func registerFieldFlags(fs *flag.FlagSet, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := f.Tag.Get("flag")
+		if name == "" {
+			if f.Type.Kind() == reflect.Struct {
+				registerFieldFlags(fs, f.Type)
+			}
+			continue
+		}
+		usage := fmt.Sprintf("%s (env %s)", f.Tag.Get("description"), f.Tag.Get("env"))
+		fs.Var(fieldFlag{name: name, typ: f.Type}, name, usage)
+	}
+}
+
+// fieldFlag is a command line flag for a config setting
+type fieldFlag struct {
+	name string
+	typ  reflect.Type
+}
+
+// String implements flag.Value
+func (f fieldFlag) String() string {
+	return flagValues[f.name]
+}
+
+// Set implements flag.Value, checking s parses as the setting's type. List
+// settings can be given several times, adding to the list.
+func (f fieldFlag) Set(s string) error {
+	if err := setField(reflect.New(f.typ).Elem(), s); err != nil {
+		return err
+	}
+	if prev, ok := flagValues[f.name]; ok && f.typ.Kind() == reflect.Slice {
+		s = prev + "," + s
+	}
+	flagValues[f.name] = s
+	return nil
+}
+
+// IsBoolFlag lets boolean settings be given without a value
+func (f fieldFlag) IsBoolFlag() bool {
+	return f.typ != nil && f.typ.Kind() == reflect.Bool
+}
+
+// loadFlags sets each field of the struct v from the command line flag named
+// in its flag tag, if it was given
+func loadFlags(v reflect.Value) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f, fv := t.Field(i), v.Field(i)
+		name := f.Tag.Get("flag")
+		if name == "" {
+			if f.Type.Kind() == reflect.Struct {
+				if err := loadFlags(fv); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		s, ok := flagValues[name]
+		if !ok {
+			continue
+		}
+		if err := setField(fv, s); err != nil {
+			return fmt.Errorf("invalid --%s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// loadFile applies the settings in a YAML config file to c. The YAML is
+// converted to JSON so settings are named by our json tags.
+func loadFile(c *config, path string) error {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("error reading config file: %v", err)
+	}
+	var v interface{}
+	if err := yaml.Unmarshal(b, &v); err != nil {
+		return fmt.Errorf("error parsing config file %s: %v", path, err)
+	}
+	if v == nil {
+		return nil
+	}
+	j, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("error parsing config file %s: %v", path, err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(j))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(c); err != nil {
+		return fmt.Errorf("invalid config file %s: %v", path, err)
+	}
+	return nil
+}
+
+// loadEnv sets each field of the struct v from the environment variable named
+// in its env tag, if it's set
+func loadEnv(v reflect.Value) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f, fv := t.Field(i), v.Field(i)
+		name := f.Tag.Get("env")
+		if name == "" {
+			if f.Type.Kind() == reflect.Struct {
+				if err := loadEnv(fv); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		s := os.Getenv(name)
+		if s == "" {
+			continue
+		}
+		if err := setField(fv, s); err != nil {
+			return fmt.Errorf("invalid %s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// redactSecrets clears the fields of the struct v that are tagged as secrets
+func redactSecrets(v reflect.Value) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f, fv := t.Field(i), v.Field(i)
+		if f.Tag.Get("secret") == "true" {
+			fv.Set(reflect.Zero(f.Type))
+		} else if f.Type.Kind() == reflect.Struct {
+			redactSecrets(fv)
+		}
+	}
+}
+
+// setField parses s into the config field v
+func setField(v reflect.Value, s string) error {
+	if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(s))
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Int:
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(n))
+	case reflect.Float64:
+		n, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(n)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Slice:
+		var items []string
+		for _, item := range strings.Split(s, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported config type %s", v.Type())
+	}
+	return nil
+}
+
+// checkTags validates the fields of the struct v against the enum, minimum
+// and maximum constraints in their tags
+func checkTags(v reflect.Value) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f, fv := t.Field(i), v.Field(i)
+		name := f.Tag.Get("json")
+		if f.Type.Kind() == reflect.Struct {
+			if err := checkTags(fv); err != nil {
+				return fmt.Errorf("%s.%v", name, err)
+			}
+			continue
+		}
+		if enum, ok := f.Tag.Lookup("enum"); ok && !contains(strings.Split(enum, ","), fv.String()) {
+			return fmt.Errorf("%s: invalid value %q, expected one of %s", name, fv.String(), strings.Trim(enum, ","))
+		}
+		var n float64
+		switch fv.Kind() {
+		case reflect.Int:
+			n = float64(fv.Int())
+		case reflect.Float64:
+			n = fv.Float()
+		default:
+			continue
+		}
+		if min, ok := f.Tag.Lookup("minimum"); ok {
+			if m, _ := strconv.ParseFloat(min, 64); n < m {
+				return fmt.Errorf("%s: %v is less than the minimum of %s", name, n, min)
+			}
+		}
+		if max, ok := f.Tag.Lookup("maximum"); ok {
+			if m, _ := strconv.ParseFloat(max, 64); n > m {
+				return fmt.Errorf("%s: %v is more than the maximum of %s", name, n, max)
+			}
+		}
+	}
+	return nil
+}
+
+// validate checks the config is usable
+func (c *config) validate() error {
+	if err := checkTags(reflect.ValueOf(c).Elem()); err != nil {
+		return err
+	}
+	if c.TokenFile != "" {
+		if c.Token != "" {
+			return fmt.Errorf("please set only one of WEATHERFLOW_API_TOKEN and WEATHERFLOW_API_TOKEN_FILE")
+		}
+		b, err := ioutil.ReadFile(c.TokenFile)
+		if err != nil {
+			return fmt.Errorf("error reading token file: %v", err)
+		}
+		c.Token = strings.TrimSpace(string(b))
+	}
+	if c.Source == "api" && c.Token == "" {
+		return fmt.Errorf("please set WEATHERFLOW_API_TOKEN or WEATHERFLOW_API_TOKEN_FILE")
+	}
+	for _, p := range c.StationPairs {
+		if !contains(c.Stations, p.A) || !contains(c.Stations, p.B) {
+			return fmt.Errorf("station pair %s:%s must only use configured stations", p.A, p.B)
+		}
+	}
+	var r response
+	known := r.allLabels()
+	for _, l := range append(append([]string{}, c.Labels.Allow...), c.Labels.Deny...) {
+		if _, ok := known[l]; !ok {
+			return fmt.Errorf("unknown station label %q", l)
+		}
+	}
+	if contains(c.Labels.Deny, "station_id") {
+		return fmt.Errorf("the station_id label can't be denied")
+	}
+	if err := checkStaticLabels(c.StaticLabels); err != nil {
+		return err
+	}
+	for _, labels := range c.StationStaticLabels {
+		if err := checkStaticLabels(labels); err != nil {
+			return err
+		}
+	}
+	if err := c.Relabel.compile(); err != nil {
+		return err
+	}
+	if c.CWOP.Callsign != "" {
+		if err := c.checkUploader("cwop", &c.CWOP.Station, c.CWOP.Interval, 5*time.Minute); err != nil {
+			return err
+		}
+		if _, _, err := net.SplitHostPort(c.CWOP.Server); err != nil {
+			return fmt.Errorf("invalid cwop server %q: %v", c.CWOP.Server, err)
+		}
+	}
+	if c.PWSWeather.StationID != "" {
+		if c.PWSWeather.APIKey == "" {
+			return fmt.Errorf("please set WEATHERFLOW_PWSWEATHER_API_KEY")
+		}
+		if err := c.checkUploader("pwsweather", &c.PWSWeather.Station, c.PWSWeather.Interval, time.Minute); err != nil {
+			return err
+		}
+	}
+	if c.Windy.APIKey != "" {
+		if err := c.checkUploader("windy", &c.Windy.Station, c.Windy.Interval, 5*time.Minute); err != nil {
+			return err
+		}
+	}
+	if c.MQTT.Broker != "" {
+		u, err := url.Parse(c.MQTT.Broker)
+		if err != nil || u.Host == "" {
+			return fmt.Errorf("invalid mqtt broker %q, expected a URL like tcp://localhost:1883", c.MQTT.Broker)
+		}
+		if c.MQTT.Topic == "" || strings.ContainsAny(c.MQTT.Topic, "+#") {
+			return fmt.Errorf("invalid mqtt topic %q", c.MQTT.Topic)
+		}
+		if (c.MQTT.TLS.CertFile == "") != (c.MQTT.TLS.KeyFile == "") {
+			return fmt.Errorf("mqtt tls needs both a cert_file and a key_file")
+		}
+		// MQTT 3.1.1 only allows a password along with a username
+		if c.MQTT.Password != "" && c.MQTT.Username == "" {
+			return fmt.Errorf("mqtt password needs a username")
+		}
+	}
+	if c.InfluxDB.URL != "" {
+		if c.InfluxDB.Version == "2" && (c.InfluxDB.Org == "" || c.InfluxDB.Bucket == "") {
+			return fmt.Errorf("please set WEATHERFLOW_INFLUXDB_ORG and WEATHERFLOW_INFLUXDB_BUCKET")
+		}
+		if c.InfluxDB.Version == "1" && c.InfluxDB.Database == "" {
+			return fmt.Errorf("please set WEATHERFLOW_INFLUXDB_DATABASE")
+		}
+	}
+	if c.Graphite.Address != "" {
+		if _, _, err := net.SplitHostPort(c.Graphite.Address); err != nil {
+			return fmt.Errorf("invalid graphite address %q, expected host:port like localhost:2003", c.Graphite.Address)
+		}
+	}
+	if c.StatsD.Address != "" {
+		if _, _, err := net.SplitHostPort(c.StatsD.Address); err != nil {
+			return fmt.Errorf("invalid statsd address %q, expected host:port like localhost:8125", c.StatsD.Address)
+		}
+		if c.StatsD.Prefix == "" || strings.ContainsAny(c.StatsD.Prefix, ":|@#") {
+			return fmt.Errorf("invalid statsd prefix %q", c.StatsD.Prefix)
+		}
+	}
+	if c.RemoteWrite.URL != "" && c.RemoteWrite.Interval < duration(time.Second) {
+		return fmt.Errorf("remote_write interval must be at least 1s")
+	}
+	if c.OTLP.Endpoint != "" {
+		u, err := url.Parse(c.OTLP.Endpoint)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid otlp endpoint %q, expected a URL like http://localhost:4318", c.OTLP.Endpoint)
+		}
+		if c.OTLP.Interval < duration(time.Second) {
+			return fmt.Errorf("otlp interval must be at least 1s")
+		}
+	}
+	if c.Pushgateway.URL != "" {
+		if c.Collection == "scrape" && c.Source != "udp" {
+			return fmt.Errorf("the pushgateway needs polling, as metrics are pushed after each poll")
+		}
+		if c.Pushgateway.Job == "" {
+			return fmt.Errorf("please set WEATHERFLOW_PUSHGATEWAY_JOB")
+		}
+	}
+	if c.History.Retention < 0 {
+		return fmt.Errorf("history retention can't be negative")
+	}
+	if c.AnomalyThreshold <= 0 {
+		return fmt.Errorf("anomaly_threshold must be positive")
+	}
+	if (c.BasicAuth.Username == "") != (c.BasicAuth.PasswordHash == "") {
+		return fmt.Errorf("basic auth needs both a username and a password hash")
+	}
+	if c.BasicAuth.PasswordHash != "" {
+		if _, err := bcrypt.Cost([]byte(c.BasicAuth.PasswordHash)); err != nil {
+			return fmt.Errorf("invalid basic auth password hash: %v", err)
+		}
+	}
+	if len(c.ListenAddresses) == 0 {
+		return fmt.Errorf("please set at least one listen address")
+	}
+	for _, addr := range c.ListenAddresses {
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			return fmt.Errorf("invalid listen address %q: %v", addr, err)
+		}
+	}
+	if !strings.HasPrefix(c.TelemetryPath, "/") {
+		return fmt.Errorf("telemetry_path must start with /")
+	}
+	if reservedPaths[c.TelemetryPath] || strings.HasPrefix(c.TelemetryPath, pprofPath) || strings.HasPrefix(c.TelemetryPath, proxyPath) || strings.HasPrefix(c.TelemetryPath, apiPath) {
+		return fmt.Errorf("telemetry_path %s is already used by the exporter", c.TelemetryPath)
+	}
+	if c.Log.File != "" && c.Log.Syslog != "" {
+		return fmt.Errorf("please set only one of WEATHERFLOW_LOG_FILE and WEATHERFLOW_LOG_SYSLOG")
+	}
+	if _, err := time.Parse("01-02", c.DegreeDays.SeasonStart); err != nil {
+		return fmt.Errorf("invalid degree days season_start %q, use month-day like 04-01", c.DegreeDays.SeasonStart)
+	}
+	if c.PollInterval < duration(time.Second) {
+		return fmt.Errorf("poll_interval must be at least 1s")
+	}
+	if c.API.Timeout <= 0 {
+		return fmt.Errorf("api timeout must be positive")
+	}
+	if c.API.RetryBackoff < 0 {
+		return fmt.Errorf("api retry_backoff must not be negative")
+	}
+	if c.OfflineAfter <= 0 {
+		return fmt.Errorf("offline_after must be positive")
+	}
+	for _, w := range c.Notify.QuietHours {
+		if _, _, err := parseWindow(w); err != nil {
+			return err
+		}
+	}
+	if c.AirQuality.Source != "" {
+		if c.AirQuality.URL == "" {
+			return fmt.Errorf("please set WEATHERFLOW_AQ_URL")
+		}
+		if c.AirQuality.StationID == "" && len(c.Stations) == 0 {
+			return fmt.Errorf("please set WEATHERFLOW_AQ_STATION_ID when stations are discovered")
+		}
+		if c.AirQuality.StationID == "" {
+			c.AirQuality.StationID = c.Stations[0]
+		}
+	}
+	return nil
+}
+
+// checkUploader checks the settings shared by our weather network uploaders,
+// defaulting the station to upload to our only configured station
+func (c *config) checkUploader(name string, station *string, interval duration, min time.Duration) error {
+	if *station == "" && len(c.Stations) == 1 {
+		*station = c.Stations[0]
+	}
+	if *station == "" {
+		return fmt.Errorf("please set WEATHERFLOW_%s_STATION to the station to upload to %s", strings.ToUpper(name), name)
+	}
+	if time.Duration(interval) < min {
+		return fmt.Errorf("%s interval must be at least %v", name, min)
+	}
+	return nil
+}
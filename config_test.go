@@ -0,0 +1,204 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSetField(t *testing.T) {
+	var c struct {
+		S string
+		I int
+		F float64
+		B bool
+		L []string
+		D duration
+	}
+	v := reflect.ValueOf(&c).Elem()
+	tests := []struct {
+		field, s string
+		want     interface{}
+		err      bool
+	}{
+		{field: "S", s: "tempest", want: "tempest"},
+		{field: "I", s: "42", want: 42},
+		{field: "I", s: "4.2", err: true},
+		{field: "F", s: "4.2", want: 4.2},
+		{field: "B", s: "true", want: true},
+		{field: "B", s: "yes", err: true},
+		{field: "L", s: "a, b,,c", want: []string{"a", "b", "c"}},
+		{field: "D", s: "90s", want: duration(90 * time.Second)},
+		{field: "D", s: "90", err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.field+"="+tt.s, func(t *testing.T) {
+			fv := v.FieldByName(tt.field)
+			err := setField(fv, tt.s)
+			if (err != nil) != tt.err {
+				t.Fatalf("setField() error = %v, want error %v", err, tt.err)
+			}
+			if !tt.err && !reflect.DeepEqual(fv.Interface(), tt.want) {
+				t.Errorf("setField() set %v, want %v", fv.Interface(), tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckTags(t *testing.T) {
+	type nested struct {
+		Mode  string  `json:"mode" enum:"a,b"`
+		Count int     `json:"count" minimum:"1" maximum:"10"`
+		Ratio float64 `json:"ratio" minimum:"0"`
+	}
+	tests := []struct {
+		name string
+		v    nested
+		err  string
+	}{
+		{name: "valid", v: nested{Mode: "a", Count: 1}},
+		{name: "not in enum", v: nested{Mode: "c", Count: 1}, err: `outer.mode: invalid value "c", expected one of a,b`},
+		{name: "below minimum", v: nested{Mode: "b", Count: 0}, err: "outer.count: 0 is less than the minimum of 1"},
+		{name: "above maximum", v: nested{Mode: "b", Count: 11}, err: "outer.count: 11 is more than the maximum of 10"},
+		{name: "negative float", v: nested{Mode: "b", Count: 1, Ratio: -0.5}, err: "outer.ratio: -0.5 is less than the minimum of 0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := struct {
+				Outer nested `json:"outer"`
+			}{tt.v}
+			var got string
+			if err := checkTags(reflect.ValueOf(c)); err != nil {
+				got = err.Error()
+			}
+			if got != tt.err {
+				t.Errorf("checkTags() error = %q, want %q", got, tt.err)
+			}
+		})
+	}
+}
+
+func TestFloatMapUnmarshalText(t *testing.T) {
+	tests := []struct {
+		s    string
+		want floatMap
+		err  bool
+	}{
+		{s: "ST=2.355,AR=3.5", want: floatMap{"ST": 2.355, "AR": 3.5}},
+		{s: " ST=2.4 , SK=3", want: floatMap{"ST": 2.4, "SK": 3}},
+		{s: "ST", err: true},
+		{s: "ST=low", err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			var m floatMap
+			err := m.UnmarshalText([]byte(tt.s))
+			if (err != nil) != tt.err {
+				t.Fatalf("UnmarshalText() error = %v, want error %v", err, tt.err)
+			}
+			if !tt.err && !reflect.DeepEqual(m, tt.want) {
+				t.Errorf("UnmarshalText() = %v, want %v", m, tt.want)
+			}
+		})
+	}
+	// values add to those already set
+	m := floatMap{"ST": 2.355}
+	if err := m.UnmarshalText([]byte("AR=3.5")); err != nil || len(m) != 2 {
+		t.Errorf("UnmarshalText() = %v, %v, want both keys", m, err)
+	}
+}
+
+func TestDurationMapUnmarshalText(t *testing.T) {
+	tests := []struct {
+		s    string
+		want durationMap
+		err  bool
+	}{
+		{s: "offline=1h,online=10m", want: durationMap{"offline": duration(time.Hour), "online": duration(10 * time.Minute)}},
+		{s: "offline", err: true},
+		{s: "offline=60", err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			var m durationMap
+			err := m.UnmarshalText([]byte(tt.s))
+			if (err != nil) != tt.err {
+				t.Fatalf("UnmarshalText() error = %v, want error %v", err, tt.err)
+			}
+			if !tt.err && !reflect.DeepEqual(m, tt.want) {
+				t.Errorf("UnmarshalText() = %v, want %v", m, tt.want)
+			}
+		})
+	}
+}
+
+func TestStringMapUnmarshalText(t *testing.T) {
+	tests := []struct {
+		s    string
+		want stringMap
+		err  bool
+	}{
+		{s: "Authorization=Bearer abc,X-Env=home", want: stringMap{"Authorization": "Bearer abc", "X-Env": "home"}},
+		{s: "query=a=b", want: stringMap{"query": "a=b"}},
+		{s: "empty=", want: stringMap{"empty": ""}},
+		{s: "Authorization", err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			var m stringMap
+			err := m.UnmarshalText([]byte(tt.s))
+			if (err != nil) != tt.err {
+				t.Fatalf("UnmarshalText() error = %v, want error %v", err, tt.err)
+			}
+			if !tt.err && !reflect.DeepEqual(m, tt.want) {
+				t.Errorf("UnmarshalText() = %v, want %v", m, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	tests := []struct {
+		name, yaml string
+		check      func(c config) bool
+		err        bool
+	}{
+		{
+			name: "maps as strings",
+			yaml: "static_labels: site=cabin,region=north\noffline_after: 20m\n",
+			check: func(c config) bool {
+				return reflect.DeepEqual(c.StaticLabels, stringMap{"site": "cabin", "region": "north"}) &&
+					c.OfflineAfter == duration(20*time.Minute)
+			},
+		},
+		{
+			name: "maps as objects",
+			yaml: "static_labels:\n  site: cabin\nnotify:\n  cooldown:\n    offline: 30m\n",
+			check: func(c config) bool {
+				return reflect.DeepEqual(c.StaticLabels, stringMap{"site": "cabin"}) &&
+					c.Notify.Cooldown["offline"] == duration(30*time.Minute)
+			},
+		},
+		{name: "empty", yaml: "", check: func(c config) bool { return c.Source == "api" }},
+		{name: "unknown setting", yaml: "sauce: api\n", err: true},
+		{name: "invalid duration", yaml: "offline_after: soon\n", err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			if err := os.WriteFile(path, []byte(tt.yaml), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			c := defaultConfig()
+			err := loadFile(&c, path)
+			if (err != nil) != tt.err {
+				t.Fatalf("loadFile() error = %v, want error %v", err, tt.err)
+			}
+			if !tt.err && !tt.check(c) {
+				t.Errorf("loadFile() = %+v", c)
+			}
+		})
+	}
+}
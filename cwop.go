@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"net"
+	"strings"
+	"time"
+)
+
+// cwopSink uploads observations to the Citizen Weather Observer Program
+// through APRS-IS, for them to reach MADIS
+type cwopSink struct {
+	c cwopConfig
+}
+
+// newCWOPSink creates a CWOP sink
+func newCWOPSink(c cwopConfig) *cwopSink {
+	return &cwopSink{c: c}
+}
+
+// name implements sink
+func (s *cwopSink) name() string {
+	return "cwop"
+}
+
+// aprsPosition formats a latitude and longitude in degrees as an APRS
+// position, in degrees and hundredths of minutes, with the weather symbol
+func aprsPosition(lat, lon float64) string {
+	format := func(v float64, width int, pos, neg byte) string {
+		hemisphere := pos
+		if v < 0 {
+			hemisphere = neg
+		}
+		m := int(math.Round(math.Abs(v) * 6000))
+		return fmt.Sprintf("%0*d%05.2f%c", width, m/6000, float64(m%6000)/100, hemisphere)
+	}
+	return format(lat, 2, 'N', 'S') + "/" + format(lon, 3, 'E', 'W') + "_"
+}
+
+// aprsWeather formats an observation's readings as an APRS weather report,
+// with missing wind and temperature readings as dots as the format requires
+func aprsWeather(values map[string]float64, elevation float64) string {
+	field := func(prefix, name string, convert func(float64) float64) string {
+		v, ok := values[name]
+		if !ok {
+			return prefix + "..."
+		}
+		return fmt.Sprintf("%s%03d", prefix, int(math.Round(convert(v))))
+	}
+	none := func(v float64) float64 { return v }
+	hundredths := func(mm float64) float64 { return mm / 0.254 }
+	var b strings.Builder
+	b.WriteString(field("", "wind_direction", none))
+	b.WriteString(field("/", "wind_avg", mph.convert))
+	b.WriteString(field("g", "wind_gust", mph.convert))
+	b.WriteString(field("t", "air_temperature", cToF))
+	if _, ok := values["precip_accum_last_1hr"]; ok {
+		b.WriteString(field("r", "precip_accum_last_1hr", hundredths))
+	}
+	if _, ok := values["precip_accum_local_day"]; ok {
+		b.WriteString(field("P", "precip_accum_local_day", hundredths))
+	}
+	if rh, ok := values["relative_humidity"]; ok {
+		b.WriteString(fmt.Sprintf("h%02d", int(math.Round(rh))%100))
+	}
+	if p, ok := values["station_pressure"]; ok && p > 0 {
+		b.WriteString(fmt.Sprintf("b%05d", int(math.Round(altimeterSetting(p, elevation)*10))))
+	}
+	if sr, ok := values["solar_radiation"]; ok {
+		if l := int(math.Round(sr)); l < 1000 {
+			b.WriteString(fmt.Sprintf("L%03d", l))
+		} else {
+			b.WriteString(fmt.Sprintf("l%03d", l-1000))
+		}
+	}
+	return b.String()
+}
+
+// packet returns the APRS packet reporting an observation from a station
+func (s *cwopSink) packet(rec sinkRecord, r response) string {
+	return fmt.Sprintf("%s>APRS,TCPIP*:@%sz%s%stempest-exporter",
+		s.c.Callsign,
+		rec.Time.UTC().Format("021504"),
+		aprsPosition(r.Latitude, r.Longitude),
+		aprsWeather(rec.Values, r.Elevation),
+	)
+}
+
+// send implements sink
+func (s *cwopSink) send(rec sinkRecord) error {
+	metricsMu.RLock()
+	r := stationResponses[rec.StationID]
+	metricsMu.RUnlock()
+	if r.Latitude == 0 && r.Longitude == 0 {
+		return fmt.Errorf("error uploading to cwop: no location for station %s", rec.StationID)
+	}
+	if err := s.upload(s.packet(rec, r)); err != nil {
+		return fmt.Errorf("error uploading to cwop: %v", err)
+	}
+	return nil
+}
+
+// upload logs in to an APRS-IS server and sends it a packet
+func (s *cwopSink) upload(packet string) error {
+	conn, err := net.DialTimeout("tcp", s.c.Server, uploadTimeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(uploadTimeout))
+	rd := bufio.NewReader(conn)
+	// the server greets us with a comment line before we log in
+	if _, err := rd.ReadString('\n'); err != nil {
+		return fmt.Errorf("error reading server banner: %v", err)
+	}
+	if _, err := fmt.Fprintf(conn, "user %s pass %d vers tempest-exporter 1.0\r\n", s.c.Callsign, s.c.Passcode); err != nil {
+		return err
+	}
+	line, err := rd.ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("error reading login response: %v", err)
+	}
+	if !strings.HasPrefix(line, "# logresp") {
+		return fmt.Errorf("unexpected login response %q", strings.TrimSpace(line))
+	}
+	_, err = fmt.Fprintf(conn, "%s\r\n", packet)
+	return err
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAPRSPosition(t *testing.T) {
+	tests := []struct {
+		lat, lon float64
+		want     string
+	}{
+		{lat: 49.0583, lon: -72.0292, want: "4903.50N/07201.75W_"},
+		{lat: -33.8688, lon: 151.2093, want: "3352.13S/15112.56E_"},
+		{lat: 0, lon: 0, want: "0000.00N/00000.00E_"},
+	}
+	for _, tt := range tests {
+		if got := aprsPosition(tt.lat, tt.lon); got != tt.want {
+			t.Errorf("aprsPosition(%v, %v) = %q, want %q", tt.lat, tt.lon, got, tt.want)
+		}
+	}
+}
+
+func TestAPRSWeather(t *testing.T) {
+	tests := []struct {
+		name   string
+		values map[string]float64
+		want   string
+	}{
+		{name: "no readings", want: ".../...g...t..."},
+		{
+			name: "full observation",
+			values: map[string]float64{
+				"wind_direction":         90,
+				"wind_avg":               2,
+				"wind_gust":              5,
+				"air_temperature":        20,
+				"precip_accum_last_1hr":  2.54,
+				"precip_accum_local_day": 12.7,
+				"relative_humidity":      55,
+				"station_pressure":       1000.3,
+				"solar_radiation":        500,
+			},
+			want: "090/004g011t068r010P050h55b10000L500",
+		},
+		{
+			name:   "below freezing, saturated and bright",
+			values: map[string]float64{"air_temperature": -20, "relative_humidity": 100, "solar_radiation": 1200},
+			want:   ".../...g...t-04h00l200",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := aprsWeather(tt.values, 0); got != tt.want {
+				t.Errorf("aprsWeather() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCWOPUpload(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	lines := make(chan []string, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		rd := bufio.NewReader(conn)
+		conn.Write([]byte("# aprsc 2.1\r\n"))
+		login, _ := rd.ReadString('\n')
+		conn.Write([]byte("# logresp EW1234 unverified, server T2TEST\r\n"))
+		packet, _ := rd.ReadString('\n')
+		lines <- []string{strings.TrimSpace(login), strings.TrimSpace(packet)}
+	}()
+	s := newCWOPSink(cwopConfig{Callsign: "EW1234", Passcode: -1, Server: l.Addr().String()})
+	var r response
+	r.Latitude, r.Longitude = 49.0583, -72.0292
+	rec := sinkRecord{Values: map[string]float64{"air_temperature": 20}, Time: time.Unix(1700000000, 0)}
+	if err := s.upload(s.packet(rec, r)); err != nil {
+		t.Fatalf("upload() error = %v", err)
+	}
+	got := <-lines
+	if want := "user EW1234 pass -1 vers tempest-exporter 1.0"; got[0] != want {
+		t.Errorf("login = %q, want %q", got[0], want)
+	}
+	if want := "EW1234>APRS,TCPIP*:@142213z4903.50N/07201.75W_.../...g...t068tempest-exporter"; got[1] != want {
+		t.Errorf("packet = %q, want %q", got[1], want)
+	}
+}
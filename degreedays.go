@@ -0,0 +1,156 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	// degreeDays holds the degree day state of each station
+	degreeDays = make(map[string]*stationDegreeDays)
+	// degreeDayMetrics are the gauges exporting our degree days
+	degreeDayMetrics = make(MetricsMap)
+)
+
+// stationDegreeDays is a station's air temperature range today, its growing
+// degree days over the completed days of the growing season, and its heating
+// and cooling degree days over every completed day
+type stationDegreeDays struct {
+	Day     string  `json:"day"`
+	Min     float64 `json:"min"`
+	Max     float64 `json:"max"`
+	Season  string  `json:"season"`
+	Growing float64 `json:"growing"`
+	Heating float64 `json:"heating"`
+	Cooling float64 `json:"cooling"`
+}
+
+// growingDegreeDays returns the growing degree days of a day with the given
+// air temperature range, by the average method
+func growingDegreeDays(min, max, base float64) float64 {
+	return math.Max(0, (min+max)/2-base)
+}
+
+// heatingCoolingDegreeDays returns the heating and cooling degree days of a day
+// with the given air temperature range, against base
+func heatingCoolingDegreeDays(min, max, base float64) (float64, float64) {
+	mean := (min + max) / 2
+	return math.Max(0, base-mean), math.Max(0, mean-base)
+}
+
+// growingSeason returns the year in which the growing season t falls in
+// started, given the month and day seasons start on
+func growingSeason(t time.Time, start string) string {
+	s, _ := time.Parse("01-02", start)
+	if t.Month() < s.Month() || (t.Month() == s.Month() && t.Day() < s.Day()) {
+		return strconv.Itoa(t.Year() - 1)
+	}
+	return strconv.Itoa(t.Year())
+}
+
+// observe folds an air temperature observed at local time t into the
+// station's degree days, returning whether they changed
+func (d *stationDegreeDays) observe(t time.Time, temp float64) bool {
+	day := t.Format("2006-01-02")
+	if day != d.Day {
+		if d.Day != "" {
+			heating, cooling := heatingCoolingDegreeDays(d.Min, d.Max, cfg.DegreeDays.Base)
+			d.Growing += growingDegreeDays(d.Min, d.Max, cfg.DegreeDays.GrowingBase)
+			d.Heating += heating
+			d.Cooling += cooling
+		}
+		if season := growingSeason(t, cfg.DegreeDays.SeasonStart); season != d.Season {
+			d.Season, d.Growing = season, 0
+		}
+		d.Day, d.Min, d.Max = day, temp, temp
+		return true
+	}
+	if temp >= d.Min && temp <= d.Max {
+		return false
+	}
+	d.Min, d.Max = math.Min(d.Min, temp), math.Max(d.Max, temp)
+	return true
+}
+
+// registerDegreeDays creates and registers the gauges for our degree days
+func registerDegreeDays(labelNames []string) {
+	names := append(append([]string{}, labelNames...), "period")
+	help := map[string]string{
+		"growing_degree_days": "Growing degree days over the current period, today's so far from its air temperature range",
+		"heating_degree_days": "Heating degree days today so far, or in total since we started accumulating them",
+		"cooling_degree_days": "Cooling degree days today so far, or in total since we started accumulating them",
+	}
+	for name, h := range help {
+		degreeDayMetrics[name] = prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: ns,
+				Subsystem: ss,
+				Name:      name,
+				Help:      h,
+			},
+			names,
+		)
+		weatherRegistry.MustRegister(degreeDayMetrics[name])
+	}
+}
+
+// loadDegreeDays reads persisted degree days from our degree days file, if
+// configured
+func loadDegreeDays() error {
+	if cfg.DegreeDays.File == "" {
+		return nil
+	}
+	b, err := ioutil.ReadFile(cfg.DegreeDays.File)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error reading degree days file: %v", err)
+	}
+	if err := json.Unmarshal(b, &degreeDays); err != nil {
+		return fmt.Errorf("error parsing degree days file: %v", err)
+	}
+	return nil
+}
+
+// setDegreeDays adds a station's observation to its degree days, persisting
+// and exporting them
+func setDegreeDays(stationID, timezone string, o observation, labels prometheus.Labels) error {
+	if !o.has("air_temperature") {
+		return nil
+	}
+	d, ok := degreeDays[stationID]
+	if !ok {
+		d = &stationDegreeDays{}
+		degreeDays[stationID] = d
+	}
+	var err error
+	t := time.Unix(int64(o.Timestamp), 0).In(location(timezone))
+	if d.observe(t, o.AirTemperature) && cfg.DegreeDays.File != "" {
+		err = writeJSONFile(cfg.DegreeDays.File, degreeDays)
+	}
+	growing := growingDegreeDays(d.Min, d.Max, cfg.DegreeDays.GrowingBase)
+	heating, cooling := heatingCoolingDegreeDays(d.Min, d.Max, cfg.DegreeDays.Base)
+	periods := map[string]map[string]float64{
+		"day":    {"growing_degree_days": growing, "heating_degree_days": heating, "cooling_degree_days": cooling},
+		"season": {"growing_degree_days": d.Growing + growing},
+		"total":  {"heating_degree_days": d.Heating + heating, "cooling_degree_days": d.Cooling + cooling},
+	}
+	for period, values := range periods {
+		l := prometheus.Labels{"period": period}
+		for k, v := range labels {
+			l[k] = v
+		}
+		for name, v := range values {
+			degreeDayMetrics[name].With(l).Set(v)
+		}
+	}
+	return err
+}
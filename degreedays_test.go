@@ -0,0 +1,114 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGrowingDegreeDays(t *testing.T) {
+	tests := []struct {
+		min, max, base, want float64
+	}{
+		{min: 10, max: 30, base: 10, want: 10},
+		{min: 5, max: 13, base: 10, want: 0},
+		{min: -5, max: 5, base: 10, want: 0},
+	}
+	for _, tt := range tests {
+		if got := growingDegreeDays(tt.min, tt.max, tt.base); got != tt.want {
+			t.Errorf("growingDegreeDays(%v, %v, %v) = %v, want %v", tt.min, tt.max, tt.base, got, tt.want)
+		}
+	}
+}
+
+func TestGrowingSeason(t *testing.T) {
+	tests := []struct {
+		t     time.Time
+		start string
+		want  string
+	}{
+		{t: time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC), start: "01-01", want: "2026"},
+		{t: time.Date(2026, 2, 28, 0, 0, 0, 0, time.UTC), start: "03-01", want: "2025"},
+		{t: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), start: "03-01", want: "2026"},
+		{t: time.Date(2026, 7, 14, 0, 0, 0, 0, time.UTC), start: "07-15", want: "2025"},
+	}
+	for _, tt := range tests {
+		if got := growingSeason(tt.t, tt.start); got != tt.want {
+			t.Errorf("growingSeason(%s, %q) = %q, want %q", tt.t.Format("2006-01-02"), tt.start, got, tt.want)
+		}
+	}
+}
+
+func TestDegreeDaysObserve(t *testing.T) {
+	defer func(c degreeDaysConfig) { cfg.DegreeDays = c }(cfg.DegreeDays)
+	cfg.DegreeDays.GrowingBase = 10
+	cfg.DegreeDays.SeasonStart = "03-01"
+	at := func(year int, month time.Month, day, hour int) time.Time {
+		return time.Date(year, month, day, hour, 0, 0, 0, time.UTC)
+	}
+	var d stationDegreeDays
+	steps := []struct {
+		t       time.Time
+		temp    float64
+		changed bool
+		growing float64
+	}{
+		{t: at(2026, 4, 1, 6), temp: 8, changed: true},
+		{t: at(2026, 4, 1, 9), temp: 12, changed: true},
+		{t: at(2026, 4, 1, 12), temp: 10, changed: false},
+		{t: at(2026, 4, 1, 15), temp: 24, changed: true},
+		// the first observation of a day adds the previous day's
+		{t: at(2026, 4, 2, 0), temp: 15, changed: true, growing: 6},
+		{t: at(2026, 4, 3, 0), temp: 15, changed: true, growing: 11},
+		// a new season starts from nothing
+		{t: at(2027, 3, 1, 0), temp: 15, changed: true, growing: 0},
+	}
+	for i, s := range steps {
+		if changed := d.observe(s.t, s.temp); changed != s.changed {
+			t.Errorf("step %d: observe() = %v, want %v", i, changed, s.changed)
+		}
+		if d.Growing != s.growing {
+			t.Errorf("step %d: growing degree days = %v, want %v", i, d.Growing, s.growing)
+		}
+	}
+}
+
+func TestHeatingCoolingDegreeDays(t *testing.T) {
+	tests := []struct {
+		min, max, base   float64
+		heating, cooling float64
+	}{
+		{min: 0, max: 10, base: 18, heating: 13},
+		{min: 20, max: 30, base: 18, cooling: 7},
+		{min: 12, max: 24, base: 18},
+	}
+	for _, tt := range tests {
+		heating, cooling := heatingCoolingDegreeDays(tt.min, tt.max, tt.base)
+		if heating != tt.heating || cooling != tt.cooling {
+			t.Errorf("heatingCoolingDegreeDays(%v, %v, %v) = %v, %v, want %v, %v", tt.min, tt.max, tt.base, heating, cooling, tt.heating, tt.cooling)
+		}
+	}
+}
+
+func TestHeatingCoolingObserve(t *testing.T) {
+	defer func(c degreeDaysConfig) { cfg.DegreeDays = c }(cfg.DegreeDays)
+	cfg.DegreeDays.Base = 18
+	cfg.DegreeDays.SeasonStart = "01-01"
+	var d stationDegreeDays
+	days := []struct {
+		min, max         float64
+		heating, cooling float64
+	}{
+		{min: 0, max: 10},
+		{min: 20, max: 30, heating: 13},
+		{min: 12, max: 24, heating: 13, cooling: 7},
+		{min: 12, max: 12, heating: 13, cooling: 7},
+	}
+	for i, day := range days {
+		start := time.Date(2026, 1, 1+i, 0, 0, 0, 0, time.UTC)
+		d.observe(start, day.min)
+		d.observe(start.Add(12*time.Hour), day.max)
+		if d.Heating != day.heating || d.Cooling != day.cooling {
+			t.Errorf("day %d: degree days = %v heating, %v cooling, want %v, %v", i, d.Heating, d.Cooling, day.heating, day.cooling)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"math"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// derivedMetrics are the gauges exporting values we compute locally
+var derivedMetrics = make(MetricsMap)
+
+// cToF converts celsius to fahrenheit
+func cToF(c float64) float64 {
+	return c*9/5 + 32
+}
+
+// fToC converts fahrenheit to celsius
+func fToC(f float64) float64 {
+	return (f - 32) * 5 / 9
+}
+
+// heatIndex returns the NWS heat index (Rothfusz regression with its
+// adjustments) in celsius for an air temperature in celsius and relative
+// humidity in percent
+func heatIndex(tempC, rh float64) float64 {
+	t := cToF(tempC)
+	hi := 0.5 * (t + 61 + (t-68)*1.2 + rh*0.094)
+	if (hi+t)/2 < 80 {
+		return fToC(hi)
+	}
+	hi = -42.379 + 2.04901523*t + 10.14333127*rh - 0.22475541*t*rh -
+		0.00683783*t*t - 0.05481717*rh*rh + 0.00122874*t*t*rh +
+		0.00085282*t*rh*rh - 0.00000199*t*t*rh*rh
+	switch {
+	case rh < 13 && t >= 80 && t <= 112:
+		hi -= (13 - rh) / 4 * math.Sqrt((17-math.Abs(t-95))/17)
+	case rh > 85 && t >= 80 && t <= 87:
+		hi += (rh - 85) / 10 * (87 - t) / 5
+	}
+	return fToC(hi)
+}
+
+// windChill returns the NWS wind chill in celsius for an air temperature in
+// celsius and wind speed in m/s
+func windChill(tempC, wind float64) float64 {
+	t := cToF(tempC)
+	v := math.Pow(wind*2.236936, 0.16)
+	return fToC(35.74 + 0.6215*t - 35.75*v + 0.4275*t*v)
+}
+
+// feelsLike returns the apparent temperature in celsius: the heat index when
+// it's hot, the wind chill when it's cold and windy, or the air temperature
+func feelsLike(tempC, rh, wind float64) float64 {
+	switch {
+	case tempC >= 26.7:
+		return heatIndex(tempC, rh)
+	case tempC <= 10 && wind > 1.34:
+		return windChill(tempC, wind)
+	default:
+		return tempC
+	}
+}
+
+// saturationVaporPressure returns the saturation vapor pressure in kPa over
+// water at an air temperature in celsius (Tetens, as used by FAO-56)
+func saturationVaporPressure(tempC float64) float64 {
+	return 0.6108 * math.Exp(17.27*tempC/(tempC+237.3))
+}
+
+// vaporPressureDeficit returns the vapor pressure deficit in kPa for an air
+// temperature in celsius and relative humidity in percent
+func vaporPressureDeficit(tempC, rh float64) float64 {
+	return saturationVaporPressure(tempC) * (1 - rh/100)
+}
+
+// densityAltitude returns the density altitude in meters for an air
+// temperature in celsius, relative humidity in percent and station pressure
+// in mb: the altitude in the ICAO standard atmosphere with the same air
+// density, using the virtual temperature to account for humidity
+func densityAltitude(tempC, rh, pressure float64) float64 {
+	e := saturationVaporPressure(tempC) * 10 * rh / 100
+	tv := (tempC + 273.15) / (1 - 0.378*e/pressure)
+	density := pressure * 100 / (287.05 * tv)
+	return 44330.8 * (1 - math.Pow(density/1.225, 0.234969))
+}
+
+// registerDerived creates and registers the gauges for our derived values
+func registerDerived(labelNames []string) {
+	help := map[string]string{
+		"heat_index_local":           "Heat index computed locally from air temperature and relative humidity",
+		"wind_chill_local":           "Wind chill computed locally from air temperature and average wind speed",
+		"feels_like_local":           "Feels like temperature computed locally from the heat index or wind chill",
+		"feels_like_divergence":      "Locally computed feels like temperature minus the API's feels_like",
+		"vapor_pressure_deficit_kpa": "Vapor pressure deficit in kPa computed from air temperature and relative humidity",
+		"density_altitude_meters":    "Density altitude computed from station pressure, air temperature and relative humidity",
+	}
+	for name, h := range help {
+		derivedMetrics[name] = prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: ns,
+				Subsystem: ss,
+				Name:      name,
+				Help:      h,
+			},
+			labelNames,
+		)
+		weatherRegistry.MustRegister(derivedMetrics[name])
+	}
+}
+
+// setDerived computes and updates our derived values from an observation
+func setDerived(o observation, labels prometheus.Labels) {
+	if o.has("air_temperature") && o.has("relative_humidity") && o.has("station_pressure") && o.StationPressure > 0 {
+		derivedMetrics["density_altitude_meters"].With(labels).Set(densityAltitude(o.AirTemperature, o.RelativeHumidity, o.StationPressure))
+	}
+	if !o.has("air_temperature") || !o.has("relative_humidity") || !o.has("wind_avg") {
+		return
+	}
+	fl := feelsLike(o.AirTemperature, o.RelativeHumidity, o.WindAvg)
+	derivedMetrics["heat_index_local"].With(labels).Set(heatIndex(o.AirTemperature, o.RelativeHumidity))
+	derivedMetrics["wind_chill_local"].With(labels).Set(windChill(o.AirTemperature, o.WindAvg))
+	derivedMetrics["feels_like_local"].With(labels).Set(fl)
+	derivedMetrics["vapor_pressure_deficit_kpa"].With(labels).Set(vaporPressureDeficit(o.AirTemperature, o.RelativeHumidity))
+	if o.has("feels_like") {
+		derivedMetrics["feels_like_divergence"].With(labels).Set(fl - o.FeelsLike)
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestFeelsLike(t *testing.T) {
+	tests := []struct {
+		name                   string
+		tempC, rh, wind, wantF float64
+	}{
+		// from the NWS heat index and wind chill charts
+		{name: "heat index", tempC: fToC(90), rh: 70, wind: 5, wantF: 106},
+		{name: "heat index below 80F", tempC: fToC(81), rh: 20, wind: 0, wantF: 79},
+		{name: "wind chill", tempC: fToC(0), rh: 50, wind: 15 / 2.236936, wantF: -19},
+		{name: "calm cold", tempC: 5, rh: 50, wind: 1, wantF: cToF(5)},
+		{name: "mild", tempC: 20, rh: 90, wind: 10, wantF: cToF(20)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cToF(feelsLike(tt.tempC, tt.rh, tt.wind)); math.Abs(got-tt.wantF) > 1 {
+				t.Errorf("feelsLike() = %.1fF, want %.1fF", got, tt.wantF)
+			}
+		})
+	}
+}
+
+func TestVaporPressureDeficit(t *testing.T) {
+	tests := []struct {
+		tempC, rh, want float64
+	}{
+		// FAO-56 table 2.3
+		{tempC: 25, rh: 0, want: 3.168},
+		{tempC: 25, rh: 50, want: 1.584},
+		{tempC: 10, rh: 100, want: 0},
+	}
+	for _, tt := range tests {
+		if got := vaporPressureDeficit(tt.tempC, tt.rh); math.Abs(got-tt.want) > 0.001 {
+			t.Errorf("vaporPressureDeficit(%v, %v) = %v, want %v", tt.tempC, tt.rh, got, tt.want)
+		}
+	}
+}
+
+func TestDensityAltitude(t *testing.T) {
+	tests := []struct {
+		name                      string
+		tempC, rh, pressure, want float64
+	}{
+		{name: "standard atmosphere", tempC: 15, rh: 0, pressure: 1013.25, want: 0},
+		{name: "hot", tempC: 30, rh: 0, pressure: 1013.25, want: 525},
+		{name: "hot and humid", tempC: 30, rh: 80, pressure: 1013.25, want: 656},
+		{name: "high and cold", tempC: -10, rh: 0, pressure: 850, want: 876},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := densityAltitude(tt.tempC, tt.rh, tt.pressure); math.Abs(got-tt.want) > 5 {
+				t.Errorf("densityAltitude() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,391 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/nalbury/tempest-exporter/pkg/weatherflow"
+)
+
+// deviceRefreshInterval is how often we refresh the list of devices on a station
+const deviceRefreshInterval = time.Hour
+
+// batteryIndex is the position of the battery voltage in each device
+// observation type
+var batteryIndex = map[string]int{
+	"obs_st":  16,
+	"obs_air": 6,
+	"obs_sky": 8,
+}
+
+// solarIndex is the position of the solar radiation in device observation
+// types from devices with a solar panel
+var solarIndex = map[string]int{
+	"obs_st":  11,
+	"obs_sky": 10,
+}
+
+// deviceFields is the position of each reading we export in each device
+// observation type, keyed by metric name
+var deviceFields = map[string]map[string]int{
+	"obs_st": {
+		"wind_lull":                     1,
+		"wind_avg":                      2,
+		"wind_gust":                     3,
+		"wind_direction":                4,
+		"station_pressure":              6,
+		"air_temperature":               7,
+		"relative_humidity":             8,
+		"brightness":                    9,
+		"uv":                            10,
+		"solar_radiation":               11,
+		"precip":                        12,
+		"lightning_strike_avg_distance": 14,
+		"lightning_strike_count":        15,
+	},
+	"obs_air": {
+		"station_pressure":              1,
+		"air_temperature":               2,
+		"relative_humidity":             3,
+		"lightning_strike_count":        4,
+		"lightning_strike_avg_distance": 5,
+	},
+	"obs_sky": {
+		"brightness":      1,
+		"uv":              2,
+		"precip":          3,
+		"wind_lull":       4,
+		"wind_avg":        5,
+		"wind_gust":       6,
+		"wind_direction":  7,
+		"solar_radiation": 10,
+	},
+}
+
+// deviceStatusHelp is the help for each gauge exporting a device's status,
+// keyed by metric name
+var deviceStatusHelp = map[string]string{
+	"battery_voltage": "Battery voltage of the device",
+	"rssi":            "Signal strength of the device's radio link to its hub",
+	"hub_rssi":        "Signal strength of the device's radio link as seen by its hub",
+	"uptime_seconds":  "Seconds since the device last restarted",
+	"sensor_status":   "Sensor status bit flags reported by the device, 0 when all sensors are ok",
+}
+
+// batteryStates are the states we classify a battery's trend into
+var batteryStates = []string{"charging", "discharging", "steady"}
+
+// batterySlopeThreshold is the rate of change, in volts per hour, a battery
+// must exceed to be considered charging or discharging
+const batterySlopeThreshold = 0.005
+
+// voltageSample is a battery voltage reading from a device observation
+type voltageSample struct {
+	timestamp float64
+	volts     float64
+}
+
+// device is a device attached to a station
+type device = weatherflow.Device
+
+// stationMeta is a station's metadata from the stations API
+type stationMeta = weatherflow.Station
+
+// deviceResponse is a device's latest observation from the device
+// observations API
+type deviceResponse weatherflow.DeviceObservations
+
+// stationDevices is the cached device list for a station, and when its
+// devices were last polled
+type stationDevices struct {
+	devices []device
+	fetched time.Time
+	polled  time.Time
+	// observed is the timestamp of the station observation when its devices
+	// were last polled
+	observed float64
+}
+
+var (
+	// devices holds the devices on each station
+	devices = make(map[string]stationDevices)
+	// batteryIsLow holds whether each device's battery was last considered low
+	batteryIsLow = make(map[int]bool)
+	// voltageHistory holds recent battery voltage samples for each device
+	voltageHistory = make(map[int][]voltageSample)
+	// deviceMetrics are the gauges exporting each device's own readings
+	deviceMetrics = make(MetricsMap)
+	// batteryLow is 1 while a device's battery is low
+	batteryLow = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: ns,
+			Subsystem: "device",
+			Name:      "battery_low",
+			Help:      "1 if the device's battery voltage is below its low threshold",
+		},
+		[]string{"station_id", "device_id", "serial_number", "device_type"},
+	)
+	// batteryTrend is the rate of change of a device's battery voltage
+	batteryTrend = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: ns,
+			Subsystem: "device",
+			Name:      "battery_voltage_trend_volts_per_hour",
+			Help:      "Rate of change of the device's battery voltage over the trend window",
+		},
+		[]string{"station_id", "device_id", "serial_number", "device_type"},
+	)
+	// batteryState is 1 for the state a device's battery is currently in
+	batteryState = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: ns,
+			Subsystem: "device",
+			Name:      "battery_state",
+			Help:      "1 for the battery's current state (charging, discharging or steady), 0 for the others",
+		},
+		[]string{"station_id", "device_id", "serial_number", "device_type", "state"},
+	)
+)
+
+// getStationMeta retrieves the metadata, including devices, for a station
+func getStationMeta(client *weatherflow.Client, s string) (stationMeta, error) {
+	id, err := strconv.Atoi(s)
+	if err != nil {
+		return stationMeta{}, fmt.Errorf("invalid station id %q", s)
+	}
+	return client.Station(context.Background(), id)
+}
+
+// getDeviceData retrieves the latest observation for a device
+func getDeviceData(client *weatherflow.Client, id int) (deviceResponse, error) {
+	r, err := client.DeviceObservations(context.Background(), id)
+	return deviceResponse(r), err
+}
+
+// values returns the readings in a device's latest observation keyed by
+// metric name
+func (d deviceResponse) values() map[string]float64 {
+	values := make(map[string]float64)
+	if len(d.Obs) == 0 {
+		return values
+	}
+	for name, i := range deviceFields[d.Type] {
+		if i < len(d.Obs[0]) {
+			values[name] = d.Obs[0][i]
+		}
+	}
+	return values
+}
+
+// battery returns the battery voltage from a device observation
+func (d deviceResponse) battery() (float64, bool) {
+	i, ok := batteryIndex[d.Type]
+	if !ok || len(d.Obs) == 0 || len(d.Obs[0]) <= i {
+		return 0, false
+	}
+	return d.Obs[0][i], true
+}
+
+// solar returns the solar radiation from a device observation, and false if
+// the device has no solar panel
+func (d deviceResponse) solar() (float64, bool) {
+	i, ok := solarIndex[d.Type]
+	if !ok || len(d.Obs) == 0 || len(d.Obs[0]) <= i {
+		return 0, false
+	}
+	return d.Obs[0][i], true
+}
+
+// voltageTrend adds a voltage sample to a device's history and returns the
+// least squares slope of its recent voltage in volts per hour, and false if
+// there aren't enough samples yet
+func voltageTrend(id int, sample voltageSample) (float64, bool) {
+	h := voltageHistory[id]
+	if len(h) == 0 || h[len(h)-1].timestamp != sample.timestamp {
+		h = append(h, sample)
+	}
+	window := time.Duration(cfg.Battery.TrendWindow).Seconds()
+	for len(h) > 0 && sample.timestamp-h[0].timestamp > window {
+		h = h[1:]
+	}
+	voltageHistory[id] = h
+	if len(h) < 3 {
+		return 0, false
+	}
+	var st, sv, stt, stv float64
+	for _, s := range h {
+		t := (s.timestamp - h[0].timestamp) / 3600
+		st += t
+		sv += s.volts
+		stt += t * t
+		stv += t * s.volts
+	}
+	n := float64(len(h))
+	den := n*stt - st*st
+	if den == 0 {
+		return 0, false
+	}
+	return (n*stv - st*sv) / den, true
+}
+
+// classifyBattery classifies a battery's voltage trend. Batteries can only be
+// charging while their device's solar panel is in the sun.
+func classifyBattery(slope, solar float64, hasSolar bool) string {
+	switch {
+	case slope > batterySlopeThreshold && hasSolar && solar > 0:
+		return "charging"
+	case slope < -batterySlopeThreshold:
+		return "discharging"
+	default:
+		return "steady"
+	}
+}
+
+// isBatteryLow applies our low voltage threshold, with hysteresis, to a
+// device's battery voltage
+func isBatteryLow(id int, deviceType string, v float64) bool {
+	threshold, ok := cfg.Battery.LowVoltage[deviceType]
+	if !ok {
+		return false
+	}
+	low := batteryIsLow[id]
+	if low {
+		low = v < threshold+cfg.Battery.Hysteresis
+	} else {
+		low = v < threshold
+	}
+	batteryIsLow[id] = low
+	return low
+}
+
+// registerDeviceMetrics creates and registers a gauge for each device reading
+func registerDeviceMetrics() {
+	for _, fields := range deviceFields {
+		for name := range fields {
+			if _, ok := deviceMetrics[name]; ok {
+				continue
+			}
+			deviceMetrics[name] = prometheus.NewGaugeVec(
+				prometheus.GaugeOpts{
+					Namespace: ns,
+					Subsystem: "device",
+					Name:      name,
+					Help:      fmt.Sprintf("The device's own %s reading from its latest observation", strings.ReplaceAll(name, "_", " ")),
+				},
+				[]string{"station_id", "device_id", "serial_number", "device_type", "location"},
+			)
+			weatherRegistry.MustRegister(deviceMetrics[name])
+		}
+	}
+	for name, help := range deviceStatusHelp {
+		deviceMetrics[name] = prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: ns,
+				Subsystem: "device",
+				Name:      name,
+				Help:      help,
+			},
+			[]string{"station_id", "device_id", "serial_number", "device_type"},
+		)
+		weatherRegistry.MustRegister(deviceMetrics[name])
+	}
+}
+
+// deviceType returns the device type (e.g. ST, AR or SK) from a device's
+// serial number
+func deviceType(serial string) string {
+	if i := strings.Index(serial, "-"); i > 0 {
+		return serial[:i]
+	}
+	return ""
+}
+
+// deviceReading is a device's latest observation
+type deviceReading struct {
+	d device
+	r deviceResponse
+}
+
+// fetchDevices gets the latest observation from each device on a station with
+// client, if device polling is enabled and due. Devices aren't polled again
+// until the station has a new observation, as that's built from theirs. The
+// observations fetched before any error are returned along with it.
+func fetchDevices(client *weatherflow.Client, c devicesConfig, station string, r response) ([]deviceReading, error) {
+	if !c.Enabled || len(r.Obs) == 0 {
+		return nil, nil
+	}
+	sd := devices[station]
+	if time.Since(sd.polled) < time.Duration(c.Interval) || sd.observed == r.Obs[0].Timestamp {
+		return nil, nil
+	}
+	if time.Since(sd.fetched) > deviceRefreshInterval {
+		meta, err := getStationMeta(client, station)
+		if err != nil {
+			return nil, err
+		}
+		sd.devices, sd.fetched = meta.Devices, time.Now()
+		devices[station] = sd
+	}
+	var readings []deviceReading
+	for _, d := range sd.devices {
+		// hubs don't report observations
+		if d.DeviceType == "HB" {
+			continue
+		}
+		dr, err := getDeviceData(client, d.DeviceID)
+		if err != nil {
+			return readings, err
+		}
+		readings = append(readings, deviceReading{d: d, r: dr})
+	}
+	sd.polled, sd.observed = time.Now(), r.Obs[0].Timestamp
+	devices[station] = sd
+	return readings, nil
+}
+
+// setDevice updates a device's metrics from its latest observation
+func setDevice(station string, d device, r deviceResponse) {
+	metricsMu.Lock()
+	defer metricsMu.Unlock()
+	id := strconv.Itoa(d.DeviceID)
+	// the station observation blends its devices, so an indoor device's
+	// readings are only kept apart from the outdoor ones here
+	for name, v := range r.values() {
+		labels := []string{station, id, d.SerialNumber, d.DeviceType, d.DeviceMeta.Environment}
+		if !inBounds(name, v) {
+			deviceMetrics[name].DeleteLabelValues(labels...)
+			continue
+		}
+		deviceMetrics[name].WithLabelValues(labels...).Set(v)
+	}
+	v, ok := r.battery()
+	if !ok {
+		return
+	}
+	deviceMetrics["battery_voltage"].WithLabelValues(station, id, d.SerialNumber, d.DeviceType).Set(v)
+	var low float64
+	if isBatteryLow(d.DeviceID, d.DeviceType, v) {
+		low = 1
+	}
+	batteryLow.WithLabelValues(station, id, d.SerialNumber, d.DeviceType).Set(low)
+
+	slope, ok := voltageTrend(d.DeviceID, voltageSample{timestamp: r.Obs[0][0], volts: v})
+	if !ok {
+		return
+	}
+	solar, hasSolar := r.solar()
+	state := classifyBattery(slope, solar, hasSolar)
+	batteryTrend.WithLabelValues(station, id, d.SerialNumber, d.DeviceType).Set(slope)
+	for _, s := range batteryStates {
+		var v float64
+		if s == state {
+			v = 1
+		}
+		batteryState.WithLabelValues(station, id, d.SerialNumber, d.DeviceType, s).Set(v)
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// stationPair is a pair of stations we export the differences between
+type stationPair struct {
+	A string `json:"station_a"`
+	B string `json:"station_b"`
+}
+
+// stationPairs is a list of station pairs
+type stationPairs []stationPair
+
+// differentialMetrics are the readings we export differences for
+var differentialMetrics = []string{
+	"air_temperature",
+	"relative_humidity",
+	"dew_point",
+	"sea_level_pressure",
+	"station_pressure",
+	"wind_avg",
+}
+
+var (
+	// latest holds the latest observation for each station
+	latest = make(map[string]observation)
+	// stationResponses holds the latest response for each station, for its
+	// location
+	stationResponses = make(map[string]response)
+	// differentials are the gauges exporting differences between station pairs
+	differentials = make(MetricsMap)
+)
+
+// UnmarshalText parses station pairs in the form "a:b,c:d"
+func (p *stationPairs) UnmarshalText(text []byte) error {
+	*p = nil
+	for _, item := range strings.Split(string(text), ",") {
+		ab := strings.Split(strings.TrimSpace(item), ":")
+		if len(ab) != 2 || ab[0] == "" || ab[1] == "" {
+			return fmt.Errorf("invalid station pair %q, expected station_a:station_b", item)
+		}
+		*p = append(*p, stationPair{A: ab[0], B: ab[1]})
+	}
+	return nil
+}
+
+// UnmarshalJSON parses station pairs from a string or a list of pairs
+func (p *stationPairs) UnmarshalJSON(text []byte) error {
+	return unmarshalJSONOrText(text, p, (*[]stationPair)(p))
+}
+
+// registerDifferentials creates and registers our differential gauges
+func registerDifferentials() {
+	if len(cfg.StationPairs) == 0 {
+		return
+	}
+	for _, name := range differentialMetrics {
+		differentials[name] = prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: ns,
+				Subsystem: "station_pair",
+				Name:      name + "_delta",
+				Help:      fmt.Sprintf("Difference in %s between station_a and station_b (a - b)", strings.ReplaceAll(name, "_", " ")),
+			},
+			[]string{"station_a", "station_b"},
+		)
+		weatherRegistry.MustRegister(differentials[name])
+	}
+}
+
+// setDifferentials updates the differences between each of our station pairs
+func setDifferentials() {
+	metricsMu.Lock()
+	defer metricsMu.Unlock()
+	for _, p := range cfg.StationPairs {
+		a, okA := latest[p.A]
+		b, okB := latest[p.B]
+		if !okA || !okB {
+			continue
+		}
+		va, vb := a.values(), b.values()
+		for _, name := range differentialMetrics {
+			if !a.has(name) || !b.has(name) {
+				continue
+			}
+			differentials[name].WithLabelValues(p.A, p.B).Set(va[name] - vb[name])
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/nalbury/tempest-exporter/pkg/weatherflow"
+)
+
+var (
+	// discoveryMu guards our discovered stations
+	discoveryMu sync.Mutex
+	// discovered holds the IDs of the stations found on the account
+	discovered []string
+	// discoveredAt is when we last discovered the stations on the account
+	discoveredAt time.Time
+	// discoveryBackoff holds when to retry discovery after failures
+	discoveryBackoff backoff
+)
+
+// getStations retrieves every station our token can access
+func getStations() ([]stationMeta, error) {
+	meta, err := apiClient.Stations(context.Background())
+	var apiErr *weatherflow.Error
+	if errors.As(err, &apiErr) && apiErr.Code == http.StatusUnauthorized {
+		return nil, fmt.Errorf("%v, check WEATHERFLOW_API_TOKEN", err)
+	}
+	return meta, err
+}
+
+// discoverStations returns the sorted IDs of every station our token can access
+func discoverStations() ([]string, error) {
+	meta, err := getStations()
+	if err != nil {
+		return nil, fmt.Errorf("error discovering stations: %v", err)
+	}
+	ids := make([]string, 0, len(meta))
+	for _, s := range meta {
+		ids = append(ids, strconv.Itoa(s.StationID))
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// stations returns the stations to poll, which are our configured stations or,
+// if none are configured, every station on the account. Discovered stations
+// are refreshed every discovery interval, and a failed refresh keeps the
+// stations we already know about until it's retried on our backoff schedule.
+func stations() []string {
+	if len(cfg.Stations) > 0 {
+		return cfg.Stations
+	}
+	discoveryMu.Lock()
+	defer discoveryMu.Unlock()
+	now := time.Now()
+	if now.Sub(discoveredAt) < time.Duration(cfg.DiscoveryInterval) || now.Before(discoveryBackoff.next) {
+		return discovered
+	}
+	ids, err := discoverStations()
+	if err != nil {
+		d := discoveryBackoff.fail(now, err)
+		slog.Error(err.Error(), "retry_in", d)
+		reportFailure("discovery", err)
+		return discovered
+	}
+	discoveryBackoff = backoff{}
+	reportSuccess("discovery")
+	for _, id := range ids {
+		if !contains(discovered, id) {
+			slog.Info("discovered station", "station_id", id)
+		}
+	}
+	for _, id := range discovered {
+		if !contains(ids, id) {
+			slog.Info("station is no longer on the account", "station_id", id)
+		}
+	}
+	discovered = ids
+	discoveredAt = now
+	return discovered
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+// doctorCheck is the outcome of a single doctor check
+type doctorCheck struct {
+	name   string
+	ok     bool
+	detail string
+}
+
+// doctorReport collects the outcome of each doctor check
+type doctorReport []doctorCheck
+
+// add records the outcome of a check
+func (r *doctorReport) add(name string, err error, detail string) {
+	if err != nil {
+		detail = err.Error()
+	}
+	*r = append(*r, doctorCheck{name: name, ok: err == nil, detail: detail})
+}
+
+// print writes the report as a table, returning whether every check passed
+func (r doctorReport) print() bool {
+	ok := true
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CHECK\tSTATUS\tDETAIL")
+	for _, c := range r {
+		status := "ok"
+		if !c.ok {
+			status = "FAIL"
+			ok = false
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", c.name, status, c.detail)
+	}
+	w.Flush()
+	return ok
+}
+
+// sinkURLs returns the configured endpoints we send data to, keyed by name
+func sinkURLs() map[string]string {
+	sinks := map[string]string{
+		"webhook":      cfg.WebhookURL,
+		"air quality":  cfg.AirQuality.URL,
+		"error report": cfg.ErrorReport.URL,
+		"sentry":       cfg.ErrorReport.DSN,
+		"http sink":    cfg.HTTPSink.URL,
+		"mqtt":         cfg.MQTT.Broker,
+		"influxdb":     cfg.InfluxDB.URL,
+		"remote write": cfg.RemoteWrite.URL,
+		"pushgateway":  cfg.Pushgateway.URL,
+		"otlp":         cfg.OTLP.Endpoint,
+	}
+	if cfg.PWSWeather.StationID != "" {
+		sinks["pwsweather"] = pwsWeatherURL
+	}
+	if cfg.Windy.APIKey != "" {
+		sinks["windy"] = windyURL
+	}
+	if cfg.Graphite.Address != "" {
+		sinks["graphite"] = "tcp://" + cfg.Graphite.Address
+	}
+	if cfg.CWOP.Callsign != "" {
+		sinks["cwop"] = "tcp://" + cfg.CWOP.Server
+	}
+	for name, u := range sinks {
+		if u == "" {
+			delete(sinks, name)
+		}
+	}
+	return sinks
+}
+
+// dialURL checks we can open a connection to the host of a URL
+func dialURL(rawURL string, timeout time.Duration) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("invalid url: %v", err)
+	}
+	host := u.Host
+	if u.Port() == "" {
+		port := "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+		host = net.JoinHostPort(u.Hostname(), port)
+	}
+	conn, err := net.DialTimeout("tcp", host, timeout)
+	if err != nil {
+		return fmt.Errorf("error connecting to %s: %v", host, err)
+	}
+	return conn.Close()
+}
+
+// checkToken verifies our token against the stations API, returning the
+// stations it can access
+func checkToken() (map[string]stationMeta, error) {
+	meta, err := getStations()
+	if err != nil {
+		return nil, err
+	}
+	stations := make(map[string]stationMeta)
+	for _, s := range meta {
+		stations[strconv.Itoa(s.StationID)] = s
+	}
+	return stations, nil
+}
+
+// checkStation verifies a configured station is accessible and reporting
+func checkStation(s string, accessible map[string]stationMeta) (string, error) {
+	meta, ok := accessible[s]
+	if !ok {
+		return "", fmt.Errorf("station is not accessible with this token")
+	}
+	r, err := getTempestData(s)
+	if err != nil {
+		return "", err
+	}
+	if len(r.Obs) == 0 {
+		return "", fmt.Errorf("%s (%d devices) has no observations", meta.Name, len(meta.Devices))
+	}
+	age := time.Since(time.Unix(int64(r.Obs[0].Timestamp), 0)).Round(time.Second)
+	if !isOnline(r, r.Obs[0], true, time.Now()) {
+		return "", fmt.Errorf("%s is offline, latest observation is %s old", meta.Name, age)
+	}
+	return fmt.Sprintf("%s (%d devices), latest observation %s old", meta.Name, len(meta.Devices), age), nil
+}
+
+// runDoctor implements the doctor subcommand, returning the process exit code
+func runDoctor(args []string) int {
+	fs := flag.NewFlagSet("doctor", flag.ExitOnError)
+	udpDuration := fs.Duration("udp-duration", 10*time.Second, "how long to listen for hub broadcasts, 0 to skip")
+	timeout := fs.Duration("timeout", 5*time.Second, "timeout for sink connectivity checks")
+	registerFlags(fs)
+	fs.Parse(args)
+
+	var report doctorReport
+	var err error
+	cfg, err = loadConfig()
+	setErrorReporting()
+	report.add("config", err, "valid")
+	if err != nil {
+		report.print()
+		return 1
+	}
+	if err := setupAPIClient(); err != nil {
+		report.add("api client", err, "")
+		report.print()
+		return 1
+	}
+
+	var accessible map[string]stationMeta
+	if cfg.Token != "" {
+		accessible, err = checkToken()
+		report.add("api token", err, fmt.Sprintf("%d stations accessible", len(accessible)))
+	}
+	if cfg.Token != "" && err == nil {
+		ids := make([]string, 0, len(accessible))
+		for id := range accessible {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		for _, id := range ids {
+			report.add("accessible station "+id, nil, accessible[id].Name)
+		}
+		checked := cfg.Stations
+		if len(checked) == 0 {
+			// we'd discover every accessible station
+			checked = ids
+		}
+		for _, s := range checked {
+			detail, err := checkStation(s, accessible)
+			report.add("station "+s, err, detail)
+		}
+	}
+
+	if *udpDuration > 0 {
+		res, err := listenUDP(cfg.UDP.Listen, *udpDuration)
+		if err == nil && res.decoded == 0 {
+			err = fmt.Errorf("no hub broadcasts decoded in %s, check the hub is on this network", *udpDuration)
+		}
+		detail := ""
+		if res != nil {
+			detail = fmt.Sprintf("%d packets decoded from hubs %s", res.decoded, joinKeys(res.hubs))
+		}
+		report.add("udp broadcasts", err, detail)
+	}
+
+	sinks := sinkURLs()
+	names := make([]string, 0, len(sinks))
+	for name := range sinks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		report.add("sink "+name, dialURL(sinks[name], *timeout), "reachable")
+	}
+
+	if !report.print() {
+		return 1
+	}
+	fmt.Println()
+	fmt.Println("ready")
+	return 0
+}
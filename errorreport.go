@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"regexp"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+)
+
+// failureReportThreshold is how many consecutive failures of a source we
+// allow before reporting them
+const failureReportThreshold = 3
+
+var (
+	// failures counts the consecutive failures of each source
+	failures   = make(map[string]int)
+	failuresMu sync.Mutex
+	// errorReportClient is the http client used to send error reports
+	errorReportClient = &http.Client{Timeout: 10 * time.Second}
+	// secretParams matches secrets passed as URL query parameters
+	secretParams = regexp.MustCompile(`(?i)((?:api_)?token|key|password|secret)=[^&\s"]+`)
+	// urlCredentials matches credentials embedded in URLs
+	urlCredentials = regexp.MustCompile(`://[^/@\s"]+@`)
+	// reportingMu guards reporting and reportingToken
+	reportingMu sync.Mutex
+	// reporting and reportingToken are our error reporting settings and the
+	// API token to scrub from reports, copied from our config so errors can
+	// be reported without holding configMu
+	reporting      errorReportConfig
+	reportingToken string
+)
+
+// setErrorReporting copies our error reporting settings from our config,
+// whenever it's loaded
+func setErrorReporting() {
+	reportingMu.Lock()
+	defer reportingMu.Unlock()
+	reporting, reportingToken = cfg.ErrorReport, cfg.Token
+}
+
+// reportingConfig returns our error reporting settings and API token
+func reportingConfig() (errorReportConfig, string) {
+	reportingMu.Lock()
+	defer reportingMu.Unlock()
+	return reporting, reportingToken
+}
+
+// errorReport is the payload sent to a generic error reporting endpoint
+type errorReport struct {
+	Level     string `json:"level"`
+	Source    string `json:"source"`
+	Message   string `json:"message"`
+	Stack     string `json:"stack,omitempty"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// errorReporting returns whether error reporting is enabled
+func errorReporting() bool {
+	c, _ := reportingConfig()
+	return c.DSN != "" || c.URL != ""
+}
+
+// scrub removes our API token and other credentials from s
+func scrub(s string) string {
+	if _, token := reportingConfig(); token != "" {
+		s = strings.ReplaceAll(s, token, "[REDACTED]")
+	}
+	s = secretParams.ReplaceAllString(s, "$1=[REDACTED]")
+	return urlCredentials.ReplaceAllString(s, "://[REDACTED]@")
+}
+
+// reportFailure records a failure of source, reporting it once the source has
+// failed failureReportThreshold times in a row
+func reportFailure(source string, err error) {
+	if !errorReporting() {
+		return
+	}
+	failuresMu.Lock()
+	failures[source]++
+	n := failures[source]
+	failuresMu.Unlock()
+	if n == failureReportThreshold {
+		msg := fmt.Sprintf("%s failed %d times in a row: %v", source, n, err)
+		go sendErrorReport(errorReport{Level: "error", Source: source, Message: msg})
+	}
+}
+
+// reportSuccess resets the consecutive failure count of source
+func reportSuccess(source string) {
+	failuresMu.Lock()
+	delete(failures, source)
+	failuresMu.Unlock()
+}
+
+// reportFatal synchronously reports an error we're about to exit on
+func reportFatal(source string, err error) {
+	if errorReporting() {
+		sendErrorReport(errorReport{Level: "fatal", Source: source, Message: err.Error()})
+	}
+}
+
+// reportPanic reports a panic in the calling goroutine before re-panicking.
+// It must be deferred.
+func reportPanic(source string) {
+	if !errorReporting() {
+		return
+	}
+	if p := recover(); p != nil {
+		sendErrorReport(errorReport{
+			Level:   "fatal",
+			Source:  source,
+			Message: fmt.Sprintf("panic: %v", p),
+			Stack:   string(debug.Stack()),
+		})
+		panic(p)
+	}
+}
+
+// sendErrorReport scrubs and sends a report to Sentry or our generic endpoint
+func sendErrorReport(e errorReport) {
+	e.Message = scrub(e.Message)
+	e.Stack = scrub(e.Stack)
+	e.Timestamp = time.Now().Unix()
+	c, _ := reportingConfig()
+	var err error
+	if c.DSN != "" {
+		err = sendSentry(c.DSN, e)
+	} else {
+		err = postErrorReport(c.URL, nil, e)
+	}
+	if err != nil {
+		slog.Error("error sending error report", "err", err)
+	}
+}
+
+// sendSentry sends a report to the Sentry store API described by a DSN
+func sendSentry(sentryDSN string, e errorReport) error {
+	dsn, err := url.Parse(sentryDSN)
+	if err != nil || dsn.User == nil {
+		return fmt.Errorf("invalid sentry dsn")
+	}
+	project := strings.TrimPrefix(dsn.Path, "/")
+	endpoint := fmt.Sprintf("%s://%s/api/%s/store/", dsn.Scheme, dsn.Host, project)
+	headers := map[string]string{
+		"X-Sentry-Auth": fmt.Sprintf("Sentry sentry_version=7, sentry_client=tempest-exporter, sentry_key=%s", dsn.User.Username()),
+	}
+	id := make([]byte, 16)
+	rand.Read(id)
+	event := map[string]interface{}{
+		"event_id":  hex.EncodeToString(id),
+		"timestamp": e.Timestamp,
+		"level":     e.Level,
+		"logger":    e.Source,
+		"platform":  "go",
+		"message":   e.Message,
+		"extra":     map[string]string{"stack": e.Stack},
+	}
+	return postErrorReport(endpoint, headers, event)
+}
+
+// postErrorReport POSTs a JSON payload to an error reporting endpoint
+func postErrorReport(endpoint string, headers map[string]string, payload interface{}) error {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := errorReportClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected response %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"math"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// et0MaxGap is the longest gap between observations we integrate the
+	// evapotranspiration rate over, so an outage doesn't add a spurious lump
+	et0MaxGap = 15 * time.Minute
+	// et0DefaultRatio is the relative shortwave radiation (Rs/Rso) we assume
+	// at night until we've seen the sun high enough to measure it
+	et0DefaultRatio = 0.7
+	// et0RatioElevation is the lowest solar elevation, in degrees, at which we
+	// trust the measured relative shortwave radiation (FAO-56 suggests 17°)
+	et0RatioElevation = 17
+)
+
+var (
+	// et0States holds each station's running evapotranspiration
+	et0States = make(map[string]*et0State)
+	// et0Metrics are the gauges exporting reference evapotranspiration
+	et0Metrics = make(MetricsMap)
+)
+
+// et0State is a station's running reference evapotranspiration for the day
+type et0State struct {
+	ratio float64
+	last  time.Time
+	rate  float64
+	day   time.Time
+	total float64
+}
+
+// et0Rate returns the FAO-56 Penman-Monteith hourly reference
+// evapotranspiration rate, in mm/hour, for the air temperature (°C), relative
+// humidity (%), wind speed at 2m (m/s), solar radiation (W/m²), air pressure
+// (kPa) and solar elevation (degrees) at time t, given the relative shortwave
+// radiation (Rs/Rso) to assume when the sun is too low to measure it. It also
+// returns the relative shortwave radiation it used.
+func et0Rate(tempC, rh, wind, solar, pressure, elevation, sunElevation, ratio float64, t time.Time) (float64, float64) {
+	// incoming shortwave radiation, and clear sky radiation from the
+	// extraterrestrial radiation, in MJ/m²/hour
+	rs := solar * 0.0036
+	var rso float64
+	if sunElevation > 0 {
+		dr := 1 + 0.033*math.Cos(2*math.Pi*float64(t.YearDay())/365)
+		ra := 4.92 * dr * math.Sin(rad(sunElevation))
+		rso = (0.75 + 2e-5*elevation) * ra
+	}
+	if sunElevation >= et0RatioElevation && rso > 0 {
+		ratio = math.Max(0.3, math.Min(1, rs/rso))
+	}
+	es := saturationVaporPressure(tempC)
+	ea := es * rh / 100
+	// net longwave radiation, then net radiation and soil heat flux
+	rnl := 2.043e-10 * math.Pow(tempC+273.16, 4) * (0.34 - 0.14*math.Sqrt(ea)) * (1.35*ratio - 0.35)
+	rn := 0.77*rs - rnl
+	g := 0.5 * rn
+	if sunElevation > 0 {
+		g = 0.1 * rn
+	}
+	delta := 4098 * es / math.Pow(tempC+237.3, 2)
+	gamma := 0.000665 * pressure
+	et0 := (0.408*delta*(rn-g) + gamma*37/(tempC+273)*wind*(es-ea)) / (delta + gamma*(1+0.34*wind))
+	return math.Max(0, et0), ratio
+}
+
+// atmosphericPressure returns the standard air pressure in kPa at an
+// elevation in meters (FAO-56 eq. 7)
+func atmosphericPressure(elevation float64) float64 {
+	return 101.3 * math.Pow((293-0.0065*elevation)/293, 5.26)
+}
+
+// registerET0 creates and registers the gauges for reference evapotranspiration
+func registerET0(labelNames []string) {
+	help := map[string]string{
+		"evapotranspiration_rate":      "FAO-56 Penman-Monteith reference evapotranspiration rate per hour",
+		"evapotranspiration_local_day": "Reference evapotranspiration accumulated today in local time",
+	}
+	for name, h := range help {
+		et0Metrics[name] = prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: ns,
+				Subsystem: ss,
+				Name:      name,
+				Help:      h,
+			},
+			labelNames,
+		)
+		weatherRegistry.MustRegister(et0Metrics[name])
+	}
+}
+
+// setET0 updates a station's reference evapotranspiration from an
+// observation, integrating its rate over the local day. It needs the
+// station's coordinates, so stations heard over UDP are skipped.
+func setET0(stationID string, r response, o observation, labels prometheus.Labels) {
+	for _, name := range []string{"air_temperature", "relative_humidity", "wind_avg", "solar_radiation"} {
+		if !o.has(name) {
+			return
+		}
+	}
+	if r.Latitude == 0 && r.Longitude == 0 {
+		return
+	}
+	s, ok := et0States[stationID]
+	if !ok {
+		s = &et0State{ratio: et0DefaultRatio}
+		et0States[stationID] = s
+	}
+	t := time.Unix(int64(o.Timestamp), 0).In(location(r.Timezone))
+	if !t.After(s.last) {
+		return
+	}
+	pressure := atmosphericPressure(r.Elevation)
+	if o.has("station_pressure") && o.StationPressure > 0 {
+		pressure = o.StationPressure / 10
+	}
+	sun := solarElevation(t, r.Latitude, r.Longitude)
+	rate, ratio := et0Rate(o.AirTemperature, o.RelativeHumidity, o.WindAvg, o.SolarRadiation, pressure, r.Elevation, sun, s.ratio, t)
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	switch {
+	case !day.Equal(s.day):
+		s.day, s.total = day, 0
+	case t.Sub(s.last) <= et0MaxGap:
+		s.total += s.rate * t.Sub(s.last).Hours()
+	}
+	s.ratio, s.last, s.rate = ratio, t, rate
+	et0Metrics["evapotranspiration_rate"].With(labels).Set(rate)
+	et0Metrics["evapotranspiration_local_day"].With(labels).Set(s.total)
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestAtmosphericPressure(t *testing.T) {
+	tests := []struct {
+		elevation, want float64
+	}{
+		{elevation: 0, want: 101.3},
+		// FAO-56 example 2
+		{elevation: 1800, want: 81.8},
+	}
+	for _, tt := range tests {
+		if got := atmosphericPressure(tt.elevation); math.Abs(got-tt.want) > 0.05 {
+			t.Errorf("atmosphericPressure(%v) = %v, want %v", tt.elevation, got, tt.want)
+		}
+	}
+}
+
+func TestET0Rate(t *testing.T) {
+	day := time.Date(2026, 10, 1, 14, 30, 0, 0, time.UTC)
+	// the solar elevation giving FAO-56 example 19's clear sky radiation
+	dr := 1 + 0.033*math.Cos(2*math.Pi*float64(day.YearDay())/365)
+	noon := math.Asin(3.543/(0.75*4.92*dr)) * 180 / math.Pi
+	tests := []struct {
+		name                                     string
+		tempC, rh, wind, solar, sunElevation, in float64
+		want, tolerance, ratio                   float64
+	}{
+		// FAO-56 example 19, 14-15h and 02-03h
+		{name: "fao-56 day", tempC: 38, rh: 52, wind: 3.3, solar: 2.450 / 0.0036, sunElevation: noon, in: 0.7, want: 0.63, tolerance: 0.02, ratio: 0.69},
+		{name: "fao-56 night", tempC: 28, rh: 90, wind: 1.9, sunElevation: -10, in: 0.8, want: 0, tolerance: 0.01, ratio: 0.8},
+		{name: "low sun keeps ratio", tempC: 20, rh: 50, wind: 2, solar: 100, sunElevation: 5, in: 0.6, want: 0.107, tolerance: 0.001, ratio: 0.6},
+		{name: "ratio capped at 1", tempC: 20, rh: 50, wind: 2, solar: 1000, sunElevation: 20, in: 0.6, want: 0.590, tolerance: 0.001, ratio: 1},
+		{name: "ratio floored at 0.3", tempC: 20, rh: 50, wind: 2, solar: 10, sunElevation: 20, in: 0.6, want: 0.080, tolerance: 0.001, ratio: 0.3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ratio := et0Rate(tt.tempC, tt.rh, tt.wind, tt.solar, 101.2, 0, tt.sunElevation, tt.in, day)
+			if math.Abs(got-tt.want) > tt.tolerance {
+				t.Errorf("et0Rate() = %v, want %v ± %v", got, tt.want, tt.tolerance)
+			}
+			if math.Abs(ratio-tt.ratio) > 0.005 {
+				t.Errorf("et0Rate() ratio = %v, want %v", ratio, tt.ratio)
+			}
+			if got < 0 {
+				t.Errorf("et0Rate() = %v, want it non-negative", got)
+			}
+		})
+	}
+}
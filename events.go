@@ -0,0 +1,79 @@
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	// lightningStrikes counts the strikes reported by evt_strike broadcasts
+	lightningStrikes *prometheus.CounterVec
+	// lightningLastStrike exports the distance, energy and time of the latest
+	// strike
+	lightningLastStrike = make(MetricsMap)
+	// rainStarts counts the evt_precip broadcasts sent when rain starts
+	rainStarts *prometheus.CounterVec
+	// lastRainStart is the time of the latest evt_precip broadcast
+	lastRainStart *prometheus.GaugeVec
+)
+
+// registerEvents creates and registers the metrics for event broadcasts
+func registerEvents(labelNames []string) {
+	lightningStrikes = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: ns,
+			Name:      "lightning_strikes_total",
+			Help:      "Lightning strikes detected since the exporter started",
+		},
+		labelNames,
+	)
+	weatherRegistry.MustRegister(lightningStrikes)
+	help := map[string]string{
+		"lightning_last_strike_distance":          "Estimated distance to the latest lightning strike",
+		"lightning_last_strike_energy":            "Energy of the latest lightning strike, in arbitrary units",
+		"lightning_last_strike_timestamp_seconds": "Unix timestamp of the latest lightning strike",
+	}
+	for name, h := range help {
+		lightningLastStrike[name] = prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: ns,
+				Name:      name,
+				Help:      h,
+			},
+			labelNames,
+		)
+		weatherRegistry.MustRegister(lightningLastStrike[name])
+	}
+	rainStarts = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: ns,
+			Name:      "rain_starts_total",
+			Help:      "Rain start events since the exporter started",
+		},
+		labelNames,
+	)
+	lastRainStart = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: ns,
+			Name:      "last_rain_start_timestamp_seconds",
+			Help:      "Unix timestamp of the latest rain start event",
+		},
+		labelNames,
+	)
+	weatherRegistry.MustRegister(rainStarts, lastRainStart)
+}
+
+// handleStrike updates our lightning metrics from an evt_strike broadcast
+func handleStrike(m udpMessage) {
+	labels := udpLabels(m.HubSN)
+	lightningStrikes.With(labels).Inc()
+	lightningLastStrike["lightning_last_strike_timestamp_seconds"].With(labels).Set(m.Evt[0])
+	lightningLastStrike["lightning_last_strike_distance"].With(labels).Set(m.Evt[1])
+	lightningLastStrike["lightning_last_strike_energy"].With(labels).Set(m.Evt[2])
+}
+
+// handleRainStart updates our rain start metrics from an evt_precip broadcast
+func handleRainStart(m udpMessage) {
+	labels := udpLabels(m.HubSN)
+	rainStarts.With(labels).Inc()
+	lastRainStart.With(labels).Set(m.Evt[0])
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/nalbury/tempest-exporter/pkg/weatherflow"
+)
+
+// hourlyForecast is a single hour of the forecast
+type hourlyForecast = weatherflow.HourlyForecast
+
+// dailyForecast is a single day of the forecast
+type dailyForecast = weatherflow.DailyForecast
+
+// forecastResponse is our response from the weatherflow forecast API
+type forecastResponse weatherflow.Forecast
+
+var (
+	// forecastFetched holds when we last fetched each station's forecast
+	forecastFetched = make(map[string]time.Time)
+	// forecastMetrics are the gauges exporting our forecast summaries
+	forecastMetrics = make(MetricsMap)
+	// hourlyMetrics and dailyMetrics are the gauges exporting the forecast
+	// for each hour and day ahead
+	hourlyMetrics = make(MetricsMap)
+	dailyMetrics  = make(MetricsMap)
+)
+
+// getForecast retrieves the forecast for a station in metric units
+func getForecast(client *weatherflow.Client, s string) (forecastResponse, error) {
+	id, err := strconv.Atoi(s)
+	if err != nil {
+		return forecastResponse{}, fmt.Errorf("invalid station id %q", s)
+	}
+	f, err := client.Forecast(context.Background(), id)
+	return forecastResponse(f), err
+}
+
+// forecastSummary holds the convenience values we derive from a forecast
+type forecastSummary struct {
+	rainExpected12h  bool
+	precipProbMax12h float64
+	freezeTonight    bool
+	minTempTonight   float64
+	maxGust24h       float64
+}
+
+// summarize derives our convenience values from the hourly forecast, as of now
+// in the station's timezone. Tonight runs from 18:00 (or now, if later) to
+// 09:00 the next morning.
+func (r forecastResponse) summarize(now time.Time, loc *time.Location) forecastSummary {
+	now = now.In(loc)
+	tonightStart := time.Date(now.Year(), now.Month(), now.Day(), 18, 0, 0, 0, loc)
+	tonightEnd := time.Date(now.Year(), now.Month(), now.Day()+1, 9, 0, 0, 0, loc)
+	if now.Hour() < 9 {
+		tonightStart = now
+		tonightEnd = time.Date(now.Year(), now.Month(), now.Day(), 9, 0, 0, 0, loc)
+	}
+
+	s := forecastSummary{minTempTonight: math.Inf(1)}
+	for _, h := range r.Forecast.Hourly {
+		t := time.Unix(int64(h.Time), 0)
+		ahead := t.Sub(now)
+		if ahead < -time.Hour {
+			continue
+		}
+		if ahead <= 12*time.Hour {
+			s.precipProbMax12h = math.Max(s.precipProbMax12h, h.PrecipProbability)
+			if h.PrecipProbability >= cfg.Forecast.RainProbability || h.Precip > 0 {
+				s.rainExpected12h = true
+			}
+		}
+		if ahead <= 24*time.Hour {
+			s.maxGust24h = math.Max(s.maxGust24h, h.WindGust)
+		}
+		if !t.Before(tonightStart.Add(-time.Hour)) && t.Before(tonightEnd) {
+			s.minTempTonight = math.Min(s.minTempTonight, h.AirTemperature)
+		}
+	}
+	s.freezeTonight = s.minTempTonight <= 0
+	return s
+}
+
+// registerForecast creates and registers our forecast gauges
+func registerForecast(labelNames []string) {
+	help := map[string]string{
+		"rain_expected_next_12h":          "1 if rain is forecast in the next 12 hours",
+		"precip_probability_max_next_12h": "Highest forecast chance of precipitation in the next 12 hours, in percent",
+		"freeze_expected_tonight":         "1 if the temperature is forecast to drop to freezing tonight",
+		"air_temperature_min_tonight":     "Lowest forecast air temperature tonight",
+		"max_gust_next_24h":               "Highest forecast wind gust in the next 24 hours",
+	}
+	for name, h := range help {
+		forecastMetrics[name] = prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: ns,
+				Subsystem: "forecast",
+				Name:      name,
+				Help:      h,
+			},
+			labelNames,
+		)
+		weatherRegistry.MustRegister(forecastMetrics[name])
+	}
+
+	hourly := map[string]string{
+		"air_temperature":    "Forecast air temperature for the hour",
+		"precip":             "Forecast precipitation for the hour",
+		"precip_probability": "Forecast chance of precipitation for the hour, in percent",
+		"wind_avg":           "Forecast average wind speed for the hour",
+		"wind_gust":          "Forecast wind gust for the hour",
+		"conditions":         "1 for the forecast conditions for the hour",
+	}
+	for name, h := range hourly {
+		names := append(append([]string{}, labelNames...), "hours_ahead")
+		if name == "conditions" {
+			names = append(names, "conditions")
+		}
+		hourlyMetrics[name] = prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: ns,
+				Subsystem: "forecast_hourly",
+				Name:      name,
+				Help:      h,
+			},
+			names,
+		)
+		weatherRegistry.MustRegister(hourlyMetrics[name])
+	}
+
+	daily := map[string]string{
+		"air_temperature_high": "Forecast high air temperature for the day",
+		"air_temperature_low":  "Forecast low air temperature for the day",
+		"precip_probability":   "Forecast chance of precipitation for the day, in percent",
+		"conditions":           "1 for the forecast conditions for the day",
+	}
+	for name, h := range daily {
+		names := append(append([]string{}, labelNames...), "days_ahead")
+		if name == "conditions" {
+			names = append(names, "conditions")
+		}
+		dailyMetrics[name] = prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: ns,
+				Subsystem: "forecast_daily",
+				Name:      name,
+				Help:      h,
+			},
+			names,
+		)
+		weatherRegistry.MustRegister(dailyMetrics[name])
+	}
+}
+
+// withLabel returns a copy of labels with an extra label added
+func withLabel(labels prometheus.Labels, name, value string) prometheus.Labels {
+	l := prometheus.Labels{name: value}
+	for k, v := range labels {
+		l[k] = v
+	}
+	return l
+}
+
+// setForecastPeriods updates the gauges for each hour and day ahead in a
+// station's forecast, as of now
+func setForecastPeriods(f forecastResponse, now time.Time, labels prometheus.Labels) {
+	metricsMu.Lock()
+	defer metricsMu.Unlock()
+	// conditions are labels, so clear out the previous forecast's
+	hourlyMetrics["conditions"].DeletePartialMatch(labels)
+	dailyMetrics["conditions"].DeletePartialMatch(labels)
+
+	hours := 0
+	for _, h := range f.Forecast.Hourly {
+		if hours >= cfg.Forecast.Hours {
+			break
+		}
+		if time.Unix(int64(h.Time), 0).Before(now) {
+			continue
+		}
+		hours++
+		l := withLabel(labels, "hours_ahead", strconv.Itoa(hours))
+		hourlyMetrics["air_temperature"].With(l).Set(h.AirTemperature)
+		hourlyMetrics["precip"].With(l).Set(h.Precip)
+		hourlyMetrics["precip_probability"].With(l).Set(h.PrecipProbability)
+		hourlyMetrics["wind_avg"].With(l).Set(h.WindAvg)
+		hourlyMetrics["wind_gust"].With(l).Set(h.WindGust)
+		hourlyMetrics["conditions"].With(withLabel(l, "conditions", h.Conditions)).Set(1)
+	}
+
+	// the first day is today, which has already started
+	for i, d := range f.Forecast.Daily {
+		if i >= cfg.Forecast.Days {
+			break
+		}
+		l := withLabel(labels, "days_ahead", strconv.Itoa(i))
+		dailyMetrics["air_temperature_high"].With(l).Set(d.AirTempHigh)
+		dailyMetrics["air_temperature_low"].With(l).Set(d.AirTempLow)
+		dailyMetrics["precip_probability"].With(l).Set(d.PrecipProbability)
+		dailyMetrics["conditions"].With(withLabel(l, "conditions", d.Conditions)).Set(1)
+	}
+}
+
+// fetchForecast fetches a station's forecast with client if it's due,
+// returning nil if it isn't
+func fetchForecast(client *weatherflow.Client, c forecastConfig, station string) (*forecastResponse, error) {
+	if time.Since(forecastFetched[station]) < time.Duration(c.Interval) {
+		return nil, nil
+	}
+	f, err := getForecast(client, station)
+	if err != nil {
+		return nil, err
+	}
+	forecastFetched[station] = time.Now()
+	return &f, nil
+}
+
+// setForecast updates our forecast gauges from a station's forecast
+func setForecast(r response, f forecastResponse, labels prometheus.Labels) {
+	s := f.summarize(time.Now(), location(r.Timezone))
+	forecastMetrics["rain_expected_next_12h"].With(labels).Set(boolToFloat(s.rainExpected12h))
+	forecastMetrics["precip_probability_max_next_12h"].With(labels).Set(s.precipProbMax12h)
+	forecastMetrics["freeze_expected_tonight"].With(labels).Set(boolToFloat(s.freezeTonight))
+	if !math.IsInf(s.minTempTonight, 1) {
+		forecastMetrics["air_temperature_min_tonight"].With(labels).Set(s.minTempTonight)
+	}
+	forecastMetrics["max_gust_next_24h"].With(labels).Set(s.maxGust24h)
+	setForecastPeriods(f, time.Now(), labels)
+}
+
+// boolToFloat returns 1 for true and 0 for false
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,138 @@
+//go:build ignore
+
+// gen_metrics generates observation_metrics.go, with a gauge and a setter for
+// each numeric field of weatherflow.Observation. Each gauge is named for the
+// field's JSON key and documented with its doc comment.
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// field is a numeric observation field
+type field struct {
+	name     string
+	metric   string
+	help     string
+	optional bool
+}
+
+func main() {
+	fields, err := observationFields("pkg/weatherflow/types.go")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	var b bytes.Buffer
+	b.WriteString(`// Code generated by gen_metrics.go; DO NOT EDIT.
+
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/nalbury/tempest-exporter/pkg/weatherflow"
+)
+
+// observationGauges returns a gauge for each numeric field of an observation,
+// keyed by metric name
+func observationGauges(labelNames []string) MetricsMap {
+	return MetricsMap{
+`)
+	for _, f := range fields {
+		fmt.Fprintf(&b, "%q: prometheus.NewGaugeVec(prometheus.GaugeOpts{Namespace: ns, Subsystem: ss, Name: %q, Help: %q}, labelNames),\n", f.metric, f.metric, f.help)
+	}
+	b.WriteString(`}
+}
+
+// fieldValues returns the numeric fields of an observation keyed by metric
+// name, leaving out optional ones the API hasn't reported
+func fieldValues(o weatherflow.Observation) map[string]float64 {
+	v := map[string]float64{
+`)
+	for _, f := range fields {
+		if !f.optional {
+			fmt.Fprintf(&b, "%q: o.%s,\n", f.metric, f.name)
+		}
+	}
+	b.WriteString("}\n")
+	for _, f := range fields {
+		if f.optional {
+			fmt.Fprintf(&b, "if o.%s != nil {\nv[%q] = *o.%[1]s\n}\n", f.name, f.metric)
+		}
+	}
+	b.WriteString(`return v
+}
+
+// setFieldValue sets the numeric field of an observation for a metric name
+func setFieldValue(o *weatherflow.Observation, metric string, v float64) {
+	switch metric {
+`)
+	for _, f := range fields {
+		if f.optional {
+			fmt.Fprintf(&b, "case %q:\no.%s = &v\n", f.metric, f.name)
+		} else {
+			fmt.Fprintf(&b, "case %q:\no.%s = v\n", f.metric, f.name)
+		}
+	}
+	b.WriteString("}\n}\n")
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error formatting generated code: %v\n", err)
+		os.Exit(1)
+	}
+	if err := os.WriteFile("observation_metrics.go", src, 0644); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// observationFields returns the numeric fields of the Observation struct in a
+// source file, in order
+func observationFields(path string) ([]field, error) {
+	f, err := parser.ParseFile(token.NewFileSet(), path, nil, parser.ParseComments)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing %s: %v", path, err)
+	}
+	obj := f.Scope.Lookup("Observation")
+	if obj == nil {
+		return nil, fmt.Errorf("no Observation type in %s", path)
+	}
+	st, ok := obj.Decl.(*ast.TypeSpec).Type.(*ast.StructType)
+	if !ok {
+		return nil, fmt.Errorf("Observation in %s is not a struct", path)
+	}
+	var fields []field
+	for _, fl := range st.Fields.List {
+		typ := fl.Type
+		optional := false
+		if star, ok := typ.(*ast.StarExpr); ok {
+			typ, optional = star.X, true
+		}
+		if id, ok := typ.(*ast.Ident); !ok || id.Name != "float64" || fl.Tag == nil {
+			continue
+		}
+		tag, err := strconv.Unquote(fl.Tag.Value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tag on %s: %v", fl.Names[0].Name, err)
+		}
+		metric := strings.Split(reflect.StructTag(tag).Get("json"), ",")[0]
+		help := strings.Join(strings.Fields(fl.Doc.Text()), " ")
+		if help == "" {
+			return nil, fmt.Errorf("%s has no doc comment to use as help", fl.Names[0].Name)
+		}
+		for _, n := range fl.Names {
+			fields = append(fields, field{name: n.Name, metric: metric, help: help, optional: optional})
+		}
+	}
+	return fields, nil
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"strconv"
+	"text/template"
+	"time"
+)
+
+// graphiteUnsafe matches characters that can't appear in a node of a Graphite
+// metric path
+var graphiteUnsafe = regexp.MustCompile(`[^a-zA-Z0-9_\-]`)
+
+// graphitePathData is the data our metric path template is rendered with
+type graphitePathData struct {
+	// StationID is the station the observation is from
+	StationID string
+	// Labels are the station's labels
+	Labels map[string]string
+	// Field is the metric name of the reading
+	Field string
+}
+
+// graphiteSink sends each reading of an observation to a Graphite carbon
+// server with the plaintext protocol
+type graphiteSink struct {
+	c    graphiteConfig
+	path *template.Template
+}
+
+// newGraphiteSink parses the metric path template for a Graphite sink
+func newGraphiteSink(c graphiteConfig) (*graphiteSink, error) {
+	path, err := template.New("path").Parse(c.PathTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing graphite path template: %v", err)
+	}
+	return &graphiteSink{c: c, path: path}, nil
+}
+
+// name implements sink
+func (s *graphiteSink) name() string {
+	return "graphite"
+}
+
+// graphiteNode replaces the characters of a value that Graphite would read as
+// path separators or otherwise reject, so it makes up a single node of a path
+func graphiteNode(v string) string {
+	return graphiteUnsafe.ReplaceAllString(v, "_")
+}
+
+// lines formats an observation as a plaintext protocol line per reading
+func (s *graphiteSink) lines(rec sinkRecord) ([]byte, error) {
+	labels := make(map[string]string, len(rec.Labels))
+	for k, v := range rec.Labels {
+		labels[k] = graphiteNode(v)
+	}
+	d := graphitePathData{StationID: graphiteNode(rec.StationID), Labels: labels}
+	fields := make([]string, 0, len(rec.Values))
+	for k := range rec.Values {
+		fields = append(fields, k)
+	}
+	sort.Strings(fields)
+	var b bytes.Buffer
+	for _, k := range fields {
+		d.Field = graphiteNode(k)
+		var path bytes.Buffer
+		if err := s.path.Execute(&path, d); err != nil {
+			return nil, fmt.Errorf("error rendering graphite path template: %v", err)
+		}
+		fmt.Fprintf(&b, "%s %s %d\n", path.String(), strconv.FormatFloat(rec.Values[k], 'f', -1, 64), rec.Time.Unix())
+	}
+	return b.Bytes(), nil
+}
+
+// send implements sink
+func (s *graphiteSink) send(rec sinkRecord) error {
+	if rec.Kind != "observation" || len(rec.Values) == 0 {
+		return errSkipped
+	}
+	b, err := s.lines(rec)
+	if err != nil {
+		return err
+	}
+	conn, err := net.DialTimeout("tcp", s.c.Address, uploadTimeout)
+	if err != nil {
+		return fmt.Errorf("error connecting to graphite: %v", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(uploadTimeout))
+	if _, err := conn.Write(b); err != nil {
+		return fmt.Errorf("error writing to graphite: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGraphiteNode(t *testing.T) {
+	tests := []struct {
+		v, want string
+	}{
+		{v: "ST-00012345", want: "ST-00012345"},
+		{v: "Back Yard", want: "Back_Yard"},
+		{v: "a.b/c", want: "a_b_c"},
+	}
+	for _, tt := range tests {
+		if got := graphiteNode(tt.v); got != tt.want {
+			t.Errorf("graphiteNode(%q) = %q, want %q", tt.v, got, tt.want)
+		}
+	}
+}
+
+func TestGraphiteLines(t *testing.T) {
+	rec := sinkRecord{
+		StationID: "1",
+		Labels:    map[string]string{"station_name": "Back Yard"},
+		Values:    map[string]float64{"uv": 3, "air_temperature": -1.5},
+		Time:      time.Unix(1700000000, 0),
+	}
+	tests := []struct {
+		name, path, want string
+		err              bool
+	}{
+		{
+			name: "default path",
+			path: "tempest.{{.StationID}}.{{.Field}}",
+			want: "tempest.1.air_temperature -1.5 1700000000\ntempest.1.uv 3 1700000000\n",
+		},
+		{
+			name: "labels",
+			path: "weather.{{.Labels.station_name}}.{{.Field}}",
+			want: "weather.Back_Yard.air_temperature -1.5 1700000000\nweather.Back_Yard.uv 3 1700000000\n",
+		},
+		{name: "failing template", path: "{{.Missing}}", err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := newGraphiteSink(graphiteConfig{PathTemplate: tt.path})
+			if err != nil {
+				t.Fatalf("newGraphiteSink() error = %v", err)
+			}
+			got, err := s.lines(rec)
+			if (err != nil) != tt.err {
+				t.Fatalf("lines() error = %v, want error %v", err, tt.err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("lines() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	// up is 1 if the last fetch of a station from the API succeeded
+	up = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: ns,
+			Name:      "up",
+			Help:      "Whether the last fetch of the station from the WeatherFlow API succeeded",
+		},
+		[]string{"station_id"},
+	)
+	// lastScrapeError is 1 if the last fetch of a station failed
+	lastScrapeError = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: ns,
+			Name:      "last_scrape_error",
+			Help:      "Whether the last fetch of the station from the WeatherFlow API resulted in an error",
+		},
+		[]string{"station_id"},
+	)
+	// scrapeDuration is how long the last fetch of a station took
+	scrapeDuration = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: ns,
+			Name:      "scrape_duration_seconds",
+			Help:      "Duration of the last fetch and update of the station",
+		},
+		[]string{"station_id"},
+	)
+	// observationMu guards observationTimes
+	observationMu sync.Mutex
+	// observationTimes holds the timestamp of each station's latest observation
+	observationTimes = make(map[string]float64)
+	// observationTimestamp and observationAge describe the metrics exported by
+	// observationCollector
+	observationTimestamp = prometheus.NewDesc(
+		ns+"_observation_timestamp_seconds",
+		"Unix timestamp of the station's latest observation",
+		[]string{"station_id"}, nil,
+	)
+	observationAge = prometheus.NewDesc(
+		ns+"_observation_age_seconds",
+		"Seconds since the station's latest observation, as of the scrape",
+		[]string{"station_id"}, nil,
+	)
+)
+
+// observationCollector exports the time and age of each station's latest
+// observation, computing the age when scraped so it keeps growing if the
+// station stops reporting
+type observationCollector struct{}
+
+// Describe implements prometheus.Collector
+func (observationCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- observationTimestamp
+	ch <- observationAge
+}
+
+// Collect implements prometheus.Collector
+func (observationCollector) Collect(ch chan<- prometheus.Metric) {
+	observationMu.Lock()
+	defer observationMu.Unlock()
+	now := float64(time.Now().UnixNano()) / 1e9
+	for station, t := range observationTimes {
+		ch <- prometheus.MustNewConstMetric(observationTimestamp, prometheus.GaugeValue, t, station)
+		ch <- prometheus.MustNewConstMetric(observationAge, prometheus.GaugeValue, now-t, station)
+	}
+}
+
+// recordObservation notes the timestamp of a station's latest observation
+func recordObservation(station string, o observation) {
+	if !o.has("timestamp") || o.Timestamp <= 0 {
+		return
+	}
+	observationMu.Lock()
+	defer observationMu.Unlock()
+	observationTimes[station] = o.Timestamp
+}
+
+// recordScrape updates our health metrics after polling a station
+func recordScrape(station string, took time.Duration, err error) {
+	scrapeDuration.WithLabelValues(station).Set(took.Seconds())
+	up.WithLabelValues(station).Set(boolToFloat(err == nil))
+	lastScrapeError.WithLabelValues(station).Set(boolToFloat(err != nil))
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"reflect"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	// configHash identifies the active configuration
+	configHash string
+	// heartbeat is updated every time the poller completes a cycle
+	heartbeat = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: ns,
+			Subsystem: "exporter",
+			Name:      "heartbeat_timestamp_seconds",
+			Help:      "Unix timestamp of the poller's last cycle, labelled with the active config hash and data source",
+		},
+		[]string{"config_hash", "source"},
+	)
+)
+
+// hashConfig returns a short hash of the exporter's effective configuration,
+// leaving out secrets so the hash can't be used to guess them
+func hashConfig() string {
+	c := cfg
+	redactSecrets(reflect.ValueOf(&c).Elem())
+	// json sorts map keys and leaves out unexported fields like compiled
+	// regexps, so equal configs hash the same
+	b, err := json.Marshal(c)
+	if err != nil {
+		slog.Error("error hashing config", "err", err)
+		return ""
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])[:16]
+}
+
+// beat updates our heartbeat, and pings systemd's watchdog
+func beat() {
+	heartbeat.WithLabelValues(configHash, cfg.Source).Set(float64(time.Now().Unix()))
+	pingWatchdog()
+}
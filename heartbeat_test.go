@@ -0,0 +1,52 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHashConfig(t *testing.T) {
+	defer func(c config) { cfg = c }(cfg)
+	cfg = defaultConfig()
+	base := hashConfig()
+	if hashConfig() != base {
+		t.Fatal("hashConfig() isn't stable for the same config")
+	}
+
+	cfg.Token = "secret"
+	cfg.MQTT.Password = "secret"
+	cfg.OTLP.Headers = stringMap{"Authorization": "Bearer secret"}
+	if got := hashConfig(); got != base {
+		t.Errorf("hashConfig() = %s after changing secrets, want %s", got, base)
+	}
+	if cfg.Token != "secret" || cfg.OTLP.Headers["Authorization"] == "" {
+		t.Error("hashConfig() cleared secrets in the active config")
+	}
+
+	cfg.PollInterval = duration(5 * time.Minute)
+	if got := hashConfig(); got == base {
+		t.Error("hashConfig() didn't change after changing the poll interval")
+	}
+}
+
+func TestHashConfigRelabel(t *testing.T) {
+	defer func(c config) { cfg = c }(cfg)
+	// build returns a config with freshly compiled relabel rules
+	build := func() config {
+		c := defaultConfig()
+		c.Relabel = relabelRules{
+			{Action: "rename", Metric: "tempest_(.*)", Replacement: "weather_$1"},
+			{Action: "replace", Label: "station_name", Regex: "Home", Replacement: "garden"},
+		}
+		if err := c.Relabel.compile(); err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+	cfg = build()
+	base := hashConfig()
+	cfg = build()
+	if got := hashConfig(); got != base {
+		t.Errorf("hashConfig() = %s for an identical config, want %s", got, base)
+	}
+}
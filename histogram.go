@@ -0,0 +1,75 @@
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// nativeBucketFactor is the growth factor between native histogram buckets,
+// giving roughly 10% resolution
+const nativeBucketFactor = 1.1
+
+var (
+	// histograms are the distributions of readings we export, keyed by name
+	histograms = make(map[string]*prometheus.HistogramVec)
+	// histogramTimestamps holds the latest observation timestamp added to
+	// each station's histograms
+	histogramTimestamps = make(map[string]float64)
+	// lastStrikes holds the latest lightning strike epoch seen for each station
+	lastStrikes = make(map[string]float64)
+	// histogramBuckets are the classic buckets for each histogram
+	histogramBuckets = map[string][]float64{
+		"wind_avg_distribution":                  {0.5, 1.5, 3.3, 5.5, 8, 10.8, 13.9, 17.2, 20.8, 24.5, 28.5, 32.7},
+		"wind_gust_distribution":                 {0.5, 1.5, 3.3, 5.5, 8, 10.8, 13.9, 17.2, 20.8, 24.5, 28.5, 32.7},
+		"lightning_strike_distance_distribution": {1, 3, 5, 8, 12, 16, 20, 25, 30, 40},
+	}
+	// histogramHelp is the help text for each histogram
+	histogramHelp = map[string]string{
+		"wind_avg_distribution":                  "Distribution of average wind speed readings",
+		"wind_gust_distribution":                 "Distribution of wind gust readings",
+		"lightning_strike_distance_distribution": "Distribution of lightning strike distances",
+	}
+)
+
+// registerHistograms creates and registers our histograms, with classic
+// buckets, native buckets or both depending on our config
+func registerHistograms(labelNames []string) {
+	for name, buckets := range histogramBuckets {
+		opts := prometheus.HistogramOpts{
+			Namespace: ns,
+			Subsystem: ss,
+			Name:      name,
+			Help:      histogramHelp[name],
+		}
+		if cfg.Histograms == "classic" || cfg.Histograms == "both" {
+			opts.Buckets = buckets
+		}
+		if cfg.Histograms == "native" || cfg.Histograms == "both" {
+			opts.NativeHistogramBucketFactor = nativeBucketFactor
+			opts.NativeHistogramMaxBucketNumber = 100
+		}
+		histograms[name] = prometheus.NewHistogramVec(opts, labelNames)
+		weatherRegistry.MustRegister(histograms[name])
+	}
+}
+
+// observeHistograms adds a station's observation to our histograms if we
+// haven't already seen it, skipping implausible readings
+func observeHistograms(stationID string, o observation, labels prometheus.Labels) {
+	if o.Timestamp == histogramTimestamps[stationID] {
+		return
+	}
+	histogramTimestamps[stationID] = o.Timestamp
+	if o.has("wind_avg") {
+		histograms["wind_avg_distribution"].With(labels).Observe(o.WindAvg)
+	}
+	if o.has("wind_gust") {
+		histograms["wind_gust_distribution"].With(labels).Observe(o.WindGust)
+	}
+
+	last, seen := lastStrikes[stationID]
+	lastStrikes[stationID] = o.LightningStrikeLastEpoch
+	// the first observation only tells us when the last strike was
+	if seen && o.has("lightning_strike_last_epoch") && o.LightningStrikeLastEpoch > last {
+		histograms["lightning_strike_distance_distribution"].With(labels).Observe(o.LightningStrikeLastDistance)
+	}
+}
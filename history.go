@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// history holds the recent observations of every station, oldest first,
+	// for CSV export
+	history []sinkRecord
+	// historyAppended counts the observations appended to our history file
+	// since it was last rewritten
+	historyAppended int
+)
+
+// trimHistory drops observations older than our retention from our history
+func trimHistory(now time.Time) {
+	cutoff := now.Add(-time.Duration(cfg.History.Retention))
+	i := 0
+	for i < len(history) && history[i].Time.Before(cutoff) {
+		i++
+	}
+	history = history[i:]
+}
+
+// loadHistory reads our history from our history file, if configured, and
+// rewrites the file without the observations that have aged out since
+func loadHistory() error {
+	if cfg.History.Retention <= 0 || cfg.History.File == "" {
+		return nil
+	}
+	f, err := os.Open(cfg.History.File)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error reading history file: %v", err)
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	sc.Buffer(nil, 1<<20)
+	for sc.Scan() {
+		var rec sinkRecord
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			return fmt.Errorf("error parsing history file: %v", err)
+		}
+		history = append(history, rec)
+	}
+	if err := sc.Err(); err != nil {
+		return fmt.Errorf("error reading history file: %v", err)
+	}
+	sort.SliceStable(history, func(i, j int) bool { return history[i].Time.Before(history[j].Time) })
+	trimHistory(time.Now())
+	return rewriteHistory()
+}
+
+// rewriteHistory atomically replaces our history file with our history
+func rewriteHistory() error {
+	var b strings.Builder
+	enc := json.NewEncoder(&b)
+	for _, rec := range history {
+		if err := enc.Encode(rec); err != nil {
+			return err
+		}
+	}
+	historyAppended = 0
+	return writeFile(cfg.History.File, []byte(b.String()))
+}
+
+// addHistory adds an observation to our history, appending it to our history
+// file if configured. The file is rewritten once it holds as many aged out
+// observations as current ones, so it doesn't grow without bound.
+func addHistory(rec sinkRecord) error {
+	if cfg.History.Retention <= 0 {
+		return nil
+	}
+	history = append(history, rec)
+	trimHistory(time.Now())
+	if cfg.History.File == "" {
+		return nil
+	}
+	if historyAppended >= len(history) {
+		return rewriteHistory()
+	}
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(cfg.History.File, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("error writing history file: %v", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		return fmt.Errorf("error writing history file: %v", err)
+	}
+	historyAppended++
+	return nil
+}
+
+// parseRange parses how far back to export, as a duration like 90m or 24h,
+// or a number of days like 7d
+func parseRange(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.ParseFloat(days, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid range %q", s)
+		}
+		return time.Duration(n * float64(24*time.Hour)), nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid range %q", s)
+	}
+	return d, nil
+}
+
+// exportHandler serves the observations in our history from the range given
+// by the range query parameter as CSV, a row per observation and a column per
+// reading, only from the station given by the station query parameter if
+// it's set
+func exportHandler(w http.ResponseWriter, req *http.Request) {
+	if cfg.History.Retention <= 0 {
+		http.Error(w, "history is disabled", http.StatusNotFound)
+		return
+	}
+	window := time.Duration(cfg.History.Retention)
+	if s := req.URL.Query().Get("range"); s != "" {
+		d, err := parseRange(s)
+		if err != nil || d <= 0 {
+			http.Error(w, fmt.Sprintf("invalid range %q, expected a duration like 24h or 7d", s), http.StatusBadRequest)
+			return
+		}
+		window = d
+	}
+	station := req.URL.Query().Get("station")
+	cutoff := time.Now().Add(-window)
+	metricsMu.RLock()
+	var recs []sinkRecord
+	for _, rec := range history {
+		if !rec.Time.Before(cutoff) && (station == "" || rec.StationID == station) {
+			recs = append(recs, rec)
+		}
+	}
+	metricsMu.RUnlock()
+	seen := make(map[string]bool)
+	var fields []string
+	for _, rec := range recs {
+		for name := range rec.Values {
+			if !seen[name] {
+				seen[name] = true
+				fields = append(fields, name)
+			}
+		}
+	}
+	sort.Strings(fields)
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="tempest.csv"`)
+	cw := csv.NewWriter(w)
+	cw.Write(append([]string{"time", "station_id"}, fields...))
+	for _, rec := range recs {
+		row := []string{rec.Time.UTC().Format(time.RFC3339), rec.StationID}
+		for _, name := range fields {
+			v, ok := rec.Values[name]
+			if !ok {
+				// leave readings the observation didn't carry empty
+				row = append(row, "")
+				continue
+			}
+			row = append(row, strconv.FormatFloat(v, 'f', -1, 64))
+		}
+		cw.Write(row)
+	}
+	cw.Flush()
+}
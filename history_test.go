@@ -0,0 +1,32 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseRange(t *testing.T) {
+	tests := []struct {
+		s    string
+		want time.Duration
+		err  bool
+	}{
+		{s: "90m", want: 90 * time.Minute},
+		{s: "24h", want: 24 * time.Hour},
+		{s: "7d", want: 7 * 24 * time.Hour},
+		{s: "0.5d", want: 12 * time.Hour},
+		{s: "d", err: true},
+		{s: "week", err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			got, err := parseRange(tt.s)
+			if (err != nil) != tt.err {
+				t.Fatalf("parseRange() error = %v, want error %v", err, tt.err)
+			}
+			if got != tt.want {
+				t.Errorf("parseRange() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
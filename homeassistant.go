@@ -0,0 +1,183 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// haUnit is the Home Assistant unit of measurement and device class for one of
+// our base units
+type haUnit struct {
+	unit        string
+	deviceClass string
+}
+
+// haUnits are the Home Assistant units and device classes of our base units,
+// keyed by unit suffix
+var haUnits = map[string]haUnit{
+	"celsius":                   {"°C", "temperature"},
+	"hpa":                       {"hPa", "atmospheric_pressure"},
+	"percent":                   {"%", "humidity"},
+	"lux":                       {"lx", "illuminance"},
+	"watts_per_square_meter":    {"W/m²", "irradiance"},
+	"meters_per_second":         {"m/s", "wind_speed"},
+	"degrees":                   {"°", ""},
+	"millimeters":               {"mm", "precipitation"},
+	"millimeters_per_hour":      {"mm/h", "precipitation_intensity"},
+	"kilometers":                {"km", "distance"},
+	"minutes":                   {"min", "duration"},
+	"kilograms_per_cubic_meter": {"kg/m³", ""},
+}
+
+// haDevice is the device a Home Assistant entity belongs to
+type haDevice struct {
+	Identifiers  []string `json:"identifiers"`
+	Name         string   `json:"name"`
+	Manufacturer string   `json:"manufacturer"`
+	Model        string   `json:"model"`
+}
+
+// haAvailability is a topic Home Assistant watches for an entity's
+// availability
+type haAvailability struct {
+	Topic string `json:"topic"`
+}
+
+// haSensor is the discovery config of a Home Assistant sensor
+type haSensor struct {
+	Name              string           `json:"name"`
+	UniqueID          string           `json:"unique_id"`
+	StateTopic        string           `json:"state_topic"`
+	ValueTemplate     string           `json:"value_template"`
+	UnitOfMeasurement string           `json:"unit_of_measurement,omitempty"`
+	DeviceClass       string           `json:"device_class,omitempty"`
+	StateClass        string           `json:"state_class"`
+	Availability      []haAvailability `json:"availability"`
+	AvailabilityMode  string           `json:"availability_mode"`
+	Device            haDevice         `json:"device"`
+}
+
+// homeAssistantSink publishes each station's observations to MQTT, with
+// discovery configs that make each reading a Home Assistant sensor. A station's
+// sensors are available while the exporter is connected and the station is
+// online.
+type homeAssistantSink struct {
+	c      mqttConfig
+	client *mqttClient
+	mu     sync.Mutex
+	// connects is the client's connection count when we announced our sensors
+	connects int
+	// announced holds the sensors we've published discovery configs for,
+	// keyed by station and reading
+	announced map[string]bool
+}
+
+// newHomeAssistantSink creates a Home Assistant sink
+func newHomeAssistantSink(c mqttConfig, client *mqttClient) *homeAssistantSink {
+	return &homeAssistantSink{c: c, client: client, announced: make(map[string]bool)}
+}
+
+// name implements sink
+func (s *homeAssistantSink) name() string {
+	return "home_assistant"
+}
+
+// topic returns a station's topic with the given suffix
+func (s *homeAssistantSink) topic(station, suffix string) string {
+	return s.c.Topic + "/" + station + "/" + suffix
+}
+
+// sensor returns the discovery config of the sensor for a station's reading
+func (s *homeAssistantSink) sensor(station, stationName, reading string) haSensor {
+	id := "tempest_" + station
+	u := haUnits[baseUnits["station_"+reading]]
+	if reading != "relative_humidity" && u.deviceClass == "humidity" {
+		u.deviceClass = ""
+	}
+	return haSensor{
+		Name:              strings.ReplaceAll(reading, "_", " "),
+		UniqueID:          id + "_" + reading,
+		StateTopic:        s.topic(station, "state"),
+		ValueTemplate:     fmt.Sprintf("{{ value_json.%s }}", reading),
+		UnitOfMeasurement: u.unit,
+		DeviceClass:       u.deviceClass,
+		StateClass:        "measurement",
+		Availability: []haAvailability{
+			{Topic: s.client.status},
+			{Topic: s.topic(station, "availability")},
+		},
+		AvailabilityMode: "all",
+		Device: haDevice{
+			Identifiers:  []string{id},
+			Name:         stationName,
+			Manufacturer: "WeatherFlow",
+			Model:        "Tempest",
+		},
+	}
+}
+
+// announce publishes discovery configs for any of a station's readings we
+// haven't announced since connecting
+func (s *homeAssistantSink) announce(rec sinkRecord) error {
+	connects, err := s.client.session()
+	if err != nil {
+		return err
+	}
+	if connects != s.connects {
+		s.connects = connects
+		s.announced = make(map[string]bool)
+	}
+	name := rec.Labels["station_name"]
+	if name == "" {
+		name = "Tempest " + rec.StationID
+	}
+	for reading := range rec.Values {
+		key := rec.StationID + "/" + reading
+		if s.announced[key] {
+			continue
+		}
+		b, err := json.Marshal(s.sensor(rec.StationID, name, reading))
+		if err != nil {
+			return err
+		}
+		topic := fmt.Sprintf("%s/sensor/tempest_%s/%s/config", s.c.DiscoveryPrefix, rec.StationID, reading)
+		if err := s.client.publish(topic, b, s.c.QoS, true); err != nil {
+			return err
+		}
+		s.announced[key] = true
+	}
+	return nil
+}
+
+// send implements sink
+func (s *homeAssistantSink) send(rec sinkRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch rec.Kind {
+	case "observation":
+		if err := s.announce(rec); err != nil {
+			return err
+		}
+		b, err := json.Marshal(rec.Values)
+		if err != nil {
+			return err
+		}
+		if err := s.client.publish(s.topic(rec.StationID, "state"), b, s.c.QoS, true); err != nil {
+			return err
+		}
+		return s.client.publish(s.topic(rec.StationID, "availability"), []byte("online"), s.c.QoS, true)
+	case "event":
+		if rec.Event.Event != "online" && rec.Event.Event != "offline" {
+			return errSkipped
+		}
+		return s.client.publish(s.topic(rec.StationID, "availability"), []byte(rec.Event.Event), s.c.QoS, true)
+	}
+	return errSkipped
+}
+
+// Close disconnects from the broker
+func (s *homeAssistantSink) Close() error {
+	return s.client.Close()
+}
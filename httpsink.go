@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"text/template"
+	"time"
+)
+
+// defaultHTTPSinkTemplate posts each record as JSON
+const defaultHTTPSinkTemplate = "{{json .}}"
+
+// httpSinkFuncs are the functions available to http sink templates
+var httpSinkFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+// httpSink POSTs each record to a URL, with a body and headers rendered from
+// templates
+type httpSink struct {
+	url     string
+	body    *template.Template
+	headers map[string]*template.Template
+	client  *http.Client
+}
+
+// newHTTPSink parses the templates for an http sink
+func newHTTPSink(c httpSinkConfig) (*httpSink, error) {
+	text := c.Template
+	if c.TemplateFile != "" {
+		b, err := ioutil.ReadFile(c.TemplateFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading http sink template: %v", err)
+		}
+		text = string(b)
+	}
+	if text == "" {
+		text = defaultHTTPSinkTemplate
+	}
+	body, err := template.New("body").Funcs(httpSinkFuncs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing http sink template: %v", err)
+	}
+	s := &httpSink{
+		url:     c.URL,
+		body:    body,
+		headers: make(map[string]*template.Template),
+		client:  &http.Client{Timeout: time.Duration(c.Timeout)},
+	}
+	for k, v := range c.Headers {
+		t, err := template.New(k).Funcs(httpSinkFuncs).Parse(v)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing http sink header %s: %v", k, err)
+		}
+		s.headers[k] = t
+	}
+	return s, nil
+}
+
+// name implements sink
+func (s *httpSink) name() string {
+	return "http_sink"
+}
+
+// send implements sink
+func (s *httpSink) send(rec sinkRecord) error {
+	var body bytes.Buffer
+	if err := s.body.Execute(&body, rec); err != nil {
+		return fmt.Errorf("error rendering http sink template: %v", err)
+	}
+	req, err := http.NewRequest(http.MethodPost, s.url, &body)
+	if err != nil {
+		return fmt.Errorf("error creating http sink request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, t := range s.headers {
+		var v bytes.Buffer
+		if err := t.Execute(&v, rec); err != nil {
+			return fmt.Errorf("error rendering http sink header %s: %v", k, err)
+		}
+		req.Header.Set(k, v.String())
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error sending to http sink: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("error sending to http sink: %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	// influxMeasurementEscaper escapes measurement names in line protocol
+	influxMeasurementEscaper = strings.NewReplacer(",", `\,`, " ", `\ `)
+	// influxKeyEscaper escapes tag keys, tag values and field keys in line
+	// protocol
+	influxKeyEscaper = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+)
+
+// influxSink writes each observation to InfluxDB as a point at the
+// observation's own time
+type influxSink struct {
+	c      influxConfig
+	url    string
+	client *http.Client
+}
+
+// newInfluxSink creates an InfluxDB sink, building its write URL for the
+// configured version of the API
+func newInfluxSink(c influxConfig) (*influxSink, error) {
+	u, err := url.Parse(c.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid influxdb url: %v", err)
+	}
+	q := url.Values{"precision": {"s"}}
+	if c.Version == "2" {
+		u = u.JoinPath("/api/v2/write")
+		q.Set("org", c.Org)
+		q.Set("bucket", c.Bucket)
+	} else {
+		u = u.JoinPath("/write")
+		q.Set("db", c.Database)
+		if c.RetentionPolicy != "" {
+			q.Set("rp", c.RetentionPolicy)
+		}
+	}
+	u.RawQuery = q.Encode()
+	return &influxSink{c: c, url: u.String(), client: &http.Client{Timeout: uploadTimeout}}, nil
+}
+
+// name implements sink
+func (s *influxSink) name() string {
+	return "influxdb"
+}
+
+// influxLine formats an observation as a point in line protocol, tagged with
+// its station's labels
+func influxLine(measurement string, rec sinkRecord) string {
+	var b strings.Builder
+	b.WriteString(influxMeasurementEscaper.Replace(measurement))
+	keys := make([]string, 0, len(rec.Labels))
+	for k := range rec.Labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		// influxdb rejects empty tag values
+		if rec.Labels[k] == "" {
+			continue
+		}
+		fmt.Fprintf(&b, ",%s=%s", influxKeyEscaper.Replace(k), influxKeyEscaper.Replace(rec.Labels[k]))
+	}
+	fields := make([]string, 0, len(rec.Values))
+	for k := range rec.Values {
+		fields = append(fields, k)
+	}
+	sort.Strings(fields)
+	for i, k := range fields {
+		sep := ","
+		if i == 0 {
+			sep = " "
+		}
+		fmt.Fprintf(&b, "%s%s=%s", sep, influxKeyEscaper.Replace(k), strconv.FormatFloat(rec.Values[k], 'f', -1, 64))
+	}
+	fmt.Fprintf(&b, " %d\n", rec.Time.Unix())
+	return b.String()
+}
+
+// send implements sink
+func (s *influxSink) send(rec sinkRecord) error {
+	if rec.Kind != "observation" || len(rec.Values) == 0 {
+		return errSkipped
+	}
+	req, err := http.NewRequest(http.MethodPost, s.url, bytes.NewBufferString(influxLine(s.c.Measurement, rec)))
+	if err != nil {
+		return fmt.Errorf("error creating influxdb request: %v", err)
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if s.c.Token != "" {
+		req.Header.Set("Authorization", "Token "+s.c.Token)
+	} else if s.c.Username != "" {
+		req.SetBasicAuth(s.c.Username, s.c.Password)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error writing to influxdb: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("error writing to influxdb: %s", resp.Status)
+	}
+	return nil
+}
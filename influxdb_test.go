@@ -0,0 +1,79 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestInfluxLine(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	tests := []struct {
+		name        string
+		measurement string
+		rec         sinkRecord
+		want        string
+	}{
+		{
+			name:        "sorted tags and fields",
+			measurement: "tempest",
+			rec: sinkRecord{
+				Labels: map[string]string{"station_id": "1", "station_name": "Back Yard", "agl": ""},
+				Values: map[string]float64{"uv": 3, "air_temperature": 21.5},
+				Time:   now,
+			},
+			want: "tempest,station_id=1,station_name=Back\\ Yard air_temperature=21.5,uv=3 1700000000\n",
+		},
+		{
+			name:        "escaping",
+			measurement: "weather station,home",
+			rec: sinkRecord{
+				Labels: map[string]string{"place": "a=b,c"},
+				Values: map[string]float64{"precip total": -0.25},
+				Time:   now,
+			},
+			want: "weather\\ station\\,home,place=a\\=b\\,c precip\\ total=-0.25 1700000000\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := influxLine(tt.measurement, tt.rec); got != tt.want {
+				t.Errorf("influxLine() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewInfluxSink(t *testing.T) {
+	tests := []struct {
+		name string
+		c    influxConfig
+		want string
+	}{
+		{
+			name: "v2",
+			c:    influxConfig{URL: "http://localhost:8086", Version: "2", Org: "home", Bucket: "weather"},
+			want: "http://localhost:8086/api/v2/write?bucket=weather&org=home&precision=s",
+		},
+		{
+			name: "v1",
+			c:    influxConfig{URL: "http://localhost:8086/", Version: "1", Database: "weather"},
+			want: "http://localhost:8086/write?db=weather&precision=s",
+		},
+		{
+			name: "v1 with retention policy",
+			c:    influxConfig{URL: "http://localhost:8086", Version: "1", Database: "weather", RetentionPolicy: "year"},
+			want: "http://localhost:8086/write?db=weather&precision=s&rp=year",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := newInfluxSink(tt.c)
+			if err != nil {
+				t.Fatalf("newInfluxSink() error = %v", err)
+			}
+			if s.url != tt.want {
+				t.Errorf("newInfluxSink() url = %q, want %q", s.url, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"reflect"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// geohashAlphabet is the base32 alphabet used by geohashes
+const geohashAlphabet = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+var (
+	// stationInfo carries descriptive labels for each station
+	stationInfo *prometheus.GaugeVec
+	// infoSeries holds the labels each station's info series was last set
+	// with, so a station whose details change doesn't keep its old series
+	infoSeries = make(map[string]prometheus.Labels)
+)
+
+// geohash encodes a latitude and longitude as a geohash of the given length
+func geohash(latitude, longitude float64, precision int) string {
+	lat := [2]float64{-90, 90}
+	lon := [2]float64{-180, 180}
+	hash := make([]byte, 0, precision)
+	var bit, ch int
+	even := true
+	for len(hash) < precision {
+		// even bits refine longitude, odd bits refine latitude
+		rng, v := &lat, latitude
+		if even {
+			rng, v = &lon, longitude
+		}
+		mid := (rng[0] + rng[1]) / 2
+		ch <<= 1
+		if v >= mid {
+			ch |= 1
+			rng[0] = mid
+		} else {
+			rng[1] = mid
+		}
+		even = !even
+		if bit++; bit == 5 {
+			hash = append(hash, geohashAlphabet[ch])
+			bit, ch = 0, 0
+		}
+	}
+	return string(hash)
+}
+
+// registerInfo creates and registers our station info metric
+func registerInfo() {
+	var r response
+	var names []string
+	for k := range r.infoLabels() {
+		names = append(names, k)
+	}
+	if cfg.GeohashPrecision > 0 {
+		names = append(names, "geohash")
+	}
+	stationInfo = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: ns,
+			Subsystem: ss,
+			Name:      "info",
+			Help:      "Descriptive information about the station, always 1",
+		},
+		names,
+	)
+	weatherRegistry.MustRegister(stationInfo)
+}
+
+// setInfo updates the info metric for a station, replacing its series if the
+// station's details have changed
+func setInfo(r response) {
+	l := r.infoLabels()
+	if cfg.GeohashPrecision > 0 {
+		l["geohash"] = geohash(r.Latitude, r.Longitude, cfg.GeohashPrecision)
+	}
+	id := strconv.Itoa(r.StationID)
+	metricsMu.Lock()
+	defer metricsMu.Unlock()
+	if old, ok := infoSeries[id]; ok && !reflect.DeepEqual(old, l) {
+		stationInfo.Delete(old)
+	}
+	infoSeries[id] = l
+	stationInfo.With(l).Set(1)
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestGeohash(t *testing.T) {
+	tests := []struct {
+		lat, lon  float64
+		precision int
+		want      string
+	}{
+		{lat: 57.64911, lon: 10.40744, precision: 11, want: "u4pruydqqvj"},
+		{lat: 42.6, lon: -5.6, precision: 5, want: "ezs42"},
+		{lat: -25.382708, lon: -49.265506, precision: 6, want: "6gkzwg"},
+	}
+	for _, tt := range tests {
+		if got := geohash(tt.lat, tt.lon, tt.precision); got != tt.want {
+			t.Errorf("geohash(%v, %v, %d) = %q, want %q", tt.lat, tt.lon, tt.precision, got, tt.want)
+		}
+	}
+}
+
+func TestSetInfoReplacesSeries(t *testing.T) {
+	defer func(v *prometheus.GaugeVec) { stationInfo = v }(stationInfo)
+	defer func(m map[string]prometheus.Labels) { infoSeries = m }(infoSeries)
+	defer func(p int) { cfg.GeohashPrecision = p }(cfg.GeohashPrecision)
+	cfg.GeohashPrecision = 0
+	infoSeries = make(map[string]prometheus.Labels)
+	var names []string
+	for k := range (&response{}).infoLabels() {
+		names = append(names, k)
+	}
+	stationInfo = prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "tempest_station_info"}, names)
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(stationInfo)
+
+	var r response
+	r.StationID = 1
+	r.StationName = "Back Yard"
+	setInfo(r)
+	setInfo(r)
+	r.StationName = "Garden"
+	setInfo(r)
+	var other response
+	other.StationID = 2
+	setInfo(other)
+
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	names = nil
+	for _, m := range mfs[0].Metric {
+		if id, _ := labelValue(m, "station_id"); id == "1" {
+			name, _ := labelValue(m, "station_name")
+			names = append(names, name)
+		}
+	}
+	if len(mfs[0].Metric) != 2 || len(names) != 1 || names[0] != "Garden" {
+		t.Errorf("station_info has %d series, station 1 named %v, want 2 series and station 1 named [Garden]", len(mfs[0].Metric), names)
+	}
+}
+
+func TestStationLabelsID(t *testing.T) {
+	defer func(v *prometheus.GaugeVec) { stationInfo = v }(stationInfo)
+	defer func(m map[string]prometheus.Labels) { infoSeries = m }(infoSeries)
+	defer func(c config) { cfg = c }(cfg)
+	cfg = defaultConfig()
+	cfg.StationLabels = "id"
+	infoSeries = make(map[string]prometheus.Labels)
+	var names []string
+	for k := range (&response{}).infoLabels() {
+		names = append(names, k)
+	}
+	stationInfo = prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "tempest_station_info"}, names)
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(stationInfo)
+
+	var r response
+	r.StationID = 1
+	r.StationName = "Back Yard"
+	r.Timezone = "America/Chicago"
+	if l := r.parseLabels(); len(l) != 1 || l["station_id"] != "1" {
+		t.Errorf("parseLabels() = %v, want only station_id", l)
+	}
+	setInfo(r)
+	// metrics are joined with the info series on station_id, so a moved
+	// station must still have only one
+	r.Latitude, r.Longitude, r.Timezone = 51.5, -0.1, "Europe/London"
+	setInfo(r)
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(mfs[0].Metric); n != 1 {
+		t.Fatalf("station_info has %d series, want 1", n)
+	}
+	if tz, _ := labelValue(mfs[0].Metric[0], "timezone"); tz != "Europe/London" {
+		t.Errorf("station_info timezone = %q, want Europe/London", tz)
+	}
+}
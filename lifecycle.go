@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+)
+
+// lifecyclePaths are the paths of our admin endpoints, which take the config
+// lock themselves if they need it
+var lifecyclePaths = map[string]bool{"/-/reload": true, "/-/quit": true}
+
+// lifecycleHandler wraps an admin endpoint, only allowing POST and PUT
+// requests to it, as Prometheus does
+func lifecycleHandler(f func(w http.ResponseWriter, req *http.Request)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost && req.Method != http.MethodPut {
+			w.Header().Set("Allow", "POST, PUT")
+			http.Error(w, "only POST or PUT requests allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		f(w, req)
+	})
+}
+
+// reloadHandler reloads our config
+func reloadHandler(w http.ResponseWriter, req *http.Request) {
+	if err := reload(); err != nil {
+		slog.Error(err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprintln(w, "config reloaded")
+}
+
+// quitHandler shuts us down cleanly, once in flight requests have finished
+func quitHandler(w http.ResponseWriter, req *http.Request) {
+	fmt.Fprintln(w, "shutting down")
+	go stopServer()
+}
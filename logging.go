@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+
+	kitlog "github.com/go-kit/log"
+)
+
+// logOutput is where our logs and access logs are written
+var logOutput io.Writer = os.Stdout
+
+// logLevels maps our log level names to slog levels
+var logLevels = map[string]slog.Level{
+	"debug": slog.LevelDebug,
+	"info":  slog.LevelInfo,
+	"warn":  slog.LevelWarn,
+	"error": slog.LevelError,
+}
+
+// newHandler returns a log handler writing to w at our configured level and
+// format
+func newHandler(w io.Writer) slog.Handler {
+	opts := &slog.HandlerOptions{Level: logLevels[cfg.Log.Level]}
+	if cfg.Log.Format == "json" {
+		return slog.NewJSONHandler(w, opts)
+	}
+	return slog.NewTextHandler(w, opts)
+}
+
+// setupLogging sends our logs, and anything logged with the log package by
+// our dependencies, through our configured logger to stdout, our log file or
+// syslog
+func setupLogging() error {
+	switch {
+	case cfg.Log.Syslog != "":
+		w, err := newSyslogWriter(cfg.Log.Syslog)
+		if err != nil {
+			return err
+		}
+		logOutput = w
+	case cfg.Log.File != "":
+		f, err := newRotatingFile(cfg.Log)
+		if err != nil {
+			return err
+		}
+		logOutput = f
+	}
+	setLogger()
+	return nil
+}
+
+// setLogger logs to our log output at our configured level and format
+func setLogger() {
+	if w, ok := logOutput.(*syslogWriter); ok {
+		slog.SetDefault(slog.New(newSyslogHandler(w)))
+		return
+	}
+	slog.SetDefault(slog.New(newHandler(logOutput)))
+}
+
+// fatal logs err and exits
+func fatal(err error) {
+	slog.Error(err.Error())
+	os.Exit(1)
+}
+
+// kitLogger adapts our logger for the exporter toolkit, which logs with go-kit
+func kitLogger() kitlog.Logger {
+	return kitlog.LoggerFunc(func(keyvals ...interface{}) error {
+		level, msg := slog.LevelInfo, ""
+		var attrs []interface{}
+		for i := 0; i+1 < len(keyvals); i += 2 {
+			switch k := fmt.Sprint(keyvals[i]); k {
+			case "level":
+				if l, ok := logLevels[fmt.Sprint(keyvals[i+1])]; ok {
+					level = l
+				}
+			case "msg":
+				msg = fmt.Sprint(keyvals[i+1])
+			default:
+				attrs = append(attrs, k, keyvals[i+1])
+			}
+		}
+		slog.Log(context.Background(), level, msg, attrs...)
+		return nil
+	})
+}
@@ -1,21 +1,20 @@
 package main
 
 import (
-	"encoding/json"
-	"fmt"
-	"log"
+	"context"
+	"flag"
+	"log/slog"
 	"net/http"
 	"os"
 	"strconv"
 	"time"
 
-	"github.com/gorilla/handlers"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
-)
 
-// apiURL is the base API URL for the weatherflow observations API
-const apiURL = "https://swd.weatherflow.com/swd/rest/observations/station"
+	"github.com/nalbury/tempest-exporter/pkg/weatherflow"
+)
 
 // ns is the metric namespace prefix
 const ns = "tempest"
@@ -24,101 +23,110 @@ const ns = "tempest"
 const ss = "station"
 
 var (
-	// token is our weatherflow API token
-	token = os.Getenv("WEATHERFLOW_API_TOKEN")
-	// station is the station ID we want to query
-	station = os.Getenv("WEATHERFLOW_STATION_ID")
-	// labels is a map of prometheus labels to apply to the metrics retrieved
-	labels     prometheus.Labels
+	// labelNames are the prometheus labels applied to the metrics retrieved
 	labelNames []string
 	// metrics is an empty MetricsMap
 	metrics = make(MetricsMap)
+	// weatherRegistry holds the weather metrics served on our telemetry path
+	weatherRegistry = prometheus.NewRegistry()
+	// internalRegistry holds the exporter's own operational metrics, served
+	// separately so they aren't shipped along with weather data
+	internalRegistry = prometheus.NewRegistry()
 )
 
-type logWriter struct{}
+// observation is the typed observation data from a station
+type observation struct {
+	weatherflow.Observation
 
-func (l *logWriter) Write(bytes []byte) (int, error) {
-	return fmt.Print(time.Now().Format("02/Jan/2006:15:04:05 -0700") + " [INFO] [exporter]" + string(bytes))
+	// fields holds which readings are present, when only some are known
+	fields map[string]bool
+	// dropped holds the readings left out for being outside their
+	// plausibility bounds
+	dropped []string
 }
 
-// stationStatus holds our station status code
-type stationStatus struct {
-	Code int `json:"status_code"`
+// values returns the numeric readings of an observation keyed by metric name.
+// Yesterday's final precipitation is only included once the API reports it,
+// and observations from local broadcasts only include the readings they carry.
+func (o observation) values() map[string]float64 {
+	v := fieldValues(o.Observation)
+	v["precip_yesterday_rain_check_applied"] = boolToFloat(o.PrecipAnalysisTypeYesterday != 0)
+	if trend, ok := pressureTrends[o.PressureTrend]; ok {
+		v["pressure_trend"] = trend
+	}
+	for name := range v {
+		if !o.has(name) {
+			delete(v, name)
+		}
+	}
+	return v
 }
 
-// observation is the typed observation data from a station
-type observation struct {
-	AirDensity                       float64 `json:"air_density"`
-	AirTemperature                   float64 `json:"air_temperature"`
-	BarometricPressure               float64 `json:"barometric_pressure"`
-	Brightness                       float64 `json:"brightness"`
-	DeltaT                           float64 `json:"delta_t"`
-	DewPoint                         float64 `json:"dew_point"`
-	FeelsLike                        float64 `json:"feels_like"`
-	HeatIndex                        float64 `json:"heat_index"`
-	LightningStrikeCount             float64 `json:"lightning_strike_count"`
-	LightningStrikeCountLast1hr      float64 `json:"lightning_strike_count_last_1hr"`
-	LightningStrikeCountLast3hr      float64 `json:"lightning_strike_count_last_3hr"`
-	LightningStrikeLastDistance      float64 `json:"lightning_strike_last_distance"`
-	LightningStrikeLastEpoch         float64 `json:"lightning_strike_last_epoch"`
-	Precip                           float64 `json:"precip"`
-	PrecipAccumLast1hr               float64 `json:"precip_accum_last_1hr"`
-	PrecipAccumLocalDay              float64 `json:"precip_accum_local_day"`
-	PrecipAccumLocalYesterday        float64 `json:"precip_accum_local_yesterday"`
-	PrecipAccumLocalYesterdayFinal   float64 `json:"precip_accum_local_yesterday_final"`
-	PrecipAnalysisTypeYesterday      float64 `json:"precip_analysis_type_yesterday"`
-	PrecipMinutesLocalDay            float64 `json:"precip_minutes_local_day"`
-	PrecipMinutesLocalYesterday      float64 `json:"precip_minutes_local_yesterday"`
-	PrecipMinutesLocalYesterdayFinal float64 `json:"precip_minutes_local_yesterday_final"`
-	PressureTrend                    string  `json:"pressure_trend"`
-	RelativeHumidity                 float64 `json:"relative_humidity"`
-	SeaLevelPressure                 float64 `json:"sea_level_pressure"`
-	SolarRadiation                   float64 `json:"solar_radiation"`
-	StationPressure                  float64 `json:"station_pressure"`
-	Timestamp                        float64 `json:"timestamp"`
-	Uv                               float64 `json:"uv"`
-	WetBulbTemperature               float64 `json:"wet_bulb_temperature"`
-	WindAvg                          float64 `json:"wind_avg"`
-	WindChill                        float64 `json:"wind_chill"`
-	WindDirection                    float64 `json:"wind_direction"`
-	WindGust                         float64 `json:"wind_gust"`
-	WindLull                         float64 `json:"wind_lull"`
-}
-
-// response is our response from the weatherflow obvservations API
+// has returns whether an observation includes a reading
+func (o observation) has(name string) bool {
+	return o.fields == nil || o.fields[name]
+}
+
+// response is a station's details and latest observations
 type response struct {
-	StationId   int           `json:"station_id"`
-	StationName string        `json:"station_name"`
-	PublicName  string        `json:"public_name"`
-	Latitude    float64       `json:"latitude"`
-	Longitude   float64       `json:"longitude"`
-	Timezone    string        `json:"timezone"`
-	Elevation   float64       `json:"elevation"`
-	Status      stationStatus `json:"status"`
-	Obs         []observation `json:"obs"`
+	weatherflow.StationObservations
+	Obs []observation
 }
 
-// getTempestData retrieves the API response from our Tempest weather station
-func getTempestData(t, s string) (response, error) {
-	var r response
-	reqURL := apiURL + "/" + s + "?token=" + t
-	httpResp, err := http.Get(reqURL)
-	// TODO handle client errors
-	if err != nil {
-		return r, fmt.Errorf("error getting data from tempest station: %v", err)
+// newResponse wraps a station observations response from the API
+func newResponse(so weatherflow.StationObservations) response {
+	r := response{StationObservations: so}
+	for _, o := range so.Obs {
+		r.Obs = append(r.Obs, observation{Observation: o})
 	}
-	defer httpResp.Body.Close()
-	err = json.NewDecoder(httpResp.Body).Decode(&r)
+	return r
+}
+
+// getTempestData retrieves the API response from our Tempest weather station
+func getTempestData(s string) (response, error) {
+	return fetchObservations(apiClient, s)
+}
+
+// fetchObservations retrieves the latest observations for a station with
+// client, without reading our config
+func fetchObservations(client *weatherflow.Client, s string) (response, error) {
+	var so weatherflow.StationObservations
+	body, err := client.Get(context.Background(), "/observations/station/"+s, nil, &so)
 	if err != nil {
-		return r, fmt.Errorf("error parsing json into response struct: %v", err)
+		return response{}, err
 	}
-	return r, nil
+	cacheResponse(s, body)
+	return newResponse(so), nil
 }
 
-// parseLabels returns a list of label values as strings matchingour "labels" var
+// parseLabels returns the labels for a station's metrics, which are only its
+// station_id unless we're configured to put every descriptive label on them
 func (r *response) parseLabels() prometheus.Labels {
+	if cfg.StationLabels == "id" {
+		return prometheus.Labels{"station_id": strconv.Itoa(r.StationID)}
+	}
+	return r.infoLabels()
+}
+
+// infoLabels returns the descriptive labels for a station that our label
+// allowlist and denylist let through
+func (r *response) infoLabels() prometheus.Labels {
+	l := r.allLabels()
+	for k := range l {
+		if k == "station_id" {
+			continue
+		}
+		if (len(cfg.Labels.Allow) > 0 && !contains(cfg.Labels.Allow, k)) || contains(cfg.Labels.Deny, k) {
+			delete(l, k)
+		}
+	}
+	return l
+}
+
+// allLabels returns every descriptive label for a station
+func (r *response) allLabels() prometheus.Labels {
 	l := make(map[string]string)
-	l["station_id"] = strconv.Itoa(r.StationId)
+	l["station_id"] = strconv.Itoa(r.StationID)
 	l["station_name"] = r.StationName
 	l["public_name"] = r.PublicName
 	l["latitude"] = strconv.FormatFloat(r.Latitude, 'E', -1, 64)
@@ -128,52 +136,290 @@ func (r *response) parseLabels() prometheus.Labels {
 	return l
 }
 
+// updateStation updates a station's metrics from a poll of its latest
+// observations, and of its forecast, devices and air quality if they were due
+func updateStation(p stationPoll) {
+	station, r := p.station, p.r
+	// stations only observe about once a minute, so skip polls that got the
+	// same observation again
+	prev, ok := latest[station]
+	observed := len(r.Obs) > 0 && (!ok || prev.Timestamp != r.Obs[0].Timestamp)
+	if observed {
+		// bound a new observation's readings before anything sees them
+		r.Obs[0].applyBounds()
+	}
+	labels := r.parseLabels()
+	setInfo(r)
+	// keep the station's status current for our online checks, even when
+	// it has no new observation
+	metricsMu.Lock()
+	stationResponses[station] = r
+	metricsMu.Unlock()
+	setAstro(r, time.Now(), labels)
+	if p.forecast != nil {
+		setForecast(r, *p.forecast, labels)
+	}
+	for _, d := range p.devices {
+		setDevice(station, d.d, d.r)
+	}
+	if p.airQuality != nil {
+		setAirQuality(*p.airQuality, labels)
+	}
+	for component, err := range p.fetched {
+		if err != nil {
+			slog.Error(err.Error(), "station_id", station)
+			reportFailure(component, err)
+		} else {
+			reportSuccess(component)
+		}
+	}
+	if observed {
+		setObservation(station, r, r.Obs[0], labels)
+	}
+}
+
+// setObservation updates everything derived from a station's latest
+// observation
+func setObservation(station string, r response, o observation, labels prometheus.Labels) {
+	metricsMu.Lock()
+	defer metricsMu.Unlock()
+	latest[station] = o
+	stationResponses[station] = r
+	latestRecords[station] = observationRecord(station, o, labels)
+	broadcast(latestRecords[station])
+	if err := addHistory(latestRecords[station]); err != nil {
+		slog.Error(err.Error(), "station_id", station)
+		reportFailure("history", err)
+	} else {
+		reportSuccess("history")
+	}
+	recordObservation(station, o)
+	notifyReady()
+	metrics.SetAll(o, labels)
+	setDerived(o, labels)
+	setET0(station, r, o, labels)
+	setWBGT(r, o, labels)
+	setAnomalies(station, o, labels)
+	setAggregates(station, r.Timezone, o, labels)
+	if cfg.Histograms != "none" {
+		observeHistograms(station, o, labels)
+	}
+	publishObservation(station, o, labels)
+	if err := setRecords(r, o, labels); err != nil {
+		slog.Error(err.Error(), "station_id", station)
+		reportFailure("records", err)
+	} else {
+		reportSuccess("records")
+	}
+	if err := setDegreeDays(station, r.Timezone, o, labels); err != nil {
+		slog.Error(err.Error(), "station_id", station)
+		reportFailure("degree_days", err)
+	} else {
+		reportSuccess("degree_days")
+	}
+}
+
 // getDatas gets all the datas
 func getDatas() {
+	defer reportPanic("poller")
+	polling.Store(true)
+	defer polling.Store(false)
 	for {
-		log.Println("getting latest observation...")
-		r, err := getTempestData(token, station)
-		if err != nil {
-			log.Fatal(err)
-		}
-		labels = r.parseLabels()
-		if len(r.Obs) > 0 {
-			o := r.Obs[0]
-			metrics.SetAll(o, labels)
+		now := time.Now()
+		configMu.RLock()
+		due, c := dueStations(now), currentPollConfig()
+		configMu.RUnlock()
+		// fetch without holding our config, so a reload doesn't wait on the
+		// API or our air quality sensor
+		polls := fetchStations(due, c)
+		configMu.RLock()
+		updateStations(polls, now)
+		push := gatherPush()
+		interval := time.Duration(cfg.PollInterval)
+		configMu.RUnlock()
+		push()
+		time.Sleep(interval)
+	}
+}
+
+// contains returns whether s is in list
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
 		}
-		time.Sleep(time.Second * 15)
 	}
+	return false
 }
 
 func init() {
-	// Setup logger for non req logs
-	log.SetFlags(0)
-	log.SetOutput(new(logWriter))
+	// Setup logger for non req logs, until our config is loaded
+	slog.SetDefault(slog.New(newHandler(os.Stdout)))
+}
 
-	// Check config values
-	if token == "" {
-		log.Fatalln("please set WEATHERFLOW_API_TOKEN")
+// setup validates our config and registers metrics for the exporter
+func setup() {
+	// Load and check config values
+	var err error
+	cfg, err = loadConfig()
+	setErrorReporting()
+	if err != nil {
+		fatal(err)
 	}
-	if station == "" {
-		log.Fatalln("please set WEATHERFLOW_STATION_ID")
+	if err := setupLogging(); err != nil {
+		fatal(err)
 	}
-	// Initialize labels
-	r, err := getTempestData(token, station)
-	if err != nil {
-		log.Fatal(err)
+	applyMemoryLimit()
+	if err := setupAPIClient(); err != nil {
+		fatal(err)
+	}
+	if bounds, err = mergeBounds(cfg.Bounds); err != nil {
+		fatal(err)
+	}
+	configHash = hashConfig()
+	registerMetrics()
+	if err := loadRecords(); err != nil {
+		fatal(err)
+	}
+	if err := loadDegreeDays(); err != nil {
+		fatal(err)
 	}
-	labels = r.parseLabels()
+	if err := loadHistory(); err != nil {
+		fatal(err)
+	}
+	if err := setupSinks(); err != nil {
+		fatal(err)
+	}
+}
+
+// registerMetrics creates and registers the metrics our config calls for
+func registerMetrics() {
+	// Initialize labels, whose names don't depend on any station's response
+	var r response
 	labelNames = []string{}
-	for k := range labels {
+	for k := range r.parseLabels() {
 		labelNames = append(labelNames, k)
 	}
 	// Initialze metrics
 	metrics.Register(labelNames)
+	anomalyGauge = newAnomalyGauge(labelNames)
+	weatherRegistry.MustRegister(anomalyGauge, up, lastScrapeError, scrapeDuration, observationCollector{})
+	internalRegistry.MustRegister(
+		readingsRejected,
+		heartbeat,
+		apiRequestDuration,
+		apiRequests,
+		apiRateLimited,
+		lastReloadSuccessful,
+		lastReloadSuccess,
+		sinkSends,
+		sinkLastSuccess,
+	)
+	if cfg.Collectors.Go {
+		internalRegistry.MustRegister(collectors.NewGoCollector())
+	}
+	if cfg.Collectors.Process {
+		internalRegistry.MustRegister(collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+	}
+	registerAggregates(labelNames)
+	registerRecords(labelNames)
+	registerDegreeDays(labelNames)
+	registerInfo()
+	registerAstro(labelNames)
+	registerDifferentials()
+	registerDerived(labelNames)
+	registerET0(labelNames)
+	registerWBGT(labelNames)
+	if cfg.Source == "udp" {
+		registerHubMetrics()
+		registerRapidWind(labelNames)
+		registerEvents(labelNames)
+	}
+	if cfg.Forecast.Enabled {
+		registerForecast(labelNames)
+	}
+	if cfg.Histograms != "none" {
+		registerHistograms(labelNames)
+	}
+	registerDeviceMetrics()
+	weatherRegistry.MustRegister(batteryLow, batteryTrend, batteryState)
+	if cfg.AirQuality.Source != "" {
+		registerAirQuality(labelNames)
+	}
 }
 
 func main() {
-	go getDatas()
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "udp-test":
+			os.Exit(runUDPTest(os.Args[2:]))
+		case "config-schema":
+			os.Exit(runConfigSchema())
+		case "doctor":
+			os.Exit(runDoctor(os.Args[2:]))
+		case "service":
+			os.Exit(runServiceCommand(os.Args[2:]))
+		}
+	}
+
+	if isWindowsService() {
+		runWindowsService(run)
+		return
+	}
+	if err := run(); err != nil {
+		fatal(err)
+	}
+}
+
+// run exports our stations until our server is stopped
+func run() error {
+	registerFlags(flag.CommandLine)
+	flag.Parse()
+
+	setup()
+	weather := snapshotGatherer(weatherRegistry)
+	switch {
+	case cfg.Source == "udp":
+		go listenBroadcasts()
+	case cfg.Collection == "scrape":
+		weather = scrapeGatherer(weather)
+	default:
+		go getDatas()
+	}
+	if cfg.RemoteWrite.URL != "" {
+		go pushRemoteWrite(weather)
+	}
+	if cfg.OTLP.Endpoint != "" {
+		go exportOTLP(weather)
+	}
 
-	http.Handle("/metrics", handlers.LoggingHandler(os.Stdout, promhttp.Handler()))
-	http.ListenAndServe("0.0.0.0:6969", nil)
+	http.Handle(cfg.TelemetryPath, accessLog(promhttp.InstrumentMetricHandler(internalRegistry, unitsHandler(weather))))
+	http.Handle("/internal/metrics", accessLog(promhttp.InstrumentMetricHandler(internalRegistry, promhttp.HandlerFor(internalRegistry, promhttp.HandlerOpts{DisableCompression: cfg.DisableCompression}))))
+	http.Handle("/probe", accessLog(probeHandler(weather)))
+	http.Handle("/sd", accessLog(http.HandlerFunc(sdHandler)))
+	http.Handle("/metar", accessLog(http.HandlerFunc(metarHandler)))
+	http.HandleFunc("/healthz", healthzHandler)
+	http.HandleFunc("/readyz", readyzHandler)
+	if cfg.EnableLifecycle {
+		http.Handle("/-/reload", accessLog(lifecycleHandler(reloadHandler)))
+		http.Handle("/-/quit", accessLog(lifecycleHandler(quitHandler)))
+	}
+	http.Handle(apiPath, accessLog(apiHandler()))
+	http.Handle("/events", accessLog(http.HandlerFunc(eventsHandler)))
+	http.Handle("/ws", accessLog(wsHandler))
+	http.Handle("/export.csv", accessLog(compress(http.HandlerFunc(exportHandler))))
+	if cfg.Proxy.Enabled {
+		http.Handle(proxyPath, accessLog(compress(http.HandlerFunc(proxyHandler))))
+	}
+	lastReloadSuccessful.Set(1)
+	lastReloadSuccess.SetToCurrentTime()
+	go watchReloads()
+	go watchOnline()
+	setupWatchdog()
+	if onDemand() {
+		notifyReady()
+	}
+	err := serve()
+	closeSinks()
+	return err
 }
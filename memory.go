@@ -0,0 +1,38 @@
+package main
+
+import (
+	"log/slog"
+	"os"
+	"runtime/debug"
+	"time"
+)
+
+// lowMemoryLimitMB is the soft memory limit used in low memory mode when
+// neither GOMEMLIMIT nor a memory limit is configured
+const lowMemoryLimitMB = 32
+
+// lowMemoryDefaults adjusts our defaults for hosts with little memory, like a
+// Raspberry Pi Zero. Settings configured explicitly still take precedence.
+func lowMemoryDefaults(c *config) {
+	c.LowMemory = true
+	c.MemoryLimitMB = lowMemoryLimitMB
+	c.AnomalyWindow = 5
+	c.Battery.TrendWindow = duration(30 * time.Minute)
+	c.Proxy.Enabled = false
+	c.Forecast.Enabled = false
+	c.History.Retention = duration(6 * time.Hour)
+}
+
+// applyMemoryLimit sets the runtime's soft memory limit from our config,
+// unless GOMEMLIMIT is set, in which case the runtime has already applied it
+func applyMemoryLimit() {
+	if os.Getenv("GOMEMLIMIT") != "" {
+		slog.Info("using GOMEMLIMIT", "limit", os.Getenv("GOMEMLIMIT"))
+		return
+	}
+	if cfg.MemoryLimitMB <= 0 {
+		return
+	}
+	debug.SetMemoryLimit(int64(cfg.MemoryLimitMB) << 20)
+	slog.Info("using a soft memory limit", "mib", cfg.MemoryLimitMB)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/nalbury/tempest-exporter/pkg/weatherflow"
+)
+
+// heapAfterDay returns how much the heap grows once our metrics are
+// registered and a station has been polled for a day, observing once a minute
+func heapAfterDay(t *testing.T, c config) uint64 {
+	defer func(c config) { cfg = c }(cfg)
+	defer func(w, i *prometheus.Registry) { weatherRegistry, internalRegistry = w, i }(weatherRegistry, internalRegistry)
+	defer func(h []sinkRecord) { history = h }(history)
+	defer func(l map[string]observation, r map[string]response, s map[string]sinkRecord) {
+		latest, stationResponses, latestRecords = l, r, s
+	}(latest, stationResponses, latestRecords)
+	defer func(a map[string]*anomalyDetector, g map[string]*aggregator, r map[string]*stationRecords, d map[string]*stationDegreeDays) {
+		anomalies, aggregates, records, degreeDays = a, g, r, d
+	}(anomalies, aggregates, records, degreeDays)
+	cfg = c
+	history = nil
+	latest, stationResponses, latestRecords = make(map[string]observation), make(map[string]response), make(map[string]sinkRecord)
+	anomalies, aggregates = make(map[string]*anomalyDetector), make(map[string]*aggregator)
+	records, degreeDays = make(map[string]*stationRecords), make(map[string]*stationDegreeDays)
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	weatherRegistry, internalRegistry = prometheus.NewRegistry(), prometheus.NewRegistry()
+	registerMetrics()
+	start := time.Now().Add(-24 * time.Hour)
+	for i := 0; i < 24*60; i++ {
+		temp := 15 + float64(i%60)/10
+		so := weatherflow.StationObservations{
+			StationID:   9999,
+			StationName: "Memory",
+			Latitude:    45,
+			Longitude:   -120,
+			Timezone:    "UTC",
+			Obs: []weatherflow.Observation{{
+				Timestamp:        float64(start.Add(time.Duration(i) * time.Minute).Unix()),
+				AirTemperature:   temp,
+				RelativeHumidity: 60,
+				StationPressure:  1000,
+				SeaLevelPressure: 1013,
+				WindAvg:          3,
+				WindGust:         5,
+				SolarRadiation:   400,
+			}},
+		}
+		updateStation(stationPoll{station: "9999", r: newResponse(so)})
+	}
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+	if len(history) == 0 || len(anomalies) == 0 {
+		t.Fatalf("polls kept %d history records and %d anomaly detectors", len(history), len(anomalies))
+	}
+	t.Logf("heap grew %d KiB with %d history records", (after.HeapAlloc-before.HeapAlloc)>>10, len(history))
+	if after.HeapAlloc < before.HeapAlloc {
+		return 0
+	}
+	return after.HeapAlloc - before.HeapAlloc
+}
+
+func TestLowMemoryHeap(t *testing.T) {
+	if testing.Short() {
+		t.Skip("measuring the heap polls a day of observations")
+	}
+	c := defaultConfig()
+	def := heapAfterDay(t, c)
+	lowMemoryDefaults(&c)
+	low := heapAfterDay(t, c)
+	// the figure documented in the README's Small hosts section
+	if low > 2<<20 {
+		t.Errorf("heap grew %d KiB in low memory mode, want at most 2048 KiB", low>>10)
+	}
+	if low >= def {
+		t.Errorf("heap grew %d KiB in low memory mode, want less than the %d KiB by default", low>>10, def>>10)
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// altimeterSetting returns the altimeter setting in mb for a station pressure
+// in mb at an elevation in meters
+func altimeterSetting(pressure, elevation float64) float64 {
+	const n = 0.190284
+	p := pressure - 0.3
+	return p * math.Pow(1+math.Pow(1013.25, n)*0.0065/288*elevation/math.Pow(p, n), 1/n)
+}
+
+// metarTemp formats a temperature in whole degrees celsius, with negative
+// temperatures prefixed with M
+func metarTemp(c float64) string {
+	t := int(math.Round(c))
+	if t < 0 {
+		return fmt.Sprintf("M%02d", -t)
+	}
+	return fmt.Sprintf("%02d", t)
+}
+
+// metarTenths formats a temperature in tenths of a degree celsius for the T
+// remark, with a leading 1 if it's negative
+func metarTenths(c float64) string {
+	t := int(math.Round(c * 10))
+	if t < 0 {
+		return fmt.Sprintf("1%03d", -t)
+	}
+	return fmt.Sprintf("0%03d", t)
+}
+
+// metarWind formats the wind group, in knots, reporting a gust when it's at
+// least 10 knots above the average
+func metarWind(o observation) string {
+	if !o.has("wind_avg") || !o.has("wind_direction") {
+		return "/////KT"
+	}
+	speed := int(math.Round(o.WindAvg * 1.943844))
+	if speed == 0 {
+		return "00000KT"
+	}
+	dir := int(math.Round(o.WindDirection/10)) * 10 % 360
+	if dir == 0 {
+		dir = 360
+	}
+	wind := fmt.Sprintf("%03d%02d", dir, speed)
+	if gust := int(math.Round(o.WindGust * 1.943844)); o.has("wind_gust") && gust-speed >= 10 {
+		wind += fmt.Sprintf("G%02d", gust)
+	}
+	return wind + "KT"
+}
+
+// metar renders a station's observation as a METAR style report. Visibility
+// and sky condition aren't observed, so are reported as missing.
+func metar(id string, o observation, elevation float64) string {
+	parts := []string{"METAR", id, time.Unix(int64(o.Timestamp), 0).UTC().Format("021504Z"), "AUTO", metarWind(o), "////"}
+	var remarks []string
+	if o.has("air_temperature") && o.has("relative_humidity") {
+		dp := o.DewPoint
+		if !o.has("dew_point") {
+			dp = dewPoint(o.AirTemperature, o.RelativeHumidity)
+		}
+		parts = append(parts, metarTemp(o.AirTemperature)+"/"+metarTemp(dp))
+		remarks = append(remarks, "T"+metarTenths(o.AirTemperature)+metarTenths(dp))
+	} else {
+		parts = append(parts, "/////")
+	}
+	if o.has("station_pressure") && o.StationPressure > 0 {
+		parts = append(parts, fmt.Sprintf("A%04d", int(math.Round(altimeterSetting(o.StationPressure, elevation)*2.95300))))
+	} else {
+		parts = append(parts, "A////")
+	}
+	if o.has("precip_accum_last_1hr") && o.PrecipAccumLast1hr > 0 {
+		remarks = append(remarks, fmt.Sprintf("P%04d", int(math.Ceil(o.PrecipAccumLast1hr/0.254))))
+	}
+	parts = append(parts, "RMK", "AO1")
+	parts = append(parts, remarks...)
+	return strings.Join(parts, " ")
+}
+
+// metarID returns the identifier a station is reported under, ZZZZ unless
+// one is configured for it
+func metarID(station string) string {
+	if id, ok := cfg.MetarIDs[station]; ok {
+		return id
+	}
+	return "ZZZZ"
+}
+
+// metarHandler serves a METAR style report of the latest observation from
+// the station given by the station query parameter, or from every station
+func metarHandler(w http.ResponseWriter, req *http.Request) {
+	metricsMu.RLock()
+	defer metricsMu.RUnlock()
+	station := req.URL.Query().Get("station")
+	var ids []string
+	if station != "" {
+		if _, ok := latest[station]; !ok {
+			http.Error(w, fmt.Sprintf("no observation from station %q", station), http.StatusNotFound)
+			return
+		}
+		ids = []string{station}
+	} else {
+		for id := range latest {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	for _, id := range ids {
+		fmt.Fprintln(w, metar(metarID(id), latest[id], stationResponses[id].Elevation))
+	}
+}
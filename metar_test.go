@@ -0,0 +1,101 @@
+package main
+
+import (
+	"math"
+	"testing"
+
+	"github.com/nalbury/tempest-exporter/pkg/weatherflow"
+)
+
+func TestAltimeterSetting(t *testing.T) {
+	tests := []struct {
+		pressure, elevation, want float64
+	}{
+		{pressure: 1000.3, elevation: 0, want: 1000},
+		{pressure: 980, elevation: 300, want: 1015.3},
+	}
+	for _, tt := range tests {
+		if got := altimeterSetting(tt.pressure, tt.elevation); math.Abs(got-tt.want) > 0.05 {
+			t.Errorf("altimeterSetting(%v, %v) = %v, want %v", tt.pressure, tt.elevation, got, tt.want)
+		}
+	}
+}
+
+func TestMetarTemp(t *testing.T) {
+	tests := []struct {
+		c           float64
+		temp, tenth string
+	}{
+		{c: 21.3, temp: "21", tenth: "0213"},
+		{c: 5.4, temp: "05", tenth: "0054"},
+		{c: -0.4, temp: "00", tenth: "1004"},
+		{c: -3.6, temp: "M04", tenth: "1036"},
+	}
+	for _, tt := range tests {
+		if got := metarTemp(tt.c); got != tt.temp {
+			t.Errorf("metarTemp(%v) = %q, want %q", tt.c, got, tt.temp)
+		}
+		if got := metarTenths(tt.c); got != tt.tenth {
+			t.Errorf("metarTenths(%v) = %q, want %q", tt.c, got, tt.tenth)
+		}
+	}
+}
+
+func TestMetarWind(t *testing.T) {
+	tests := []struct {
+		name string
+		o    observation
+		want string
+	}{
+		{name: "gusting", o: observation{Observation: weatherflow.Observation{WindAvg: 5, WindDirection: 273, WindGust: 12}}, want: "27010G23KT"},
+		{name: "gust under 10 knots above", o: observation{Observation: weatherflow.Observation{WindAvg: 5, WindDirection: 273, WindGust: 8}}, want: "27010KT"},
+		{name: "north", o: observation{Observation: weatherflow.Observation{WindAvg: 5, WindDirection: 358}}, want: "36010KT"},
+		{name: "calm", o: observation{Observation: weatherflow.Observation{WindDirection: 180}}, want: "00000KT"},
+		{name: "missing", o: observation{fields: map[string]bool{"air_temperature": true}}, want: "/////KT"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := metarWind(tt.o); got != tt.want {
+				t.Errorf("metarWind() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMetar(t *testing.T) {
+	tests := []struct {
+		name string
+		o    observation
+		want string
+	}{
+		{
+			name: "full observation",
+			o: observation{Observation: weatherflow.Observation{
+				Timestamp:          1700000000,
+				WindAvg:            5,
+				WindDirection:      273,
+				AirTemperature:     21.3,
+				RelativeHumidity:   50,
+				DewPoint:           10.2,
+				StationPressure:    1000.3,
+				PrecipAccumLast1hr: 0.3,
+			}},
+			want: "METAR KXYZ 142213Z AUTO 27010KT //// 21/10 A2953 RMK AO1 T02130102 P0002",
+		},
+		{
+			name: "wind only",
+			o: observation{
+				Observation: weatherflow.Observation{Timestamp: 1700000000, WindAvg: 5, WindDirection: 273},
+				fields:      map[string]bool{"wind_avg": true, "wind_direction": true},
+			},
+			want: "METAR KXYZ 142213Z AUTO 27010KT //// ///// A//// RMK AO1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := metar("KXYZ", tt.o, 0); got != tt.want {
+				t.Errorf("metar() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,402 @@
+package main
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// mqttKeepAlive is the keep alive interval we ask brokers for. We ping twice
+// as often.
+const mqttKeepAlive = 60 * time.Second
+
+// mqttConnectReturnCodes are the reasons a broker refuses a connection
+var mqttConnectReturnCodes = map[byte]string{
+	1: "unacceptable protocol version",
+	2: "identifier rejected",
+	3: "server unavailable",
+	4: "bad user name or password",
+	5: "not authorized",
+}
+
+// errMQTTDisconnected is returned to publishers waiting on an acknowledgement
+// when the connection drops
+var errMQTTDisconnected = errors.New("disconnected from mqtt broker")
+
+// mqttClient is a minimal MQTT 3.1.1 client that only publishes. It connects
+// on first use and again after any error, and leaves a retained "offline" on
+// its status topic as its last will.
+type mqttClient struct {
+	c      mqttConfig
+	status string
+	mu     sync.Mutex
+	conn   net.Conn
+	// connects counts our connections, so users can tell when we reconnected
+	connects int
+	// nextID is the packet identifier of our next QoS 1 or 2 publish
+	nextID uint16
+	// acks holds a channel for each publish awaiting an acknowledgement, keyed
+	// by packet identifier, that receives each acknowledging packet's type
+	acks map[uint16]chan byte
+}
+
+// newMQTTClient creates an MQTT client
+func newMQTTClient(c mqttConfig) *mqttClient {
+	return &mqttClient{c: c, status: c.Topic + "/status", acks: make(map[uint16]chan byte)}
+}
+
+// mqttString encodes a string with its length prefix
+func mqttString(s string) []byte {
+	b := make([]byte, 2, 2+len(s))
+	binary.BigEndian.PutUint16(b, uint16(len(s)))
+	return append(b, s...)
+}
+
+// mqttPacket frames a packet body with its fixed header
+func mqttPacket(header byte, body []byte) []byte {
+	p := []byte{header}
+	n := len(body)
+	for {
+		b := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			b |= 0x80
+		}
+		p = append(p, b)
+		if n == 0 {
+			break
+		}
+	}
+	return append(p, body...)
+}
+
+// mqttConnect returns a CONNECT packet for a clean session, logging in with
+// our credentials and leaving a retained QoS 0 will of offline on our status
+// topic
+func mqttConnect(c mqttConfig, status string) []byte {
+	flags := byte(0x02 | 0x04 | 0x20)
+	payload := append(mqttString(c.ClientID), mqttString(status)...)
+	payload = append(payload, mqttString("offline")...)
+	if c.Username != "" {
+		flags |= 0x80
+		payload = append(payload, mqttString(c.Username)...)
+	}
+	if c.Password != "" {
+		flags |= 0x40
+		payload = append(payload, mqttString(c.Password)...)
+	}
+	body := append(mqttString("MQTT"), 4, flags)
+	body = binary.BigEndian.AppendUint16(body, uint16(mqttKeepAlive.Seconds()))
+	return mqttPacket(0x10, append(body, payload...))
+}
+
+// mqttPublish returns a PUBLISH packet, with a packet identifier for QoS 1
+// and 2
+func mqttPublish(topic string, payload []byte, qos int, retain bool, id uint16) []byte {
+	header := byte(0x30) | byte(qos)<<1
+	if retain {
+		header |= 0x01
+	}
+	body := mqttString(topic)
+	if qos > 0 {
+		body = binary.BigEndian.AppendUint16(body, id)
+	}
+	return mqttPacket(header, append(body, payload...))
+}
+
+// readMQTTPacket reads a packet, returning its fixed header and body
+func readMQTTPacket(r *bufio.Reader) (byte, []byte, error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	n, mult := 0, 1
+	for i := 0; ; i++ {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		n += int(b&0x7f) * mult
+		mult *= 128
+		if b&0x80 == 0 {
+			break
+		}
+		if i == 3 {
+			return 0, nil, fmt.Errorf("malformed mqtt packet length")
+		}
+	}
+	body := make([]byte, n)
+	_, err = io.ReadFull(r, body)
+	return header, body, err
+}
+
+// tlsConfig returns the TLS config for our broker connection
+func (m *mqttClient) tlsConfig(host string) (*tls.Config, error) {
+	t := &tls.Config{ServerName: host, InsecureSkipVerify: m.c.TLS.InsecureSkipVerify}
+	if m.c.TLS.CAFile != "" {
+		b, err := ioutil.ReadFile(m.c.TLS.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading mqtt ca file: %v", err)
+		}
+		t.RootCAs = x509.NewCertPool()
+		if !t.RootCAs.AppendCertsFromPEM(b) {
+			return nil, fmt.Errorf("no certificates found in mqtt ca file %s", m.c.TLS.CAFile)
+		}
+	}
+	if m.c.TLS.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(m.c.TLS.CertFile, m.c.TLS.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("error loading mqtt client certificate: %v", err)
+		}
+		t.Certificates = []tls.Certificate{cert}
+	}
+	return t, nil
+}
+
+// dial opens a connection to our broker, over TLS for tls://, ssl:// and
+// mqtts:// URLs
+func (m *mqttClient) dial() (net.Conn, error) {
+	u, err := url.Parse(m.c.Broker)
+	if err != nil {
+		return nil, fmt.Errorf("invalid mqtt broker: %v", err)
+	}
+	d := &net.Dialer{Timeout: uploadTimeout}
+	switch u.Scheme {
+	case "tcp", "mqtt":
+		host := u.Host
+		if u.Port() == "" {
+			host = net.JoinHostPort(u.Hostname(), "1883")
+		}
+		return d.Dial("tcp", host)
+	case "tls", "ssl", "mqtts":
+		host := u.Host
+		if u.Port() == "" {
+			host = net.JoinHostPort(u.Hostname(), "8883")
+		}
+		t, err := m.tlsConfig(u.Hostname())
+		if err != nil {
+			return nil, err
+		}
+		return tls.DialWithDialer(d, "tcp", host, t)
+	default:
+		return nil, fmt.Errorf("unsupported mqtt broker scheme %q", u.Scheme)
+	}
+}
+
+// connect connects and logs in to our broker, and marks us online. m.mu must
+// be held.
+func (m *mqttClient) connect() error {
+	conn, err := m.dial()
+	if err != nil {
+		return fmt.Errorf("error connecting to mqtt broker: %v", err)
+	}
+	conn.SetDeadline(time.Now().Add(uploadTimeout))
+	if _, err := conn.Write(mqttConnect(m.c, m.status)); err != nil {
+		conn.Close()
+		return fmt.Errorf("error connecting to mqtt broker: %v", err)
+	}
+	r := bufio.NewReader(conn)
+	header, ack, err := readMQTTPacket(r)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("error reading mqtt connack: %v", err)
+	}
+	if header != 0x20 || len(ack) != 2 {
+		conn.Close()
+		return fmt.Errorf("unexpected mqtt packet type %#x instead of connack", header)
+	}
+	if ack[1] != 0 {
+		conn.Close()
+		return fmt.Errorf("mqtt broker refused connection: %s", mqttConnectReturnCodes[ack[1]])
+	}
+	if _, err := conn.Write(mqttPublish(m.status, []byte("online"), 0, true, 0)); err != nil {
+		conn.Close()
+		return fmt.Errorf("error publishing to mqtt broker: %v", err)
+	}
+	conn.SetDeadline(time.Time{})
+	m.conn = conn
+	m.connects++
+	go m.read(conn, r)
+	go m.keepAlive(conn)
+	return nil
+}
+
+// read handles the packets the broker sends us over a connection until it's
+// closed, passing acknowledgements to their publishers
+func (m *mqttClient) read(conn net.Conn, r *bufio.Reader) {
+	for {
+		header, body, err := readMQTTPacket(r)
+		if err != nil {
+			break
+		}
+		switch t := header >> 4; t {
+		case 4, 5, 7: // PUBACK, PUBREC and PUBCOMP
+			if len(body) < 2 {
+				continue
+			}
+			m.mu.Lock()
+			if ch, ok := m.acks[binary.BigEndian.Uint16(body)]; ok {
+				select {
+				case ch <- t:
+				default:
+				}
+			}
+			m.mu.Unlock()
+		}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.disconnect(conn)
+}
+
+// disconnect closes a connection, failing any publishes awaiting an
+// acknowledgement over it. m.mu must be held.
+func (m *mqttClient) disconnect(conn net.Conn) {
+	conn.Close()
+	if m.conn != conn {
+		return
+	}
+	m.conn = nil
+	for id, ch := range m.acks {
+		close(ch)
+		delete(m.acks, id)
+	}
+}
+
+// keepAlive pings the broker over a connection until it's closed
+func (m *mqttClient) keepAlive(conn net.Conn) {
+	t := time.NewTicker(mqttKeepAlive / 2)
+	defer t.Stop()
+	for range t.C {
+		m.mu.Lock()
+		if m.conn != conn {
+			m.mu.Unlock()
+			return
+		}
+		if err := m.write([]byte{0xc0, 0}); err != nil {
+			m.disconnect(conn)
+		}
+		m.mu.Unlock()
+	}
+}
+
+// write writes a packet to our connection. m.mu must be held.
+func (m *mqttClient) write(p []byte) error {
+	m.conn.SetWriteDeadline(time.Now().Add(uploadTimeout))
+	_, err := m.conn.Write(p)
+	return err
+}
+
+// session connects if we aren't connected, returning the count of
+// connections so far
+func (m *mqttClient) session() (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.conn == nil {
+		if err := m.connect(); err != nil {
+			return m.connects, err
+		}
+	}
+	return m.connects, nil
+}
+
+// send writes a packet, connecting first if needed. For QoS 1 and 2 it
+// registers for the packet's acknowledgements before writing it, returning
+// the channel they arrive on.
+func (m *mqttClient) send(packet func(id uint16) []byte, qos int) (uint16, chan byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.conn == nil {
+		if err := m.connect(); err != nil {
+			return 0, nil, err
+		}
+	}
+	var id uint16
+	var ch chan byte
+	if qos > 0 {
+		m.nextID++
+		if m.nextID == 0 {
+			m.nextID = 1
+		}
+		id = m.nextID
+		ch = make(chan byte, 2)
+		m.acks[id] = ch
+	}
+	if err := m.write(packet(id)); err != nil {
+		m.disconnect(m.conn)
+		return 0, nil, fmt.Errorf("error publishing to mqtt broker: %v", err)
+	}
+	return id, ch, nil
+}
+
+// await waits for a publish's acknowledgement of the given type
+func (m *mqttClient) await(id uint16, ch chan byte, want byte) error {
+	select {
+	case t, ok := <-ch:
+		if !ok {
+			return errMQTTDisconnected
+		}
+		if t != want {
+			return fmt.Errorf("unexpected mqtt packet type %d acknowledging publish", t)
+		}
+		return nil
+	case <-time.After(uploadTimeout):
+		m.mu.Lock()
+		delete(m.acks, id)
+		m.mu.Unlock()
+		return fmt.Errorf("timed out waiting for mqtt broker to acknowledge publish")
+	}
+}
+
+// publish sends a message to a topic at a QoS, waiting for the broker to
+// acknowledge QoS 1 and 2 messages
+func (m *mqttClient) publish(topic string, payload []byte, qos int, retain bool) error {
+	id, ch, err := m.send(func(id uint16) []byte { return mqttPublish(topic, payload, qos, retain, id) }, qos)
+	if err != nil || qos == 0 {
+		return err
+	}
+	defer func() {
+		m.mu.Lock()
+		delete(m.acks, id)
+		m.mu.Unlock()
+	}()
+	if qos == 1 {
+		return m.await(id, ch, 4)
+	}
+	if err := m.await(id, ch, 5); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	if m.conn == nil {
+		m.mu.Unlock()
+		return errMQTTDisconnected
+	}
+	err = m.write(mqttPacket(0x62, binary.BigEndian.AppendUint16(nil, id)))
+	m.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("error publishing to mqtt broker: %v", err)
+	}
+	return m.await(id, ch, 7)
+}
+
+// Close marks us offline and disconnects from the broker
+func (m *mqttClient) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.conn == nil {
+		return nil
+	}
+	m.write(mqttPublish(m.status, []byte("offline"), 0, true, 0))
+	m.write([]byte{0xe0, 0})
+	m.disconnect(m.conn)
+	return nil
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+)
+
+func TestMQTTPacketLength(t *testing.T) {
+	tests := []struct {
+		n      int
+		length []byte
+	}{
+		{n: 0, length: []byte{0x00}},
+		{n: 127, length: []byte{0x7f}},
+		{n: 128, length: []byte{0x80, 0x01}},
+		{n: 16383, length: []byte{0xff, 0x7f}},
+		{n: 16384, length: []byte{0x80, 0x80, 0x01}},
+		{n: 2097151, length: []byte{0xff, 0xff, 0x7f}},
+		{n: 2097152, length: []byte{0x80, 0x80, 0x80, 0x01}},
+	}
+	for _, tt := range tests {
+		body := bytes.Repeat([]byte{'x'}, tt.n)
+		p := mqttPacket(0x30, body)
+		if p[0] != 0x30 || !bytes.Equal(p[1:1+len(tt.length)], tt.length) {
+			t.Errorf("mqttPacket() with a %d byte body has header % x, want 30 % x", tt.n, p[:1+len(tt.length)], tt.length)
+			continue
+		}
+		header, got, err := readMQTTPacket(bufio.NewReader(bytes.NewReader(p)))
+		if err != nil {
+			t.Errorf("readMQTTPacket() with a %d byte body error = %v", tt.n, err)
+			continue
+		}
+		if header != 0x30 || !bytes.Equal(got, body) {
+			t.Errorf("readMQTTPacket() with a %d byte body = %#x and %d bytes", tt.n, header, len(got))
+		}
+	}
+}
+
+func TestReadMQTTPacketErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		p    []byte
+		err  string
+	}{
+		{name: "length over four bytes", p: []byte{0x30, 0x80, 0x80, 0x80, 0x80, 0x01}, err: "malformed mqtt packet length"},
+		{name: "truncated length", p: []byte{0x30, 0x80}, err: "EOF"},
+		{name: "truncated body", p: []byte{0x30, 0x05, 'a', 'b'}, err: "unexpected EOF"},
+		{name: "empty", p: nil, err: "EOF"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := readMQTTPacket(bufio.NewReader(bytes.NewReader(tt.p)))
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("readMQTTPacket() error = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestMQTTPublish(t *testing.T) {
+	tests := []struct {
+		name   string
+		qos    int
+		retain bool
+		id     uint16
+		want   []byte
+	}{
+		{name: "qos 0", want: []byte{0x30, 0x08, 0x00, 0x03, 'a', '/', 'b', 'o', 'n', '!'}},
+		{name: "qos 0 retained", retain: true, want: []byte{0x31, 0x08, 0x00, 0x03, 'a', '/', 'b', 'o', 'n', '!'}},
+		{name: "qos 1", qos: 1, id: 0x0102, want: []byte{0x32, 0x0a, 0x00, 0x03, 'a', '/', 'b', 0x01, 0x02, 'o', 'n', '!'}},
+		{name: "qos 2 retained", qos: 2, retain: true, id: 7, want: []byte{0x35, 0x0a, 0x00, 0x03, 'a', '/', 'b', 0x00, 0x07, 'o', 'n', '!'}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mqttPublish("a/b", []byte("on!"), tt.qos, tt.retain, tt.id); !bytes.Equal(got, tt.want) {
+				t.Errorf("mqttPublish() = % x, want % x", got, tt.want)
+			}
+		})
+	}
+}
+
+// readMQTTString reads a length prefixed string from the start of b, returning
+// it and the rest of b
+func readMQTTString(t *testing.T, b []byte) (string, []byte) {
+	t.Helper()
+	if len(b) < 2 {
+		t.Fatalf("no string in % x", b)
+	}
+	n := int(binary.BigEndian.Uint16(b))
+	if len(b) < 2+n {
+		t.Fatalf("string of %d bytes in % x", n, b)
+	}
+	return string(b[2 : 2+n]), b[2+n:]
+}
+
+func TestMQTTConnect(t *testing.T) {
+	tests := []struct {
+		name  string
+		c     mqttConfig
+		flags byte
+		login []string
+	}{
+		{name: "anonymous", c: mqttConfig{ClientID: "tempest"}, flags: 0x26},
+		{name: "username", c: mqttConfig{ClientID: "tempest", Username: "user"}, flags: 0xa6, login: []string{"user"}},
+		{name: "username and password", c: mqttConfig{ClientID: "tempest", Username: "user", Password: "pass"}, flags: 0xe6, login: []string{"user", "pass"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header, body, err := readMQTTPacket(bufio.NewReader(bytes.NewReader(mqttConnect(tt.c, "tempest/status"))))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if header != 0x10 {
+				t.Fatalf("header = %#x, want 0x10", header)
+			}
+			protocol, rest := readMQTTString(t, body)
+			if protocol != "MQTT" || rest[0] != 4 {
+				t.Errorf("protocol = %s level %d, want MQTT level 4", protocol, rest[0])
+			}
+			if rest[1] != tt.flags {
+				t.Errorf("connect flags = %#x, want %#x", rest[1], tt.flags)
+			}
+			if keepAlive := binary.BigEndian.Uint16(rest[2:]); keepAlive != 60 {
+				t.Errorf("keep alive = %d, want 60", keepAlive)
+			}
+			want := append([]string{"tempest", "tempest/status", "offline"}, tt.login...)
+			rest = rest[4:]
+			for _, w := range want {
+				var s string
+				s, rest = readMQTTString(t, rest)
+				if s != w {
+					t.Errorf("payload field = %q, want %q", s, w)
+				}
+			}
+			if len(rest) > 0 {
+				t.Errorf("payload has % x left over", rest)
+			}
+		})
+	}
+}
+
+func TestValidateMQTTLogin(t *testing.T) {
+	c := defaultConfig()
+	c.Token = "token"
+	c.MQTT.Broker = "tcp://localhost:1883"
+	c.MQTT.Password = "pass"
+	if err := c.validate(); err == nil {
+		t.Error("validate() accepted an mqtt password without a username")
+	}
+	c.MQTT.Username = "user"
+	if err := c.validate(); err != nil {
+		t.Errorf("validate() error = %v", err)
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"text/template"
+)
+
+// mqttTopicData is the data our topic templates are rendered with
+type mqttTopicData struct {
+	// StationID is the station the observation is from
+	StationID string
+	// Labels are the station's labels
+	Labels map[string]string
+	// Field is the metric name of the reading published to a per field topic
+	Field string
+}
+
+// mqttSink publishes each observation to MQTT as a JSON object, as a topic
+// per reading, or both
+type mqttSink struct {
+	c          mqttConfig
+	client     *mqttClient
+	topic      *template.Template
+	fieldTopic *template.Template
+}
+
+// newMQTTSink parses the topic templates for an MQTT sink
+func newMQTTSink(c mqttConfig, client *mqttClient) (*mqttSink, error) {
+	topic, err := template.New("topic").Parse(c.TopicTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing mqtt topic template: %v", err)
+	}
+	fieldTopic, err := template.New("field_topic").Parse(c.FieldTopicTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing mqtt field topic template: %v", err)
+	}
+	return &mqttSink{c: c, client: client, topic: topic, fieldTopic: fieldTopic}, nil
+}
+
+// name implements sink
+func (s *mqttSink) name() string {
+	return "mqtt"
+}
+
+// render renders a topic template
+func (s *mqttSink) render(t *template.Template, d mqttTopicData) (string, error) {
+	var b bytes.Buffer
+	if err := t.Execute(&b, d); err != nil {
+		return "", fmt.Errorf("error rendering mqtt %s template: %v", t.Name(), err)
+	}
+	return b.String(), nil
+}
+
+// send implements sink
+func (s *mqttSink) send(rec sinkRecord) error {
+	if rec.Kind != "observation" {
+		return errSkipped
+	}
+	d := mqttTopicData{StationID: rec.StationID, Labels: rec.Labels}
+	if s.c.Publish == "json" || s.c.Publish == "both" {
+		topic, err := s.render(s.topic, d)
+		if err != nil {
+			return err
+		}
+		b, err := json.Marshal(rec)
+		if err != nil {
+			return err
+		}
+		if err := s.client.publish(topic, b, s.c.QoS, s.c.Retain); err != nil {
+			return err
+		}
+	}
+	if s.c.Publish == "fields" || s.c.Publish == "both" {
+		for name, v := range rec.Values {
+			d.Field = name
+			topic, err := s.render(s.fieldTopic, d)
+			if err != nil {
+				return err
+			}
+			if err := s.client.publish(topic, []byte(strconv.FormatFloat(v, 'f', -1, 64)), s.c.QoS, s.c.Retain); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Close disconnects from the broker
+func (s *mqttSink) Close() error {
+	return s.client.Close()
+}
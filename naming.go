@@ -0,0 +1,128 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// baseUnits is the unit suffix of each metric in conventional naming mode,
+// keyed by metric name without the namespace. Metrics converted to imperial
+// units already carry their unit and are left alone.
+//
+// These are the units readings are exported in rather than strict Prometheus
+// base units: pressures stay in hectopascals, rain in millimeters and
+// distances in kilometers, as weather is reported in those, and Home
+// Assistant's units are looked up by these suffixes.
+var baseUnits = map[string]string{
+	"station_air_density":                          "kilograms_per_cubic_meter",
+	"station_air_temperature":                      "celsius",
+	"station_air_temperature_avg":                  "celsius",
+	"station_air_temperature_min":                  "celsius",
+	"station_air_temperature_max":                  "celsius",
+	"station_delta_t":                              "celsius",
+	"station_dew_point":                            "celsius",
+	"station_feels_like":                           "celsius",
+	"station_feels_like_local":                     "celsius",
+	"station_feels_like_divergence":                "celsius",
+	"station_heat_index":                           "celsius",
+	"station_heat_index_local":                     "celsius",
+	"station_wet_bulb_temperature":                 "celsius",
+	"station_wet_bulb_globe_temperature":           "celsius",
+	"station_wind_chill":                           "celsius",
+	"station_wind_chill_local":                     "celsius",
+	"station_barometric_pressure":                  "hpa",
+	"station_sea_level_pressure":                   "hpa",
+	"station_station_pressure":                     "hpa",
+	"station_relative_humidity":                    "percent",
+	"station_brightness":                           "lux",
+	"station_solar_radiation":                      "watts_per_square_meter",
+	"station_wind_avg":                             "meters_per_second",
+	"station_wind_gust":                            "meters_per_second",
+	"station_wind_lull":                            "meters_per_second",
+	"station_wind_gust_max":                        "meters_per_second",
+	"station_rapid_wind_speed":                     "meters_per_second",
+	"station_wind_direction":                       "degrees",
+	"station_rapid_wind_direction":                 "degrees",
+	"station_precip":                               "millimeters",
+	"station_precip_total":                         "millimeters",
+	"station_precip_accum_last_1hr":                "millimeters",
+	"station_precip_accum_local_day":               "millimeters",
+	"station_precip_accum_local_yesterday":         "millimeters",
+	"station_precip_accum_local_yesterday_final":   "millimeters",
+	"station_evapotranspiration_rate":              "millimeters_per_hour",
+	"station_evapotranspiration_local_day":         "millimeters",
+	"station_precip_minutes_local_day":             "minutes",
+	"station_precip_minutes_local_yesterday":       "minutes",
+	"station_precip_minutes_local_yesterday_final": "minutes",
+	"station_lightning_strike_last_distance":       "kilometers",
+	"station_lightning_strike_last_epoch":          "seconds",
+	"station_timestamp":                            "seconds",
+	"station_rapid_wind_timestamp":                 "seconds",
+	"station_pair_air_temperature_delta":           "celsius",
+	"station_pair_dew_point_delta":                 "celsius",
+	"station_pair_relative_humidity_delta":         "percent",
+	"station_pair_sea_level_pressure_delta":        "hpa",
+	"station_pair_station_pressure_delta":          "hpa",
+	"station_pair_wind_avg_delta":                  "meters_per_second",
+	"lightning_last_strike_distance":               "kilometers",
+	"device_air_temperature":                       "celsius",
+	"device_station_pressure":                      "hpa",
+	"device_relative_humidity":                     "percent",
+	"device_brightness":                            "lux",
+	"device_solar_radiation":                       "watts_per_square_meter",
+	"device_wind_avg":                              "meters_per_second",
+	"device_wind_gust":                             "meters_per_second",
+	"device_wind_lull":                             "meters_per_second",
+	"device_wind_direction":                        "degrees",
+	"device_precip":                                "millimeters",
+	"device_lightning_strike_avg_distance":         "kilometers",
+	"device_battery_voltage":                       "volts",
+	"device_rssi":                                  "dbm",
+	"device_hub_rssi":                              "dbm",
+	"hub_rssi":                                     "dbm",
+	"forecast_air_temperature_min_tonight":         "celsius",
+	"forecast_max_gust_next_24h":                   "meters_per_second",
+	"forecast_precip_probability_max_next_12h":     "percent",
+	"forecast_hourly_air_temperature":              "celsius",
+	"forecast_hourly_precip":                       "millimeters",
+	"forecast_hourly_precip_probability":           "percent",
+	"forecast_hourly_wind_avg":                     "meters_per_second",
+	"forecast_hourly_wind_gust":                    "meters_per_second",
+	"forecast_daily_air_temperature_high":          "celsius",
+	"forecast_daily_air_temperature_low":           "celsius",
+	"forecast_daily_precip_probability":            "percent",
+}
+
+// conventionalName returns the conventional name of a metric, with its unit
+// appended, and for counters followed by _total
+func conventionalName(name string, counter bool) string {
+	name = strings.TrimSuffix(name, "_total")
+	if unit, ok := baseUnits[strings.TrimPrefix(name, ns+"_")]; ok {
+		name += "_" + unit
+	}
+	if counter {
+		name += "_total"
+	}
+	return name
+}
+
+// namingGatherer renames the metrics gathered from g to their conventional
+// names when we're configured to
+type namingGatherer struct {
+	g prometheus.Gatherer
+}
+
+// Gather implements prometheus.Gatherer
+func (n namingGatherer) Gather() ([]*dto.MetricFamily, error) {
+	mfs, err := n.g.Gather()
+	if cfg.MetricNames != "conventional" {
+		return mfs, err
+	}
+	for _, mf := range mfs {
+		name := conventionalName(mf.GetName(), mf.GetType() == dto.MetricType_COUNTER)
+		mf.Name = &name
+	}
+	return mfs, err
+}
@@ -0,0 +1,25 @@
+package main
+
+import "testing"
+
+func TestConventionalName(t *testing.T) {
+	tests := []struct {
+		name    string
+		counter bool
+		want    string
+	}{
+		{name: "tempest_station_air_temperature", want: "tempest_station_air_temperature_celsius"},
+		{name: "tempest_station_sea_level_pressure", want: "tempest_station_sea_level_pressure_hpa"},
+		{name: "tempest_station_precip_accum_local_day", want: "tempest_station_precip_accum_local_day_millimeters"},
+		{name: "tempest_station_timestamp", want: "tempest_station_timestamp_seconds"},
+		{name: "tempest_station_uv", want: "tempest_station_uv"},
+		{name: "tempest_station_air_temperature_fahrenheit", want: "tempest_station_air_temperature_fahrenheit"},
+		{name: "tempest_lightning_strikes_total", counter: true, want: "tempest_lightning_strikes_total"},
+		{name: "tempest_rain_starts", counter: true, want: "tempest_rain_starts_total"},
+	}
+	for _, tt := range tests {
+		if got := conventionalName(tt.name, tt.counter); got != tt.want {
+			t.Errorf("conventionalName(%s, %v) = %s, want %s", tt.name, tt.counter, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// onlineCheckInterval is how often we check whether our stations are online
+const onlineCheckInterval = 30 * time.Second
+
+var (
+	// stationOnline holds whether each station was last seen online
+	stationOnline = make(map[string]bool)
+	// lastNotified holds when each station was last notified of each event
+	lastNotified = make(map[string]time.Time)
+	// webhookClient is the http client used to send notifications
+	webhookClient = &http.Client{Timeout: 10 * time.Second}
+)
+
+// webhookEvent is the payload sent to our webhook
+type webhookEvent struct {
+	Event           string  `json:"event"`
+	StationID       int     `json:"station_id"`
+	StationName     string  `json:"station_name"`
+	StatusCode      int     `json:"status_code"`
+	LastObservation float64 `json:"last_observation"`
+	Timestamp       int64   `json:"timestamp"`
+	Repeat          bool    `json:"repeat,omitempty"`
+}
+
+// isOnline returns whether a station is online based on its last reported
+// status and the age of its latest observation, if we've had one
+func isOnline(r response, o observation, observed bool, now time.Time) bool {
+	if r.Status.Code != 0 || !observed {
+		return false
+	}
+	age := now.Sub(time.Unix(int64(o.Timestamp), 0))
+	return age <= time.Duration(cfg.OfflineAfter)
+}
+
+// watchOnline checks whether our stations are online on a timer, so stations
+// the API stops returning and hubs that stop broadcasting go offline too
+func watchOnline() {
+	defer reportPanic("notify")
+	for {
+		configMu.RLock()
+		checkStations(time.Now())
+		configMu.RUnlock()
+		time.Sleep(onlineCheckInterval)
+	}
+}
+
+// checkStations checks whether each station we poll or have heard from is
+// online
+func checkStations(now time.Time) {
+	ids := make(map[string]bool)
+	if cfg.Source == "api" {
+		for _, s := range stations() {
+			ids[s] = true
+		}
+	}
+	metricsMu.RLock()
+	for s := range latest {
+		ids[s] = true
+	}
+	metricsMu.RUnlock()
+	for id := range ids {
+		checkOnline(id, now)
+	}
+}
+
+// checkOnline notifies our webhook when a station goes offline or comes back
+// online, and repeatedly while it stays offline if configured. The first
+// check of a station only records its state.
+func checkOnline(id string, now time.Time) {
+	metricsMu.RLock()
+	r := stationResponses[id]
+	o, observed := latest[id]
+	metricsMu.RUnlock()
+	online := isOnline(r, o, observed, now)
+	was, seen := stationOnline[id]
+	stationOnline[id] = online
+	if !seen {
+		return
+	}
+	// hubs heard over UDP are identified by serial number rather than a
+	// numeric station ID
+	stationID, _ := strconv.Atoi(id)
+	ev := webhookEvent{
+		Event:       "offline",
+		StationID:   stationID,
+		StationName: r.StationName,
+		StatusCode:  r.Status.Code,
+		Timestamp:   now.Unix(),
+	}
+	if online {
+		ev.Event = "online"
+	}
+	if observed {
+		ev.LastObservation = o.Timestamp
+	}
+	if was == online {
+		repeat := time.Duration(cfg.Notify.Repeat)
+		if online || repeat <= 0 || now.Sub(lastNotified[id+"/"+ev.Event]) < repeat {
+			return
+		}
+		ev.Repeat = true
+	} else {
+		slog.Info("station status changed", "station_id", id, "status", ev.Event)
+	}
+	if !shouldNotify(id, ev.Event, location(r.Timezone), now) {
+		return
+	}
+	rec := sinkRecord{Kind: "event", StationID: id, Event: &ev, Time: now}
+	publish(rec)
+	broadcast(rec)
+	if cfg.WebhookURL == "" {
+		return
+	}
+	go func() {
+		if err := sendWebhook(ev); err != nil {
+			slog.Error(err.Error())
+			reportFailure("webhook", err)
+		} else {
+			reportSuccess("webhook")
+		}
+	}()
+}
+
+// shouldNotify returns whether a station may be notified of an event now,
+// given our quiet hours and cooldowns, and records it as notified if so
+func shouldNotify(id, event string, loc *time.Location, now time.Time) bool {
+	for _, w := range cfg.Notify.QuietHours {
+		if inWindow(w, now.In(loc)) {
+			slog.Debug("silencing notification in quiet hours", "station_id", id, "event", event, "quiet_hours", w)
+			return false
+		}
+	}
+	key := id + "/" + event
+	if last, ok := lastNotified[key]; ok && now.Sub(last) < time.Duration(cfg.Notify.Cooldown[event]) {
+		slog.Debug("silencing notification during its cooldown", "station_id", id, "event", event)
+		return false
+	}
+	lastNotified[key] = now
+	return true
+}
+
+// parseWindow parses a window of the day like "22:00-07:00" into its start
+// and end as minutes since midnight
+func parseWindow(w string) (int, int, error) {
+	parts := strings.SplitN(w, "-", 2)
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("invalid quiet hours %q, expected HH:MM-HH:MM", w)
+	}
+	var mins [2]int
+	for i, p := range parts {
+		t, err := time.Parse("15:04", strings.TrimSpace(p))
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid quiet hours %q: %v", w, err)
+		}
+		mins[i] = t.Hour()*60 + t.Minute()
+	}
+	return mins[0], mins[1], nil
+}
+
+// inWindow returns whether t's time of day is within the window w, which may
+// wrap past midnight
+func inWindow(w string, t time.Time) bool {
+	start, end, err := parseWindow(w)
+	if err != nil {
+		return false
+	}
+	m := t.Hour()*60 + t.Minute()
+	if start <= end {
+		return m >= start && m < end
+	}
+	return m >= start || m < end
+}
+
+// sendWebhook posts an event to our webhook
+func sendWebhook(ev webhookEvent) error {
+	b, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	resp, err := webhookClient.Post(cfg.WebhookURL, "application/json", bytes.NewReader(b))
+	if err != nil {
+		return fmt.Errorf("error sending webhook: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("error sending webhook: %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nalbury/tempest-exporter/pkg/weatherflow"
+)
+
+func TestParseWindow(t *testing.T) {
+	tests := []struct {
+		w          string
+		start, end int
+		err        bool
+	}{
+		{w: "22:00-07:00", start: 22 * 60, end: 7 * 60},
+		{w: "09:30 - 17:45", start: 9*60 + 30, end: 17*60 + 45},
+		{w: "22:00", err: true},
+		{w: "22:00-7pm", err: true},
+		{w: "25:00-07:00", err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.w, func(t *testing.T) {
+			start, end, err := parseWindow(tt.w)
+			if (err != nil) != tt.err {
+				t.Fatalf("parseWindow() error = %v, want error %v", err, tt.err)
+			}
+			if start != tt.start || end != tt.end {
+				t.Errorf("parseWindow() = %d, %d, want %d, %d", start, end, tt.start, tt.end)
+			}
+		})
+	}
+}
+
+func TestInWindow(t *testing.T) {
+	at := func(h, m int) time.Time { return time.Date(2026, 10, 17, h, m, 0, 0, time.UTC) }
+	tests := []struct {
+		w    string
+		t    time.Time
+		want bool
+	}{
+		{w: "09:00-17:00", t: at(12, 0), want: true},
+		{w: "09:00-17:00", t: at(9, 0), want: true},
+		{w: "09:00-17:00", t: at(17, 0), want: false},
+		{w: "09:00-17:00", t: at(8, 59), want: false},
+		{w: "22:00-07:00", t: at(23, 30), want: true},
+		{w: "22:00-07:00", t: at(3, 0), want: true},
+		{w: "22:00-07:00", t: at(7, 0), want: false},
+		{w: "22:00-07:00", t: at(12, 0), want: false},
+		{w: "invalid", t: at(12, 0), want: false},
+	}
+	for _, tt := range tests {
+		if got := inWindow(tt.w, tt.t); got != tt.want {
+			t.Errorf("inWindow(%q, %s) = %v, want %v", tt.w, tt.t.Format("15:04"), got, tt.want)
+		}
+	}
+}
+
+func TestIsOnline(t *testing.T) {
+	defer func(d duration) { cfg.OfflineAfter = d }(cfg.OfflineAfter)
+	cfg.OfflineAfter = duration(10 * time.Minute)
+	now := time.Unix(1700000000, 0)
+	obs := func(age time.Duration) observation {
+		return observation{Observation: weatherflow.Observation{Timestamp: float64(now.Add(-age).Unix())}}
+	}
+	var failed response
+	failed.Status.Code = 2
+	tests := []struct {
+		name     string
+		r        response
+		o        observation
+		observed bool
+		want     bool
+	}{
+		{name: "recent observation", o: obs(time.Minute), observed: true, want: true},
+		{name: "stale observation", o: obs(time.Hour), observed: true, want: false},
+		{name: "no observation", want: false},
+		{name: "status not ok", r: failed, o: obs(time.Minute), observed: true, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isOnline(tt.r, tt.o, tt.observed, now); got != tt.want {
+				t.Errorf("isOnline() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,170 @@
+// Code generated by gen_metrics.go; DO NOT EDIT.
+
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/nalbury/tempest-exporter/pkg/weatherflow"
+)
+
+// observationGauges returns a gauge for each numeric field of an observation,
+// keyed by metric name
+func observationGauges(labelNames []string) MetricsMap {
+	return MetricsMap{
+		"air_density":                          prometheus.NewGaugeVec(prometheus.GaugeOpts{Namespace: ns, Subsystem: ss, Name: "air_density", Help: "Density of the air in kg/m³"}, labelNames),
+		"air_temperature":                      prometheus.NewGaugeVec(prometheus.GaugeOpts{Namespace: ns, Subsystem: ss, Name: "air_temperature", Help: "Air temperature"}, labelNames),
+		"barometric_pressure":                  prometheus.NewGaugeVec(prometheus.GaugeOpts{Namespace: ns, Subsystem: ss, Name: "barometric_pressure", Help: "Barometric pressure"}, labelNames),
+		"brightness":                           prometheus.NewGaugeVec(prometheus.GaugeOpts{Namespace: ns, Subsystem: ss, Name: "brightness", Help: "Illuminance in lux"}, labelNames),
+		"delta_t":                              prometheus.NewGaugeVec(prometheus.GaugeOpts{Namespace: ns, Subsystem: ss, Name: "delta_t", Help: "Difference between the air and wet bulb temperatures"}, labelNames),
+		"dew_point":                            prometheus.NewGaugeVec(prometheus.GaugeOpts{Namespace: ns, Subsystem: ss, Name: "dew_point", Help: "Dew point temperature"}, labelNames),
+		"feels_like":                           prometheus.NewGaugeVec(prometheus.GaugeOpts{Namespace: ns, Subsystem: ss, Name: "feels_like", Help: "Apparent temperature, from the heat index or wind chill"}, labelNames),
+		"heat_index":                           prometheus.NewGaugeVec(prometheus.GaugeOpts{Namespace: ns, Subsystem: ss, Name: "heat_index", Help: "Heat index temperature"}, labelNames),
+		"lightning_strike_count":               prometheus.NewGaugeVec(prometheus.GaugeOpts{Namespace: ns, Subsystem: ss, Name: "lightning_strike_count", Help: "Lightning strikes detected in the observation interval"}, labelNames),
+		"lightning_strike_count_last_1hr":      prometheus.NewGaugeVec(prometheus.GaugeOpts{Namespace: ns, Subsystem: ss, Name: "lightning_strike_count_last_1hr", Help: "Lightning strikes detected in the last hour"}, labelNames),
+		"lightning_strike_count_last_3hr":      prometheus.NewGaugeVec(prometheus.GaugeOpts{Namespace: ns, Subsystem: ss, Name: "lightning_strike_count_last_3hr", Help: "Lightning strikes detected in the last 3 hours"}, labelNames),
+		"lightning_strike_last_distance":       prometheus.NewGaugeVec(prometheus.GaugeOpts{Namespace: ns, Subsystem: ss, Name: "lightning_strike_last_distance", Help: "Distance to the most recent lightning strike"}, labelNames),
+		"lightning_strike_last_epoch":          prometheus.NewGaugeVec(prometheus.GaugeOpts{Namespace: ns, Subsystem: ss, Name: "lightning_strike_last_epoch", Help: "Unix timestamp of the most recent lightning strike"}, labelNames),
+		"precip":                               prometheus.NewGaugeVec(prometheus.GaugeOpts{Namespace: ns, Subsystem: ss, Name: "precip", Help: "Precipitation in the observation interval"}, labelNames),
+		"precip_accum_last_1hr":                prometheus.NewGaugeVec(prometheus.GaugeOpts{Namespace: ns, Subsystem: ss, Name: "precip_accum_last_1hr", Help: "Precipitation accumulated in the last hour"}, labelNames),
+		"precip_accum_local_day":               prometheus.NewGaugeVec(prometheus.GaugeOpts{Namespace: ns, Subsystem: ss, Name: "precip_accum_local_day", Help: "Precipitation accumulated today in local time"}, labelNames),
+		"precip_accum_local_yesterday":         prometheus.NewGaugeVec(prometheus.GaugeOpts{Namespace: ns, Subsystem: ss, Name: "precip_accum_local_yesterday", Help: "Preliminary precipitation accumulated yesterday in local time, before any Rain Check adjustment"}, labelNames),
+		"precip_accum_local_yesterday_final":   prometheus.NewGaugeVec(prometheus.GaugeOpts{Namespace: ns, Subsystem: ss, Name: "precip_accum_local_yesterday_final", Help: "Final precipitation accumulated yesterday in local time, only exported once the API reports it"}, labelNames),
+		"precip_analysis_type_yesterday":       prometheus.NewGaugeVec(prometheus.GaugeOpts{Namespace: ns, Subsystem: ss, Name: "precip_analysis_type_yesterday", Help: "Rain Check analysis applied to yesterday's precipitation: 0 none, 1 with display on, 2 with display off"}, labelNames),
+		"precip_minutes_local_day":             prometheus.NewGaugeVec(prometheus.GaugeOpts{Namespace: ns, Subsystem: ss, Name: "precip_minutes_local_day", Help: "Minutes of precipitation today in local time"}, labelNames),
+		"precip_minutes_local_yesterday":       prometheus.NewGaugeVec(prometheus.GaugeOpts{Namespace: ns, Subsystem: ss, Name: "precip_minutes_local_yesterday", Help: "Preliminary minutes of precipitation yesterday in local time, before any Rain Check adjustment"}, labelNames),
+		"precip_minutes_local_yesterday_final": prometheus.NewGaugeVec(prometheus.GaugeOpts{Namespace: ns, Subsystem: ss, Name: "precip_minutes_local_yesterday_final", Help: "Final minutes of precipitation yesterday in local time, only exported once the API reports it"}, labelNames),
+		"relative_humidity":                    prometheus.NewGaugeVec(prometheus.GaugeOpts{Namespace: ns, Subsystem: ss, Name: "relative_humidity", Help: "Relative humidity in percent"}, labelNames),
+		"sea_level_pressure":                   prometheus.NewGaugeVec(prometheus.GaugeOpts{Namespace: ns, Subsystem: ss, Name: "sea_level_pressure", Help: "Pressure adjusted to sea level"}, labelNames),
+		"solar_radiation":                      prometheus.NewGaugeVec(prometheus.GaugeOpts{Namespace: ns, Subsystem: ss, Name: "solar_radiation", Help: "Solar radiation in W/m²"}, labelNames),
+		"station_pressure":                     prometheus.NewGaugeVec(prometheus.GaugeOpts{Namespace: ns, Subsystem: ss, Name: "station_pressure", Help: "Pressure at the station"}, labelNames),
+		"timestamp":                            prometheus.NewGaugeVec(prometheus.GaugeOpts{Namespace: ns, Subsystem: ss, Name: "timestamp", Help: "Unix timestamp of the observation"}, labelNames),
+		"uv":                                   prometheus.NewGaugeVec(prometheus.GaugeOpts{Namespace: ns, Subsystem: ss, Name: "uv", Help: "UV index"}, labelNames),
+		"wet_bulb_temperature":                 prometheus.NewGaugeVec(prometheus.GaugeOpts{Namespace: ns, Subsystem: ss, Name: "wet_bulb_temperature", Help: "Wet bulb temperature"}, labelNames),
+		"wind_avg":                             prometheus.NewGaugeVec(prometheus.GaugeOpts{Namespace: ns, Subsystem: ss, Name: "wind_avg", Help: "Average wind speed over the observation interval"}, labelNames),
+		"wind_chill":                           prometheus.NewGaugeVec(prometheus.GaugeOpts{Namespace: ns, Subsystem: ss, Name: "wind_chill", Help: "Wind chill temperature"}, labelNames),
+		"wind_direction":                       prometheus.NewGaugeVec(prometheus.GaugeOpts{Namespace: ns, Subsystem: ss, Name: "wind_direction", Help: "Wind direction in degrees"}, labelNames),
+		"wind_gust":                            prometheus.NewGaugeVec(prometheus.GaugeOpts{Namespace: ns, Subsystem: ss, Name: "wind_gust", Help: "Highest wind speed over the observation interval"}, labelNames),
+		"wind_lull":                            prometheus.NewGaugeVec(prometheus.GaugeOpts{Namespace: ns, Subsystem: ss, Name: "wind_lull", Help: "Lowest wind speed over the observation interval"}, labelNames),
+	}
+}
+
+// fieldValues returns the numeric fields of an observation keyed by metric
+// name, leaving out optional ones the API hasn't reported
+func fieldValues(o weatherflow.Observation) map[string]float64 {
+	v := map[string]float64{
+		"air_density":                     o.AirDensity,
+		"air_temperature":                 o.AirTemperature,
+		"barometric_pressure":             o.BarometricPressure,
+		"brightness":                      o.Brightness,
+		"delta_t":                         o.DeltaT,
+		"dew_point":                       o.DewPoint,
+		"feels_like":                      o.FeelsLike,
+		"heat_index":                      o.HeatIndex,
+		"lightning_strike_count":          o.LightningStrikeCount,
+		"lightning_strike_count_last_1hr": o.LightningStrikeCountLast1hr,
+		"lightning_strike_count_last_3hr": o.LightningStrikeCountLast3hr,
+		"lightning_strike_last_distance":  o.LightningStrikeLastDistance,
+		"lightning_strike_last_epoch":     o.LightningStrikeLastEpoch,
+		"precip":                          o.Precip,
+		"precip_accum_last_1hr":           o.PrecipAccumLast1hr,
+		"precip_accum_local_day":          o.PrecipAccumLocalDay,
+		"precip_accum_local_yesterday":    o.PrecipAccumLocalYesterday,
+		"precip_analysis_type_yesterday":  o.PrecipAnalysisTypeYesterday,
+		"precip_minutes_local_day":        o.PrecipMinutesLocalDay,
+		"precip_minutes_local_yesterday":  o.PrecipMinutesLocalYesterday,
+		"relative_humidity":               o.RelativeHumidity,
+		"sea_level_pressure":              o.SeaLevelPressure,
+		"solar_radiation":                 o.SolarRadiation,
+		"station_pressure":                o.StationPressure,
+		"timestamp":                       o.Timestamp,
+		"uv":                              o.Uv,
+		"wet_bulb_temperature":            o.WetBulbTemperature,
+		"wind_avg":                        o.WindAvg,
+		"wind_chill":                      o.WindChill,
+		"wind_direction":                  o.WindDirection,
+		"wind_gust":                       o.WindGust,
+		"wind_lull":                       o.WindLull,
+	}
+	if o.PrecipAccumLocalYesterdayFinal != nil {
+		v["precip_accum_local_yesterday_final"] = *o.PrecipAccumLocalYesterdayFinal
+	}
+	if o.PrecipMinutesLocalYesterdayFinal != nil {
+		v["precip_minutes_local_yesterday_final"] = *o.PrecipMinutesLocalYesterdayFinal
+	}
+	return v
+}
+
+// setFieldValue sets the numeric field of an observation for a metric name
+func setFieldValue(o *weatherflow.Observation, metric string, v float64) {
+	switch metric {
+	case "air_density":
+		o.AirDensity = v
+	case "air_temperature":
+		o.AirTemperature = v
+	case "barometric_pressure":
+		o.BarometricPressure = v
+	case "brightness":
+		o.Brightness = v
+	case "delta_t":
+		o.DeltaT = v
+	case "dew_point":
+		o.DewPoint = v
+	case "feels_like":
+		o.FeelsLike = v
+	case "heat_index":
+		o.HeatIndex = v
+	case "lightning_strike_count":
+		o.LightningStrikeCount = v
+	case "lightning_strike_count_last_1hr":
+		o.LightningStrikeCountLast1hr = v
+	case "lightning_strike_count_last_3hr":
+		o.LightningStrikeCountLast3hr = v
+	case "lightning_strike_last_distance":
+		o.LightningStrikeLastDistance = v
+	case "lightning_strike_last_epoch":
+		o.LightningStrikeLastEpoch = v
+	case "precip":
+		o.Precip = v
+	case "precip_accum_last_1hr":
+		o.PrecipAccumLast1hr = v
+	case "precip_accum_local_day":
+		o.PrecipAccumLocalDay = v
+	case "precip_accum_local_yesterday":
+		o.PrecipAccumLocalYesterday = v
+	case "precip_accum_local_yesterday_final":
+		o.PrecipAccumLocalYesterdayFinal = &v
+	case "precip_analysis_type_yesterday":
+		o.PrecipAnalysisTypeYesterday = v
+	case "precip_minutes_local_day":
+		o.PrecipMinutesLocalDay = v
+	case "precip_minutes_local_yesterday":
+		o.PrecipMinutesLocalYesterday = v
+	case "precip_minutes_local_yesterday_final":
+		o.PrecipMinutesLocalYesterdayFinal = &v
+	case "relative_humidity":
+		o.RelativeHumidity = v
+	case "sea_level_pressure":
+		o.SeaLevelPressure = v
+	case "solar_radiation":
+		o.SolarRadiation = v
+	case "station_pressure":
+		o.StationPressure = v
+	case "timestamp":
+		o.Timestamp = v
+	case "uv":
+		o.Uv = v
+	case "wet_bulb_temperature":
+		o.WetBulbTemperature = v
+	case "wind_avg":
+		o.WindAvg = v
+	case "wind_chill":
+		o.WindChill = v
+	case "wind_direction":
+		o.WindDirection = v
+	case "wind_gust":
+		o.WindGust = v
+	case "wind_lull":
+		o.WindLull = v
+	}
+}
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"golang.org/x/net/http2"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// otlpCumulative is OTLP's cumulative aggregation temporality
+const otlpCumulative = 2
+
+// otlpStart is when we started, which our counters, histograms and summaries
+// accumulate from
+var otlpStart = time.Now()
+
+// otlpMessage builds a protobuf message field by field
+type otlpMessage []byte
+
+// bytes appends a length delimited field
+func (m otlpMessage) bytes(num protowire.Number, b []byte) otlpMessage {
+	m = protowire.AppendTag(m, num, protowire.BytesType)
+	return protowire.AppendBytes(m, b)
+}
+
+// string appends a string field
+func (m otlpMessage) string(num protowire.Number, s string) otlpMessage {
+	m = protowire.AppendTag(m, num, protowire.BytesType)
+	return protowire.AppendString(m, s)
+}
+
+// fixed64 appends a fixed64 field
+func (m otlpMessage) fixed64(num protowire.Number, v uint64) otlpMessage {
+	m = protowire.AppendTag(m, num, protowire.Fixed64Type)
+	return protowire.AppendFixed64(m, v)
+}
+
+// double appends a double field
+func (m otlpMessage) double(num protowire.Number, v float64) otlpMessage {
+	return m.fixed64(num, math.Float64bits(v))
+}
+
+// varint appends a varint field
+func (m otlpMessage) varint(num protowire.Number, v uint64) otlpMessage {
+	m = protowire.AppendTag(m, num, protowire.VarintType)
+	return protowire.AppendVarint(m, v)
+}
+
+// otlpAttributes encodes labels as KeyValue attributes with string values,
+// sorted by key, as the given field
+func otlpAttributes(m otlpMessage, num protowire.Number, labels map[string]string) otlpMessage {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		value := otlpMessage{}.string(1, labels[k])
+		m = m.bytes(num, otlpMessage{}.string(1, k).bytes(2, value))
+	}
+	return m
+}
+
+// otlpResource holds the metrics of one resource, a station or the exporter
+// itself, as encoded data points keyed by metric name
+type otlpResource struct {
+	attributes map[string]string
+	metrics    map[string]otlpMessage
+	help       map[string]string
+	types      map[string]dto.MetricType
+}
+
+// otlpDataPoint encodes a metric's data point, with the labels that aren't
+// resource attributes as its attributes
+func otlpDataPoint(mf *dto.MetricFamily, m *dto.Metric, attributes map[string]string, now time.Time) otlpMessage {
+	ts := uint64(now.UnixNano())
+	if m.TimestampMs != nil {
+		ts = uint64(m.GetTimestampMs()) * uint64(time.Millisecond)
+	}
+	start := uint64(otlpStart.UnixNano())
+	var p otlpMessage
+	switch mf.GetType() {
+	case dto.MetricType_COUNTER:
+		p = otlpAttributes(p, 7, attributes).fixed64(2, start).fixed64(3, ts).double(4, m.GetCounter().GetValue())
+	case dto.MetricType_GAUGE:
+		p = otlpAttributes(p, 7, attributes).fixed64(3, ts).double(4, m.GetGauge().GetValue())
+	case dto.MetricType_UNTYPED:
+		p = otlpAttributes(p, 7, attributes).fixed64(3, ts).double(4, m.GetUntyped().GetValue())
+	case dto.MetricType_SUMMARY:
+		s := m.GetSummary()
+		p = otlpAttributes(p, 7, attributes).fixed64(2, start).fixed64(3, ts).
+			fixed64(4, s.GetSampleCount()).double(5, s.GetSampleSum())
+		for _, q := range s.Quantile {
+			p = p.bytes(6, otlpMessage{}.double(1, q.GetQuantile()).double(2, q.GetValue()))
+		}
+	case dto.MetricType_HISTOGRAM:
+		h := m.GetHistogram()
+		// OTLP counts each bucket on its own, with an extra one above the
+		// last bound, where Prometheus buckets are cumulative
+		var counts, bounds []byte
+		var prev uint64
+		for _, b := range h.Bucket {
+			if math.IsInf(b.GetUpperBound(), 1) {
+				continue
+			}
+			counts = binary.LittleEndian.AppendUint64(counts, b.GetCumulativeCount()-prev)
+			bounds = binary.LittleEndian.AppendUint64(bounds, math.Float64bits(b.GetUpperBound()))
+			prev = b.GetCumulativeCount()
+		}
+		counts = binary.LittleEndian.AppendUint64(counts, h.GetSampleCount()-prev)
+		p = otlpAttributes(p, 9, attributes).fixed64(2, start).fixed64(3, ts).
+			fixed64(4, h.GetSampleCount()).double(5, h.GetSampleSum()).
+			bytes(6, counts).bytes(7, bounds)
+	}
+	return p
+}
+
+// otlpMetric encodes a Metric message from its data points
+func otlpMetric(name, help string, t dto.MetricType, points otlpMessage) otlpMessage {
+	m := otlpMessage{}.string(1, name).string(2, help)
+	switch t {
+	case dto.MetricType_COUNTER:
+		return m.bytes(7, points.varint(2, otlpCumulative).varint(3, 1))
+	case dto.MetricType_SUMMARY:
+		return m.bytes(11, points)
+	case dto.MetricType_HISTOGRAM:
+		return m.bytes(9, points.varint(2, otlpCumulative))
+	default:
+		return m.bytes(5, points)
+	}
+}
+
+// encodeOTLP encodes metric families as an ExportMetricsServiceRequest, with
+// a resource for each station whose attributes are the station's labels
+func encodeOTLP(mfs []*dto.MetricFamily, now time.Time) []byte {
+	var r response
+	stationLabels := r.allLabels()
+	resources := make(map[string]*otlpResource)
+	var keys []string
+	for _, mf := range mfs {
+		for _, m := range mf.Metric {
+			resource := map[string]string{"service.name": "tempest-exporter"}
+			attributes := make(map[string]string)
+			for _, lp := range m.Label {
+				if _, ok := stationLabels[lp.GetName()]; ok {
+					resource[lp.GetName()] = lp.GetValue()
+				} else {
+					attributes[lp.GetName()] = lp.GetValue()
+				}
+			}
+			key := string(otlpAttributes(nil, 1, resource))
+			res, ok := resources[key]
+			if !ok {
+				res = &otlpResource{
+					attributes: resource,
+					metrics:    make(map[string]otlpMessage),
+					help:       make(map[string]string),
+					types:      make(map[string]dto.MetricType),
+				}
+				resources[key] = res
+				keys = append(keys, key)
+			}
+			// data points are the first field of each type of metric, so we
+			// collect them before knowing the type's other fields
+			res.metrics[mf.GetName()] = res.metrics[mf.GetName()].bytes(1, otlpDataPoint(mf, m, attributes, now))
+			res.help[mf.GetName()] = mf.GetHelp()
+			res.types[mf.GetName()] = mf.GetType()
+		}
+	}
+	sort.Strings(keys)
+	scope := otlpMessage{}.string(1, "tempest-exporter")
+	var req otlpMessage
+	for _, key := range keys {
+		res := resources[key]
+		names := make([]string, 0, len(res.metrics))
+		for name := range res.metrics {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		sm := otlpMessage{}.bytes(1, scope)
+		for _, name := range names {
+			sm = sm.bytes(2, otlpMetric(name, res.help[name], res.types[name], res.metrics[name]))
+		}
+		rm := otlpMessage{}.bytes(1, otlpAttributes(nil, 1, res.attributes)).bytes(2, sm)
+		req = req.bytes(1, rm)
+	}
+	return req
+}
+
+// otlpClient returns the http client for our OTLP endpoint, speaking HTTP/2
+// for gRPC, without TLS for http:// endpoints
+func otlpClient(c otlpConfig) *http.Client {
+	client := &http.Client{Timeout: time.Duration(c.Timeout)}
+	if c.Protocol != "grpc" {
+		return client
+	}
+	t := &http2.Transport{}
+	if strings.HasPrefix(c.Endpoint, "http://") {
+		t.AllowHTTP = true
+		t.DialTLSContext = func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, addr)
+		}
+	}
+	client.Transport = t
+	return client
+}
+
+// otlpExport sends an encoded export request to our OTLP endpoint
+func otlpExport(client *http.Client, c otlpConfig, body []byte) error {
+	u, err := url.Parse(c.Endpoint)
+	if err != nil {
+		return fmt.Errorf("invalid otlp endpoint: %v", err)
+	}
+	contentType := "application/x-protobuf"
+	if c.Protocol == "grpc" {
+		u = u.JoinPath("/opentelemetry.proto.collector.metrics.v1.MetricsService/Export")
+		contentType = "application/grpc"
+		// gRPC frames each message with a compression flag and its length
+		frame := make([]byte, 5, 5+len(body))
+		binary.BigEndian.PutUint32(frame[1:], uint32(len(body)))
+		body = append(frame, body...)
+	} else if !strings.HasSuffix(u.Path, "/v1/metrics") {
+		u = u.JoinPath("/v1/metrics")
+	}
+	req, err := http.NewRequest(http.MethodPost, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error creating otlp request: %v", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	if c.Protocol == "grpc" {
+		req.Header.Set("TE", "trailers")
+	}
+	for k, v := range c.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error exporting to otlp endpoint: %v", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("error exporting to otlp endpoint: %s", resp.Status)
+	}
+	if c.Protocol == "grpc" {
+		// errors come in the trailers, or the headers if there's no body
+		status, msg := resp.Trailer.Get("Grpc-Status"), resp.Trailer.Get("Grpc-Message")
+		if status == "" {
+			status, msg = resp.Header.Get("Grpc-Status"), resp.Header.Get("Grpc-Message")
+		}
+		if code, _ := strconv.Atoi(status); code != 0 {
+			return fmt.Errorf("error exporting to otlp endpoint: grpc status %d: %s", code, msg)
+		}
+	}
+	return nil
+}
+
+// exportOTLP exports our weather metrics to our OTLP endpoint every interval,
+// with observation readings at their observation's time
+func exportOTLP(weather prometheus.Gatherer) {
+	defer reportPanic("otlp")
+	// reuse our client and its connections until a reload changes how we
+	// connect
+	var client *http.Client
+	var clientFor otlpConfig
+	for {
+		configMu.RLock()
+		c := cfg.OTLP
+		mfs, err := exportGatherer(weather, cfg.Units, true).Gather()
+		configMu.RUnlock()
+		if client == nil || c.Endpoint != clientFor.Endpoint || c.Protocol != clientFor.Protocol || c.Timeout != clientFor.Timeout {
+			if client != nil {
+				client.CloseIdleConnections()
+			}
+			client, clientFor = otlpClient(c), c
+		}
+		if err != nil {
+			err = fmt.Errorf("error gathering metrics to export to otlp: %v", err)
+		} else if len(mfs) > 0 {
+			err = otlpExport(client, c, encodeOTLP(mfs, time.Now()))
+		}
+		if err != nil {
+			slog.Error(err.Error())
+			sinkSends.WithLabelValues("otlp", "failure").Inc()
+			reportFailure("otlp", err)
+		} else {
+			sinkSends.WithLabelValues("otlp", "success").Inc()
+			sinkLastSuccess.WithLabelValues("otlp").SetToCurrentTime()
+			reportSuccess("otlp")
+		}
+		time.Sleep(time.Duration(c.Interval))
+	}
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+)
+
+// otlpKeys returns the keys of encoded KeyValue attributes, in order
+func otlpKeys(t *testing.T, fields []protoField) []string {
+	t.Helper()
+	var keys []string
+	for _, f := range fields {
+		keys = append(keys, string(protoFields(decodeProto(t, f.bytes), 1)[0].bytes))
+	}
+	return keys
+}
+
+func TestOTLPAttributes(t *testing.T) {
+	m := otlpAttributes(nil, 7, map[string]string{"station_id": "1", "agg": "max", "device": "ST-1"})
+	attrs := protoFields(decodeProto(t, m), 7)
+	got := otlpKeys(t, attrs)
+	want := []string{"agg", "device", "station_id"}
+	if len(got) != len(want) {
+		t.Fatalf("otlpAttributes() keys = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("otlpAttributes() keys = %v, want %v", got, want)
+		}
+	}
+	value := decodeProto(t, protoFields(decodeProto(t, attrs[0].bytes), 2)[0].bytes)
+	if s := string(protoFields(value, 1)[0].bytes); s != "max" {
+		t.Errorf("otlpAttributes() agg value = %q, want %q", s, "max")
+	}
+}
+
+func TestOTLPDataPoint(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	start := uint64(otlpStart.UnixNano())
+	ts := uint64(now.UnixNano())
+	tests := []struct {
+		name       string
+		mf         *dto.MetricFamily
+		attributes protowire.Number
+		fixed      map[protowire.Number]uint64
+		doubles    map[protowire.Number]float64
+	}{
+		{
+			name: "gauge",
+			mf: &dto.MetricFamily{Type: dto.MetricType_GAUGE.Enum(), Metric: []*dto.Metric{{
+				Gauge: &dto.Gauge{Value: proto.Float64(21.5)},
+			}}},
+			attributes: 7,
+			fixed:      map[protowire.Number]uint64{3: ts},
+			doubles:    map[protowire.Number]float64{4: 21.5},
+		},
+		{
+			name: "gauge with timestamp",
+			mf: &dto.MetricFamily{Type: dto.MetricType_GAUGE.Enum(), Metric: []*dto.Metric{{
+				Gauge:       &dto.Gauge{Value: proto.Float64(3)},
+				TimestampMs: proto.Int64(1699999940000),
+			}}},
+			attributes: 7,
+			fixed:      map[protowire.Number]uint64{3: 1699999940000 * uint64(time.Millisecond)},
+			doubles:    map[protowire.Number]float64{4: 3},
+		},
+		{
+			name: "counter",
+			mf: &dto.MetricFamily{Type: dto.MetricType_COUNTER.Enum(), Metric: []*dto.Metric{{
+				Counter: &dto.Counter{Value: proto.Float64(5)},
+			}}},
+			attributes: 7,
+			fixed:      map[protowire.Number]uint64{2: start, 3: ts},
+			doubles:    map[protowire.Number]float64{4: 5},
+		},
+		{
+			name: "summary",
+			mf: &dto.MetricFamily{Type: dto.MetricType_SUMMARY.Enum(), Metric: []*dto.Metric{{
+				Summary: &dto.Summary{SampleCount: proto.Uint64(2), SampleSum: proto.Float64(1.5)},
+			}}},
+			attributes: 7,
+			fixed:      map[protowire.Number]uint64{2: start, 3: ts, 4: 2},
+			doubles:    map[protowire.Number]float64{5: 1.5},
+		},
+		{
+			name: "histogram",
+			mf: &dto.MetricFamily{Type: dto.MetricType_HISTOGRAM.Enum(), Metric: []*dto.Metric{{
+				Histogram: &dto.Histogram{SampleCount: proto.Uint64(4), SampleSum: proto.Float64(10)},
+			}}},
+			attributes: 9,
+			fixed:      map[protowire.Number]uint64{2: start, 3: ts, 4: 4},
+			doubles:    map[protowire.Number]float64{5: 10},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := decodeProto(t, otlpDataPoint(tt.mf, tt.mf.Metric[0], map[string]string{"agg": "max"}, now))
+			if keys := otlpKeys(t, protoFields(p, tt.attributes)); len(keys) != 1 || keys[0] != "agg" {
+				t.Errorf("attributes in field %d = %v, want [agg]", tt.attributes, keys)
+			}
+			for num, want := range tt.fixed {
+				if f := protoFields(p, num); len(f) != 1 || f[0].value != want {
+					t.Errorf("field %d = %v, want %d", num, f, want)
+				}
+			}
+			for num, want := range tt.doubles {
+				if f := protoFields(p, num); len(f) != 1 || math.Float64frombits(f[0].value) != want {
+					t.Errorf("field %d = %v, want %v", num, f, want)
+				}
+			}
+		})
+	}
+}
+
+func TestOTLPHistogramBuckets(t *testing.T) {
+	mf := &dto.MetricFamily{Type: dto.MetricType_HISTOGRAM.Enum(), Metric: []*dto.Metric{{
+		Histogram: &dto.Histogram{
+			SampleCount: proto.Uint64(6),
+			Bucket: []*dto.Bucket{
+				{UpperBound: proto.Float64(1), CumulativeCount: proto.Uint64(1)},
+				{UpperBound: proto.Float64(5), CumulativeCount: proto.Uint64(4)},
+				{UpperBound: proto.Float64(math.Inf(1)), CumulativeCount: proto.Uint64(6)},
+			},
+		},
+	}}}
+	p := decodeProto(t, otlpDataPoint(mf, mf.Metric[0], nil, time.Now()))
+	var counts []uint64
+	for b := protoFields(p, 6)[0].bytes; len(b) > 0; b = b[8:] {
+		counts = append(counts, binary.LittleEndian.Uint64(b))
+	}
+	var bounds []float64
+	for b := protoFields(p, 7)[0].bytes; len(b) > 0; b = b[8:] {
+		bounds = append(bounds, math.Float64frombits(binary.LittleEndian.Uint64(b)))
+	}
+	if want := []uint64{1, 3, 2}; len(counts) != 3 || counts[0] != want[0] || counts[1] != want[1] || counts[2] != want[2] {
+		t.Errorf("bucket counts = %v, want %v", counts, want)
+	}
+	if want := []float64{1, 5}; len(bounds) != 2 || bounds[0] != want[0] || bounds[1] != want[1] {
+		t.Errorf("explicit bounds = %v, want %v", bounds, want)
+	}
+}
+
+func TestOTLPMetric(t *testing.T) {
+	tests := []struct {
+		t           dto.MetricType
+		field       protowire.Number
+		temporality bool
+		monotonic   bool
+	}{
+		{t: dto.MetricType_GAUGE, field: 5},
+		{t: dto.MetricType_UNTYPED, field: 5},
+		{t: dto.MetricType_COUNTER, field: 7, temporality: true, monotonic: true},
+		{t: dto.MetricType_HISTOGRAM, field: 9, temporality: true},
+		{t: dto.MetricType_SUMMARY, field: 11},
+	}
+	for _, tt := range tests {
+		t.Run(tt.t.String(), func(t *testing.T) {
+			m := decodeProto(t, otlpMetric("tempest_station_uv", "UV index", tt.t, otlpMessage{}.bytes(1, nil)))
+			if name := string(protoFields(m, 1)[0].bytes); name != "tempest_station_uv" {
+				t.Errorf("name = %q, want %q", name, "tempest_station_uv")
+			}
+			data := protoFields(m, tt.field)
+			if len(data) != 1 {
+				t.Fatalf("metric has %d fields %d, want 1", len(data), tt.field)
+			}
+			d := decodeProto(t, data[0].bytes)
+			if n := len(protoFields(d, 1)); n != 1 {
+				t.Errorf("metric has %d data points, want 1", n)
+			}
+			if f := protoFields(d, 2); tt.temporality != (len(f) == 1 && f[0].value == otlpCumulative) {
+				t.Errorf("aggregation temporality = %v, want cumulative %v", f, tt.temporality)
+			}
+			if f := protoFields(d, 3); tt.monotonic != (len(f) == 1 && f[0].value == 1) {
+				t.Errorf("is_monotonic = %v, want %v", f, tt.monotonic)
+			}
+		})
+	}
+}
+
+func TestEncodeOTLP(t *testing.T) {
+	gauge := func(name, station string) *dto.MetricFamily {
+		var labels []*dto.LabelPair
+		if station != "" {
+			labels = append(labels, &dto.LabelPair{Name: proto.String("station_id"), Value: proto.String(station)})
+		}
+		return &dto.MetricFamily{
+			Name:   proto.String(name),
+			Type:   dto.MetricType_GAUGE.Enum(),
+			Metric: []*dto.Metric{{Label: labels, Gauge: &dto.Gauge{Value: proto.Float64(1)}}},
+		}
+	}
+	mfs := []*dto.MetricFamily{
+		gauge("tempest_station_uv", "1"),
+		gauge("tempest_station_air_temperature", "1"),
+		gauge("tempest_station_uv", "2"),
+		gauge("tempest_exporter_up", ""),
+	}
+	rms := protoFields(decodeProto(t, encodeOTLP(mfs, time.Now())), 1)
+	if len(rms) != 3 {
+		t.Fatalf("encodeOTLP() has %d resources, want 3", len(rms))
+	}
+	metrics := make(map[string]int)
+	for _, rm := range rms {
+		f := decodeProto(t, rm.bytes)
+		resource := decodeProto(t, protoFields(f, 1)[0].bytes)
+		key := ""
+		for _, k := range otlpKeys(t, protoFields(resource, 1)) {
+			key += k + ","
+		}
+		sm := decodeProto(t, protoFields(f, 2)[0].bytes)
+		metrics[key] += len(protoFields(sm, 2))
+	}
+	want := map[string]int{"service.name,station_id,": 3, "service.name,": 1}
+	for key, n := range want {
+		if metrics[key] != n {
+			t.Errorf("resources with attributes %q have %d metrics, want %d", key, metrics[key], n)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+// Package weatherflow is a client for the WeatherFlow Tempest REST API,
+// documented at https://weatherflow.github.io/Tempest/api/.
+package weatherflow
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// DefaultBaseURL is the base URL of the WeatherFlow REST API
+const DefaultBaseURL = "https://swd.weatherflow.com/swd/rest"
+
+// Client makes requests to the WeatherFlow REST API with a personal access
+// token
+type Client struct {
+	// BaseURL is the base URL of the API, without a trailing slash
+	BaseURL string
+	// Token is the personal access token requests are authorized with
+	Token string
+	// HTTPClient makes the client's requests
+	HTTPClient *http.Client
+}
+
+// NewClient returns a client for the WeatherFlow API authorized with token,
+// making requests with http.DefaultClient
+func NewClient(token string) *Client {
+	return &Client{
+		BaseURL:    DefaultBaseURL,
+		Token:      token,
+		HTTPClient: http.DefaultClient,
+	}
+}
+
+// Error is returned for requests the API failed, either with an HTTP error
+// status or with an error status in its response body
+type Error struct {
+	// Code is the API's status code, which is the HTTP status code unless
+	// the response body gave its own
+	Code int
+	// Message is the API's status message, if it gave one
+	Message string
+}
+
+// Error implements error
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("weatherflow api returned status %d", e.Code)
+	}
+	return fmt.Sprintf("weatherflow api returned status %d: %s", e.Code, e.Message)
+}
+
+// Get requests path from the API with query, decoding the JSON response
+// into v and returning the raw response body
+func (c *Client) Get(ctx context.Context, path string, query url.Values, v interface{}) ([]byte, error) {
+	q := url.Values{}
+	for k, vs := range query {
+		q[k] = vs
+	}
+	q.Set("token", c.Token)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.BaseURL+path+"?"+q.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("error requesting %s: %w", path, err)
+	}
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		// drop the request URL from the error, as it holds our token
+		var ue *url.Error
+		if errors.As(err, &ue) {
+			err = ue.Err
+		}
+		return nil, fmt.Errorf("error requesting %s: %w", path, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading response to %s: %w", path, err)
+	}
+	if resp.StatusCode >= 400 {
+		apiErr := &Error{Code: resp.StatusCode, Message: http.StatusText(resp.StatusCode)}
+		var r struct {
+			Status Status `json:"status"`
+		}
+		if json.Unmarshal(body, &r) == nil && r.Status.Code != 0 {
+			apiErr = &Error{Code: r.Status.Code, Message: r.Status.Message}
+		}
+		return nil, fmt.Errorf("error requesting %s: %w", path, apiErr)
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return nil, fmt.Errorf("error parsing response to %s: %w", path, err)
+	}
+	return body, nil
+}
+
+// Stations returns every station the token can access
+func (c *Client) Stations(ctx context.Context) ([]Station, error) {
+	var r stationsResponse
+	if _, err := c.Get(ctx, "/stations", nil, &r); err != nil {
+		return nil, err
+	}
+	if err := r.Status.err(); err != nil {
+		return nil, fmt.Errorf("error requesting /stations: %w", err)
+	}
+	return r.Stations, nil
+}
+
+// Station returns a station's metadata, including its devices
+func (c *Client) Station(ctx context.Context, stationID int) (Station, error) {
+	var r stationsResponse
+	path := "/stations/" + strconv.Itoa(stationID)
+	if _, err := c.Get(ctx, path, nil, &r); err != nil {
+		return Station{}, err
+	}
+	if err := r.Status.err(); err != nil {
+		return Station{}, fmt.Errorf("error requesting %s: %w", path, err)
+	}
+	if len(r.Stations) == 0 {
+		return Station{}, fmt.Errorf("station %d not found", stationID)
+	}
+	return r.Stations[0], nil
+}
+
+// StationObservations returns a station's latest derived observation
+func (c *Client) StationObservations(ctx context.Context, stationID int) (StationObservations, error) {
+	var r StationObservations
+	_, err := c.Get(ctx, "/observations/station/"+strconv.Itoa(stationID), nil, &r)
+	return r, err
+}
+
+// DeviceObservations returns a device's latest raw observation
+func (c *Client) DeviceObservations(ctx context.Context, deviceID int) (DeviceObservations, error) {
+	var r DeviceObservations
+	_, err := c.Get(ctx, "/observations/device/"+strconv.Itoa(deviceID), nil, &r)
+	return r, err
+}
+
+// Forecast returns a station's forecast in metric units: degrees Celsius,
+// meters per second, millibars, millimeters and kilometers
+func (c *Client) Forecast(ctx context.Context, stationID int) (Forecast, error) {
+	var r Forecast
+	q := url.Values{
+		"station_id":     {strconv.Itoa(stationID)},
+		"units_temp":     {"c"},
+		"units_wind":     {"mps"},
+		"units_pressure": {"mb"},
+		"units_precip":   {"mm"},
+		"units_distance": {"km"},
+	}
+	_, err := c.Get(ctx, "/better_forecast", q, &r)
+	return r, err
+}
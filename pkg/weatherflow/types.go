@@ -0,0 +1,203 @@
+package weatherflow
+
+// Status is the status of an API response
+type Status struct {
+	Code    int    `json:"status_code"`
+	Message string `json:"status_message"`
+}
+
+// err returns the error a status reports, if any
+func (s Status) err() error {
+	if s.Code == 0 {
+		return nil
+	}
+	return &Error{Code: s.Code, Message: s.Message}
+}
+
+// Observation is a station's derived observation. Temperatures are in °C,
+// pressures in mb, wind speeds in m/s, precipitation in mm and distances in km.
+type Observation struct {
+	// Density of the air in kg/m³
+	AirDensity float64 `json:"air_density"`
+
+	// Air temperature
+	AirTemperature float64 `json:"air_temperature"`
+
+	// Barometric pressure
+	BarometricPressure float64 `json:"barometric_pressure"`
+
+	// Illuminance in lux
+	Brightness float64 `json:"brightness"`
+
+	// Difference between the air and wet bulb temperatures
+	DeltaT float64 `json:"delta_t"`
+
+	// Dew point temperature
+	DewPoint float64 `json:"dew_point"`
+
+	// Apparent temperature, from the heat index or wind chill
+	FeelsLike float64 `json:"feels_like"`
+
+	// Heat index temperature
+	HeatIndex float64 `json:"heat_index"`
+
+	// Lightning strikes detected in the observation interval
+	LightningStrikeCount float64 `json:"lightning_strike_count"`
+
+	// Lightning strikes detected in the last hour
+	LightningStrikeCountLast1hr float64 `json:"lightning_strike_count_last_1hr"`
+
+	// Lightning strikes detected in the last 3 hours
+	LightningStrikeCountLast3hr float64 `json:"lightning_strike_count_last_3hr"`
+
+	// Distance to the most recent lightning strike
+	LightningStrikeLastDistance float64 `json:"lightning_strike_last_distance"`
+
+	// Unix timestamp of the most recent lightning strike
+	LightningStrikeLastEpoch float64 `json:"lightning_strike_last_epoch"`
+
+	// Precipitation in the observation interval
+	Precip float64 `json:"precip"`
+
+	// Precipitation accumulated in the last hour
+	PrecipAccumLast1hr float64 `json:"precip_accum_last_1hr"`
+
+	// Precipitation accumulated today in local time
+	PrecipAccumLocalDay float64 `json:"precip_accum_local_day"`
+
+	// Preliminary precipitation accumulated yesterday in local time, before any Rain Check adjustment
+	PrecipAccumLocalYesterday float64 `json:"precip_accum_local_yesterday"`
+
+	// Final precipitation accumulated yesterday in local time, only exported once the API reports it
+	PrecipAccumLocalYesterdayFinal *float64 `json:"precip_accum_local_yesterday_final"`
+
+	// Rain Check analysis applied to yesterday's precipitation: 0 none, 1 with
+	// display on, 2 with display off
+	PrecipAnalysisTypeYesterday float64 `json:"precip_analysis_type_yesterday"`
+
+	// Minutes of precipitation today in local time
+	PrecipMinutesLocalDay float64 `json:"precip_minutes_local_day"`
+
+	// Preliminary minutes of precipitation yesterday in local time, before any Rain Check adjustment
+	PrecipMinutesLocalYesterday float64 `json:"precip_minutes_local_yesterday"`
+
+	// Final minutes of precipitation yesterday in local time, only exported once the API reports it
+	PrecipMinutesLocalYesterdayFinal *float64 `json:"precip_minutes_local_yesterday_final"`
+
+	// Pressure trend: falling, steady or rising
+	PressureTrend string `json:"pressure_trend"`
+
+	// Relative humidity in percent
+	RelativeHumidity float64 `json:"relative_humidity"`
+
+	// Pressure adjusted to sea level
+	SeaLevelPressure float64 `json:"sea_level_pressure"`
+
+	// Solar radiation in W/m²
+	SolarRadiation float64 `json:"solar_radiation"`
+
+	// Pressure at the station
+	StationPressure float64 `json:"station_pressure"`
+
+	// Unix timestamp of the observation
+	Timestamp float64 `json:"timestamp"`
+
+	// UV index
+	Uv float64 `json:"uv"`
+
+	// Wet bulb temperature
+	WetBulbTemperature float64 `json:"wet_bulb_temperature"`
+
+	// Average wind speed over the observation interval
+	WindAvg float64 `json:"wind_avg"`
+
+	// Wind chill temperature
+	WindChill float64 `json:"wind_chill"`
+
+	// Wind direction in degrees
+	WindDirection float64 `json:"wind_direction"`
+
+	// Highest wind speed over the observation interval
+	WindGust float64 `json:"wind_gust"`
+
+	// Lowest wind speed over the observation interval
+	WindLull float64 `json:"wind_lull"`
+}
+
+// StationObservations is a station's details and latest derived observation
+type StationObservations struct {
+	StationID   int           `json:"station_id"`
+	StationName string        `json:"station_name"`
+	PublicName  string        `json:"public_name"`
+	Latitude    float64       `json:"latitude"`
+	Longitude   float64       `json:"longitude"`
+	Timezone    string        `json:"timezone"`
+	Elevation   float64       `json:"elevation"`
+	Status      Status        `json:"status"`
+	Obs         []Observation `json:"obs"`
+}
+
+// Station is a station's metadata
+type Station struct {
+	StationID int      `json:"station_id"`
+	Name      string   `json:"name"`
+	Devices   []Device `json:"devices"`
+}
+
+// Device is a device attached to a station
+type Device struct {
+	DeviceID     int    `json:"device_id"`
+	SerialNumber string `json:"serial_number"`
+	DeviceType   string `json:"device_type"`
+	DeviceMeta   struct {
+		Name        string `json:"name"`
+		Environment string `json:"environment"`
+	} `json:"device_meta"`
+}
+
+// stationsResponse is the response from the stations endpoints
+type stationsResponse struct {
+	Status   Status    `json:"status"`
+	Stations []Station `json:"stations"`
+}
+
+// DeviceObservations is a device's latest raw observation, whose fields
+// depend on the device's observation type
+type DeviceObservations struct {
+	Status   Status      `json:"status"`
+	DeviceID int         `json:"device_id"`
+	Type     string      `json:"type"`
+	Obs      [][]float64 `json:"obs"`
+}
+
+// Forecast is a station's forecast
+type Forecast struct {
+	Status   Status          `json:"status"`
+	Forecast ForecastPeriods `json:"forecast"`
+}
+
+// ForecastPeriods are the days and hours of a forecast
+type ForecastPeriods struct {
+	Daily  []DailyForecast  `json:"daily"`
+	Hourly []HourlyForecast `json:"hourly"`
+}
+
+// HourlyForecast is a single hour of a forecast
+type HourlyForecast struct {
+	Time              float64 `json:"time"`
+	Conditions        string  `json:"conditions"`
+	AirTemperature    float64 `json:"air_temperature"`
+	Precip            float64 `json:"precip"`
+	PrecipProbability float64 `json:"precip_probability"`
+	WindAvg           float64 `json:"wind_avg"`
+	WindGust          float64 `json:"wind_gust"`
+}
+
+// DailyForecast is a single day of a forecast
+type DailyForecast struct {
+	DayStartLocal     float64 `json:"day_start_local"`
+	Conditions        string  `json:"conditions"`
+	AirTempHigh       float64 `json:"air_temp_high"`
+	AirTempLow        float64 `json:"air_temp_low"`
+	PrecipProbability float64 `json:"precip_probability"`
+}
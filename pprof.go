@@ -0,0 +1,25 @@
+package main
+
+import (
+	"net/http"
+	_ "net/http/pprof"
+	"strings"
+)
+
+// pprofPath is where net/http/pprof registers its handlers on the default mux
+const pprofPath = "/debug/pprof/"
+
+// hidePprof stops h serving the profiling endpoints net/http/pprof registers
+// when it's imported, unless they're enabled
+func hidePprof(h http.Handler) http.Handler {
+	if cfg.EnablePprof {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, pprofPath) {
+			http.NotFound(w, r)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"net/http"
+	"sync/atomic"
+)
+
+var (
+	// polling is set while our poller, or broadcast listener, is running
+	polling atomic.Bool
+	// ready is set once we've fetched our first observation
+	ready atomic.Bool
+	// probePaths are served without authentication, so orchestrators can
+	// probe us without credentials
+	probePaths = map[string]bool{"/healthz": true, "/readyz": true}
+)
+
+// onDemand returns whether we only fetch observations when scraped, so have
+// no poller running in the background
+func onDemand() bool {
+	return cfg.Source != "udp" && cfg.Collection == "scrape"
+}
+
+// healthzHandler reports us live as long as our poller is running
+func healthzHandler(w http.ResponseWriter, req *http.Request) {
+	if !onDemand() && !polling.Load() {
+		http.Error(w, "poller not running", http.StatusServiceUnavailable)
+		return
+	}
+	w.Write([]byte("ok\n"))
+}
+
+// readyzHandler reports us ready once we've fetched our first observation
+func readyzHandler(w http.ResponseWriter, req *http.Request) {
+	if !onDemand() && !ready.Load() {
+		http.Error(w, "no observation fetched yet", http.StatusServiceUnavailable)
+		return
+	}
+	w.Write([]byte("ok\n"))
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// proxyPath is the path we serve cached station observations on
+const proxyPath = "/proxy/observations/station/"
+
+// cachedResponse is a raw API response and when we fetched it
+type cachedResponse struct {
+	body    []byte
+	fetched time.Time
+}
+
+var (
+	// apiCache holds the latest raw API response for each station
+	apiCache   = make(map[string]cachedResponse)
+	apiCacheMu sync.Mutex
+)
+
+// cacheResponse stores the raw API response for a station
+func cacheResponse(station string, body []byte) {
+	apiCacheMu.Lock()
+	defer apiCacheMu.Unlock()
+	apiCache[station] = cachedResponse{body: body, fetched: time.Now()}
+}
+
+// cachedObservations returns the cached API response for a station, fetching
+// a fresh one if the cache is older than our TTL
+func cachedObservations(station string) (cachedResponse, error) {
+	apiCacheMu.Lock()
+	c, ok := apiCache[station]
+	apiCacheMu.Unlock()
+	if ok && time.Since(c.fetched) < time.Duration(cfg.Proxy.TTL) {
+		return c, nil
+	}
+	if _, err := getTempestData(station); err != nil {
+		return c, err
+	}
+	apiCacheMu.Lock()
+	defer apiCacheMu.Unlock()
+	return apiCache[station], nil
+}
+
+// proxyHandler serves cached observations for our configured stations, so
+// other tools can share our API quota without needing the token
+func proxyHandler(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	station := strings.TrimPrefix(req.URL.Path, proxyPath)
+	if !contains(stations(), station) {
+		http.NotFound(w, req)
+		return
+	}
+	c, err := cachedObservations(station)
+	if err != nil {
+		slog.Error(err.Error(), "station_id", station)
+		http.Error(w, "error getting observations from weatherflow", http.StatusBadGateway)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Age", strconv.Itoa(int(time.Since(c.fetched).Seconds())))
+	w.Write(c.body)
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// unstampedGatherer clears any timestamps from the metrics gathered from g,
+// as the Pushgateway rejects them
+type unstampedGatherer struct {
+	g prometheus.Gatherer
+}
+
+// Gather implements prometheus.Gatherer
+func (u unstampedGatherer) Gather() ([]*dto.MetricFamily, error) {
+	mfs, err := u.g.Gather()
+	for _, mf := range mfs {
+		for _, m := range mf.Metric {
+			m.TimestampMs = nil
+		}
+	}
+	return mfs, err
+}
+
+// gatherPush gathers our weather metrics for our Pushgateway, if one is
+// configured, returning a function that replaces our group on it with them.
+// The push doesn't read our config, so it can be made without holding
+// configMu.
+func gatherPush() func() {
+	if cfg.Pushgateway.URL == "" {
+		return func() {}
+	}
+	c := cfg.Pushgateway
+	mfs, err := unstampedGatherer{exportGatherer(snapshotGatherer(weatherRegistry), cfg.Units, false)}.Gather()
+	return func() {
+		if err == nil {
+			err = pushGateway(c, mfs)
+		}
+		if err != nil {
+			err = fmt.Errorf("error pushing to pushgateway: %v", err)
+			slog.Error(err.Error())
+			sinkSends.WithLabelValues("pushgateway", "failure").Inc()
+			reportFailure("pushgateway", err)
+			return
+		}
+		sinkSends.WithLabelValues("pushgateway", "success").Inc()
+		sinkLastSuccess.WithLabelValues("pushgateway").SetToCurrentTime()
+		reportSuccess("pushgateway")
+	}
+}
+
+// pushGateway replaces our group on a Pushgateway with metric families
+func pushGateway(c pushgatewayConfig, mfs []*dto.MetricFamily) error {
+	g := prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) { return mfs, nil })
+	p := push.New(c.URL, c.Job).
+		Gatherer(g).
+		Client(&http.Client{Timeout: uploadTimeout})
+	for k, v := range c.Grouping {
+		p = p.Grouping(k, v)
+	}
+	if c.Username != "" {
+		p = p.BasicAuth(c.Username, c.Password)
+	}
+	return p.Push()
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// pwsWeatherURL is the PWSWeather observation upload endpoint
+const pwsWeatherURL = "https://pwsupdate.pwsweather.com/api/v1/submitwx"
+
+// pwsWeatherParams are the query parameters PWSWeather takes each reading as,
+// keyed by metric name, with the conversion from our units
+var pwsWeatherParams = map[string]uploadParam{
+	"air_temperature":        {"tempf", cToF},
+	"dew_point":              {"dewptf", cToF},
+	"relative_humidity":      {"humidity", nil},
+	"wind_avg":               {"windspeedmph", mph.convert},
+	"wind_gust":              {"windgustmph", mph.convert},
+	"wind_direction":         {"winddir", nil},
+	"sea_level_pressure":     {"baromin", inHg.convert},
+	"precip_accum_last_1hr":  {"rainin", inches.convert},
+	"precip_accum_local_day": {"dailyrainin", inches.convert},
+	"solar_radiation":        {"solarradiation", nil},
+	"uv":                     {"UV", nil},
+}
+
+// pwsWeatherSink uploads observations to PWSWeather
+type pwsWeatherSink struct {
+	c      pwsWeatherConfig
+	client *http.Client
+}
+
+// newPWSWeatherSink creates a PWSWeather sink
+func newPWSWeatherSink(c pwsWeatherConfig) *pwsWeatherSink {
+	return &pwsWeatherSink{c: c, client: &http.Client{Timeout: uploadTimeout}}
+}
+
+// name implements sink
+func (s *pwsWeatherSink) name() string {
+	return "pwsweather"
+}
+
+// send implements sink
+func (s *pwsWeatherSink) send(rec sinkRecord) error {
+	q := url.Values{
+		"ID":           {s.c.StationID},
+		"PASSWORD":     {s.c.APIKey},
+		"dateutc":      {rec.Time.UTC().Format("2006-01-02 15:04:05")},
+		"softwaretype": {"tempest-exporter"},
+		"action":       {"updateraw"},
+	}
+	for name, p := range pwsWeatherParams {
+		v, ok := rec.Values[name]
+		if !ok {
+			continue
+		}
+		if p.convert != nil {
+			v = p.convert(v)
+		}
+		q.Set(p.name, strconv.FormatFloat(v, 'f', 2, 64))
+	}
+	if err := getUpload(s.client, pwsWeatherURL+"?"+q.Encode()); err != nil {
+		return fmt.Errorf("error uploading to pwsweather: %v", err)
+	}
+	return nil
+}
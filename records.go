@@ -0,0 +1,225 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// recordType describes a record we track and the reading it comes from
+type recordType struct {
+	name   string
+	metric string
+	max    bool
+}
+
+// recordTypes are the records we track for each station
+var recordTypes = []recordType{
+	{name: "air_temperature_max", metric: "air_temperature", max: true},
+	{name: "air_temperature_min", metric: "air_temperature", max: false},
+	{name: "wind_gust_max", metric: "wind_gust", max: true},
+	{name: "precip_day_max", metric: "precip_accum_local_day", max: true},
+}
+
+var (
+	// records holds the records for each station
+	records = make(map[string]*stationRecords)
+	// recordValue, recordTimestamp and recordSeason export our records
+	recordValue     *prometheus.GaugeVec
+	recordTimestamp *prometheus.GaugeVec
+	recordSeason    *prometheus.GaugeVec
+)
+
+// record is the extreme value of a reading and when it was observed
+type record struct {
+	Value     float64 `json:"value"`
+	Timestamp float64 `json:"timestamp"`
+}
+
+// stationRecords holds the all-time and current season records for a station
+type stationRecords struct {
+	Season   string            `json:"season"`
+	AllTime  map[string]record `json:"all_time"`
+	Seasonal map[string]record `json:"seasonal"`
+}
+
+// season returns the meteorological season (and the year it started in) that
+// t falls in, flipping seasons for the southern hemisphere
+func season(t time.Time, latitude float64) (string, int) {
+	names := []string{"winter", "spring", "summer", "autumn"}
+	if latitude < 0 {
+		names = []string{"summer", "autumn", "winter", "spring"}
+	}
+	year := t.Year()
+	m := int(t.Month())
+	if m < 3 {
+		// January and February belong to the season that started in December
+		year--
+	}
+	return names[(m%12)/3], year
+}
+
+// update checks an observation against the records, returning whether any
+// record was broken
+func (r *stationRecords) update(o observation, seasonKey string) bool {
+	if r.AllTime == nil {
+		r.AllTime = make(map[string]record)
+	}
+	if r.Season != seasonKey || r.Seasonal == nil {
+		r.Season = seasonKey
+		r.Seasonal = make(map[string]record)
+	}
+	changed := false
+	values := o.values()
+	for _, rt := range recordTypes {
+		v, ok := values[rt.metric]
+		if !ok {
+			continue
+		}
+		for _, set := range []map[string]record{r.AllTime, r.Seasonal} {
+			cur, ok := set[rt.name]
+			if !ok || (rt.max && v > cur.Value) || (!rt.max && v < cur.Value) {
+				set[rt.name] = record{Value: v, Timestamp: o.Timestamp}
+				changed = true
+			}
+		}
+	}
+	return changed
+}
+
+// registerRecords creates and registers the gauges for our records
+func registerRecords(labelNames []string) {
+	names := append(append([]string{}, labelNames...), "record", "period")
+	recordValue = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: ns,
+			Subsystem: ss,
+			Name:      "record_value",
+			Help:      "Record value of a reading over the period",
+		},
+		names,
+	)
+	recordTimestamp = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: ns,
+			Subsystem: ss,
+			Name:      "record_timestamp_seconds",
+			Help:      "Unix timestamp of the observation that set the record",
+		},
+		names,
+	)
+	recordSeason = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: ns,
+			Subsystem: ss,
+			Name:      "record_season_info",
+			Help:      "The season that seasonal records are currently tracked for",
+		},
+		append(append([]string{}, labelNames...), "season", "year"),
+	)
+	weatherRegistry.MustRegister(recordValue, recordTimestamp, recordSeason)
+}
+
+// loadRecords reads persisted records from our records file, if configured
+func loadRecords() error {
+	if cfg.RecordsFile == "" {
+		return nil
+	}
+	b, err := ioutil.ReadFile(cfg.RecordsFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error reading records file: %v", err)
+	}
+	if err := json.Unmarshal(b, &records); err != nil {
+		return fmt.Errorf("error parsing records file: %v", err)
+	}
+	return nil
+}
+
+// saveRecords atomically writes our records to our records file, if configured
+func saveRecords() error {
+	if cfg.RecordsFile == "" {
+		return nil
+	}
+	return writeJSONFile(cfg.RecordsFile, records)
+}
+
+// writeJSONFile atomically replaces the file at path with v encoded as JSON
+func writeJSONFile(path string, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return writeFile(path, b)
+}
+
+// writeFile atomically replaces the file at path with b
+func writeFile(path string, b []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return fmt.Errorf("error writing %s: %v", path, err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error writing %s: %v", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error writing %s: %v", path, err)
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// setRecords checks a station's observation against its records, persisting
+// and exporting them
+func setRecords(r response, o observation, labels prometheus.Labels) error {
+	id := strconv.Itoa(r.StationID)
+	sr, ok := records[id]
+	if !ok {
+		sr = &stationRecords{}
+		records[id] = sr
+	}
+	t := time.Unix(int64(o.Timestamp), 0).In(location(r.Timezone))
+	name, year := season(t, r.Latitude)
+	seasonKey := fmt.Sprintf("%d-%s", year, name)
+	if sr.Season != "" && sr.Season != seasonKey {
+		// Stop exporting the season that just ended
+		var oldYear, oldName string
+		fmt.Sscanf(strings.Replace(sr.Season, "-", " ", 1), "%s %s", &oldYear, &oldName)
+		l := prometheus.Labels{"season": oldName, "year": oldYear}
+		for k, v := range labels {
+			l[k] = v
+		}
+		recordSeason.Delete(l)
+	}
+	var err error
+	if sr.update(o, seasonKey) {
+		err = saveRecords()
+	}
+
+	l := prometheus.Labels{"season": name, "year": strconv.Itoa(year)}
+	for k, v := range labels {
+		l[k] = v
+	}
+	recordSeason.With(l).Set(1)
+	for period, set := range map[string]map[string]record{"all_time": sr.AllTime, "season": sr.Seasonal} {
+		for rn, rec := range set {
+			l := prometheus.Labels{"record": rn, "period": period}
+			for k, v := range labels {
+				l[k] = v
+			}
+			recordValue.With(l).Set(rec.Value)
+			recordTimestamp.With(l).Set(rec.Timestamp)
+		}
+	}
+	return err
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/model"
+)
+
+// groupRef matches a reference to a regex group in a replacement, like $1 or
+// ${name}
+var groupRef = regexp.MustCompile(`\$(\{[^}]*\}|\w+)`)
+
+// relabelRule is a rule applied to the series we export
+type relabelRule struct {
+	Action      string `json:"action" enum:"rename,drop,labeldrop,replace" description:"Whether to rename metrics, drop metrics or series, drop a label, or replace label values"`
+	Metric      string `json:"metric" description:"Regex matching the metric names the rule applies to, defaults to all metrics"`
+	Label       string `json:"label" description:"Label to drop or replace values of"`
+	Regex       string `json:"regex" description:"Regex matching label values to replace, or to drop series by with the drop action"`
+	Replacement string `json:"replacement" description:"New metric name or label value, may reference regex groups like $1"`
+
+	metric *regexp.Regexp
+	regex  *regexp.Regexp
+}
+
+// relabelRules is a list of relabel rules, configured in the environment as JSON
+type relabelRules []relabelRule
+
+// UnmarshalText parses relabel rules from JSON
+func (r *relabelRules) UnmarshalText(b []byte) error {
+	return json.Unmarshal(b, (*[]relabelRule)(r))
+}
+
+// UnmarshalJSON parses relabel rules from a JSON string or list
+func (r *relabelRules) UnmarshalJSON(b []byte) error {
+	return unmarshalJSONOrText(b, r, (*[]relabelRule)(r))
+}
+
+// compile compiles the rules' regexes, anchoring them like Prometheus does
+func (r relabelRules) compile() error {
+	for i := range r {
+		rule := &r[i]
+		metric := rule.Metric
+		if metric == "" {
+			metric = ".*"
+		}
+		regex := rule.Regex
+		if regex == "" {
+			regex = ".*"
+		}
+		var err error
+		if rule.metric, err = regexp.Compile("^(?:" + metric + ")$"); err != nil {
+			return fmt.Errorf("invalid relabel metric regex %q: %v", rule.Metric, err)
+		}
+		if rule.regex, err = regexp.Compile("^(?:" + regex + ")$"); err != nil {
+			return fmt.Errorf("invalid relabel regex %q: %v", rule.Regex, err)
+		}
+		if !contains([]string{"rename", "drop", "labeldrop", "replace"}, rule.Action) {
+			return fmt.Errorf("invalid relabel action %q", rule.Action)
+		}
+		if rule.Action != "rename" && rule.Action != "drop" && rule.Label == "" {
+			return fmt.Errorf("relabel action %s needs a label", rule.Action)
+		}
+		if rule.Action == "rename" && rule.Replacement == "" {
+			return fmt.Errorf("relabel action rename needs a replacement")
+		}
+		if rule.Action == "rename" {
+			// what group references expand to is only known in apply, which
+			// checks the expanded name, so stand in a valid name for them here
+			n := groupRef.ReplaceAllString(rule.Replacement, "x")
+			if !model.IsValidMetricName(model.LabelValue(n)) {
+				return fmt.Errorf("invalid relabel replacement %q, expected a metric name", rule.Replacement)
+			}
+		}
+	}
+	return nil
+}
+
+// relabelGatherer applies our relabel rules to the metrics gathered from g
+type relabelGatherer struct {
+	g prometheus.Gatherer
+}
+
+// Gather implements prometheus.Gatherer
+func (r relabelGatherer) Gather() ([]*dto.MetricFamily, error) {
+	mfs, err := r.g.Gather()
+	if len(cfg.Relabel) == 0 {
+		return mfs, err
+	}
+	var out []*dto.MetricFamily
+	byName := make(map[string]*dto.MetricFamily)
+	for _, mf := range mfs {
+		for _, rule := range cfg.Relabel {
+			if mf == nil {
+				break
+			}
+			mf = rule.apply(mf)
+		}
+		if mf == nil || len(mf.Metric) == 0 {
+			continue
+		}
+		// renaming can leave several families with the same name, which we
+		// merge unless their types differ from the first's
+		if existing, ok := byName[mf.GetName()]; ok {
+			if existing.GetType() == mf.GetType() {
+				existing.Metric = append(existing.Metric, mf.Metric...)
+			}
+			continue
+		}
+		byName[mf.GetName()] = mf
+		out = append(out, mf)
+	}
+	for _, mf := range out {
+		mf.Metric = dedupeSeries(mf.Metric)
+	}
+	return out, err
+}
+
+// dedupeSeries drops the series left with the same labels as an earlier one
+// by renaming or dropping labels, which scrapers would reject
+func dedupeSeries(ms []*dto.Metric) []*dto.Metric {
+	seen := make(map[string]bool, len(ms))
+	keep := ms[:0]
+	for _, m := range ms {
+		pairs := make([]string, 0, len(m.Label))
+		for _, l := range m.Label {
+			pairs = append(pairs, l.GetName()+"\xff"+l.GetValue())
+		}
+		sort.Strings(pairs)
+		key := strings.Join(pairs, "\xfe")
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		keep = append(keep, m)
+	}
+	return keep
+}
+
+// apply applies a rule to a metric family, returning nil if it is dropped
+func (rule relabelRule) apply(mf *dto.MetricFamily) *dto.MetricFamily {
+	name := mf.GetName()
+	m := rule.metric.FindStringSubmatchIndex(name)
+	if m == nil {
+		return mf
+	}
+	switch rule.Action {
+	case "rename":
+		n := string(rule.metric.ExpandString(nil, rule.Replacement, name, m))
+		if !model.IsValidMetricName(model.LabelValue(n)) {
+			// keep the name rather than export an invalid one
+			return mf
+		}
+		mf.Name = &n
+	case "drop":
+		if rule.Label == "" {
+			return nil
+		}
+		var keep []*dto.Metric
+		for _, metric := range mf.Metric {
+			if v, ok := labelValue(metric, rule.Label); !ok || !rule.regex.MatchString(v) {
+				keep = append(keep, metric)
+			}
+		}
+		mf.Metric = keep
+	case "labeldrop":
+		for _, metric := range mf.Metric {
+			var keep []*dto.LabelPair
+			for _, l := range metric.Label {
+				if l.GetName() != rule.Label {
+					keep = append(keep, l)
+				}
+			}
+			metric.Label = keep
+		}
+	case "replace":
+		for _, metric := range mf.Metric {
+			for _, l := range metric.Label {
+				if l.GetName() != rule.Label {
+					continue
+				}
+				if vm := rule.regex.FindStringSubmatchIndex(l.GetValue()); vm != nil {
+					v := string(rule.regex.ExpandString(nil, rule.Replacement, l.GetValue(), vm))
+					l.Value = &v
+				}
+			}
+		}
+	}
+	return mf
+}
+
+// labelValue returns the value of a label on a gathered metric
+func labelValue(m *dto.Metric, name string) (string, bool) {
+	for _, l := range m.Label {
+		if l.GetName() == name {
+			return l.GetValue(), true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestRelabelCompile(t *testing.T) {
+	tests := []struct {
+		name string
+		rule relabelRule
+		err  bool
+	}{
+		{name: "rename", rule: relabelRule{Action: "rename", Metric: "tempest_(.*)", Replacement: "weather_$1"}},
+		{name: "rename with a named group", rule: relabelRule{Action: "rename", Metric: "tempest_(?P<rest>.*)", Replacement: "weather_${rest}"}},
+		{name: "drop", rule: relabelRule{Action: "drop", Metric: "tempest_uv"}},
+		{name: "labeldrop", rule: relabelRule{Action: "labeldrop", Label: "latitude"}},
+		{name: "replace", rule: relabelRule{Action: "replace", Label: "station_name", Regex: "Home", Replacement: "garden"}},
+		{name: "invalid metric regex", rule: relabelRule{Action: "drop", Metric: "("}, err: true},
+		{name: "invalid regex", rule: relabelRule{Action: "replace", Label: "station_name", Regex: "("}, err: true},
+		{name: "unknown action", rule: relabelRule{Action: "keep"}, err: true},
+		{name: "labeldrop without a label", rule: relabelRule{Action: "labeldrop"}, err: true},
+		{name: "rename without a replacement", rule: relabelRule{Action: "rename"}, err: true},
+		{name: "rename to an invalid name", rule: relabelRule{Action: "rename", Replacement: "weather-uv"}, err: true},
+		{name: "rename to a name starting with a digit", rule: relabelRule{Action: "rename", Metric: "tempest_(.*)", Replacement: "1_$1"}, err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := relabelRules{tt.rule}.compile()
+			if (err != nil) != tt.err {
+				t.Errorf("compile() error = %v, want error %v", err, tt.err)
+			}
+		})
+	}
+}
+
+// gatherRelabelled registers gauges for the given series, keyed by metric
+// name and then by station_name and station_id label values, and gathers them
+// through rules
+func gatherRelabelled(t *testing.T, rules relabelRules, series map[string][][2]string) map[string][]*dto.Metric {
+	t.Helper()
+	if err := rules.compile(); err != nil {
+		t.Fatal(err)
+	}
+	defer func(r relabelRules) { cfg.Relabel = r }(cfg.Relabel)
+	cfg.Relabel = rules
+	reg := prometheus.NewRegistry()
+	for name, labels := range series {
+		g := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: name, Help: name}, []string{"station_name", "station_id"})
+		reg.MustRegister(g)
+		for _, l := range labels {
+			g.WithLabelValues(l[0], l[1]).Set(1)
+		}
+	}
+	mfs, err := relabelGatherer{reg}.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string][]*dto.Metric)
+	for _, mf := range mfs {
+		got[mf.GetName()] = mf.Metric
+	}
+	return got
+}
+
+func TestRelabelGather(t *testing.T) {
+	tests := []struct {
+		name   string
+		rules  relabelRules
+		series map[string][][2]string
+		want   map[string]int
+	}{
+		{
+			name:   "rename",
+			rules:  relabelRules{{Action: "rename", Metric: "tempest_(.*)", Replacement: "weather_$1"}},
+			series: map[string][][2]string{"tempest_uv": {{"Home", "1"}}},
+			want:   map[string]int{"weather_uv": 1},
+		},
+		{
+			name:   "rename expanding to an invalid name",
+			rules:  relabelRules{{Action: "rename", Metric: "tempest_(.*)", Replacement: "$1"}},
+			series: map[string][][2]string{"tempest_1m": {{"Home", "1"}}},
+			want:   map[string]int{"tempest_1m": 1},
+		},
+		{
+			name:   "renamed families are merged",
+			rules:  relabelRules{{Action: "rename", Metric: "tempest_(uv|solar)", Replacement: "tempest_light"}},
+			series: map[string][][2]string{"tempest_uv": {{"Home", "1"}}, "tempest_solar": {{"Home", "2"}}},
+			want:   map[string]int{"tempest_light": 2},
+		},
+		{
+			name:   "renamed families colliding on labels",
+			rules:  relabelRules{{Action: "rename", Metric: "tempest_(uv|solar)", Replacement: "tempest_light"}},
+			series: map[string][][2]string{"tempest_uv": {{"Home", "1"}}, "tempest_solar": {{"Home", "1"}}},
+			want:   map[string]int{"tempest_light": 1},
+		},
+		{
+			name:   "labeldrop collisions",
+			rules:  relabelRules{{Action: "labeldrop", Label: "station_id"}},
+			series: map[string][][2]string{"tempest_uv": {{"Home", "1"}, {"Home", "2"}, {"Cabin", "3"}}},
+			want:   map[string]int{"tempest_uv": 2},
+		},
+		{
+			name:   "replace collisions",
+			rules:  relabelRules{{Action: "replace", Label: "station_id", Regex: ".*", Replacement: "all"}},
+			series: map[string][][2]string{"tempest_uv": {{"Home", "1"}, {"Home", "2"}}},
+			want:   map[string]int{"tempest_uv": 1},
+		},
+		{
+			name:   "drop series",
+			rules:  relabelRules{{Action: "drop", Label: "station_name", Regex: "Cabin"}},
+			series: map[string][][2]string{"tempest_uv": {{"Home", "1"}, {"Cabin", "2"}}},
+			want:   map[string]int{"tempest_uv": 1},
+		},
+		{
+			name:   "drop metric",
+			rules:  relabelRules{{Action: "drop", Metric: "tempest_uv"}},
+			series: map[string][][2]string{"tempest_uv": {{"Home", "1"}}, "tempest_solar": {{"Home", "1"}}},
+			want:   map[string]int{"tempest_solar": 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := gatherRelabelled(t, tt.rules, tt.series)
+			if len(got) != len(tt.want) {
+				t.Fatalf("Gather() families = %v, want %v", got, tt.want)
+			}
+			for name, n := range tt.want {
+				if len(got[name]) != n {
+					t.Errorf("Gather() %s has %d series, want %d", name, len(got[name]), n)
+				}
+			}
+		})
+	}
+}
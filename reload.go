@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"reflect"
+	"sync"
+	"syscall"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	// configMu guards cfg against reloads while we update our metrics from a
+	// poll or broadcast, or serve a request. It isn't held while the poller
+	// waits on the API or our air quality sensor, or pushes to a sink.
+	configMu sync.RWMutex
+	// restartSettings are the settings that can't be changed by reloading, as
+	// they decide which listeners, handlers and metrics we set up
+	restartSettings = map[string]func(c config) interface{}{
+		"listen_address":      func(c config) interface{} { return c.ListenAddresses },
+		"telemetry_path":      func(c config) interface{} { return c.TelemetryPath },
+		"web_config_file":     func(c config) interface{} { return c.WebConfigFile },
+		"basic_auth":          func(c config) interface{} { return c.BasicAuth },
+		"disable_compression": func(c config) interface{} { return c.DisableCompression },
+		"enable_pprof":        func(c config) interface{} { return c.EnablePprof },
+		"enable_lifecycle":    func(c config) interface{} { return c.EnableLifecycle },
+		"log.access":          func(c config) interface{} { return c.Log.Access },
+		"log.file":            func(c config) interface{} { return c.Log.File },
+		"log.syslog":          func(c config) interface{} { return c.Log.Syslog },
+		"source":              func(c config) interface{} { return c.Source },
+		"collection":          func(c config) interface{} { return c.Collection },
+		"udp":                 func(c config) interface{} { return c.UDP },
+		"station_labels":      func(c config) interface{} { return c.StationLabels },
+		"labels":              func(c config) interface{} { return c.Labels },
+		"sample_timestamps":   func(c config) interface{} { return c.SampleTimestamps },
+		"histograms":          func(c config) interface{} { return c.Histograms },
+		"forecast.enabled":    func(c config) interface{} { return c.Forecast.Enabled },
+		"remote_write.url":    func(c config) interface{} { return c.RemoteWrite.URL != "" },
+		"otlp.endpoint":       func(c config) interface{} { return c.OTLP.Endpoint != "" },
+		"air_quality.source":  func(c config) interface{} { return c.AirQuality.Source },
+		"proxy.enabled":       func(c config) interface{} { return c.Proxy.Enabled },
+		"records_file":        func(c config) interface{} { return c.RecordsFile },
+		"degree_days.file":    func(c config) interface{} { return c.DegreeDays.File },
+		"history.file":        func(c config) interface{} { return c.History.File },
+		"collectors":          func(c config) interface{} { return c.Collectors },
+	}
+	// lastReloadSuccessful is 1 if our last config reload succeeded
+	lastReloadSuccessful = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: ns,
+		Subsystem: "exporter",
+		Name:      "config_last_reload_successful",
+		Help:      "Whether the last configuration reload attempt was successful",
+	})
+	// lastReloadSuccess is when our config was last loaded
+	lastReloadSuccess = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: ns,
+		Subsystem: "exporter",
+		Name:      "config_last_reload_success_timestamp_seconds",
+		Help:      "Unix timestamp of the last successful configuration load",
+	})
+)
+
+// reloadConfig reloads our config, applying it once nothing is using the old
+// one. If the new config is invalid or changes a setting that needs a restart
+// we carry on with the old one.
+func reloadConfig() error {
+	c, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	for name, setting := range restartSettings {
+		if !reflect.DeepEqual(setting(c), setting(cfg)) {
+			return fmt.Errorf("%s can't be changed without restarting the exporter", name)
+		}
+	}
+	b, err := mergeBounds(c.Bounds)
+	if err != nil {
+		return err
+	}
+	configMu.Lock()
+	defer configMu.Unlock()
+	old := cfg
+	cfg = c
+	if err := setupAPIClient(); err != nil {
+		cfg = old
+		return err
+	}
+	if err := setupSinks(); err != nil {
+		cfg = old
+		setupAPIClient()
+		return err
+	}
+	bounds = b
+	setErrorReporting()
+	configHash = hashConfig()
+	heartbeat.Reset()
+	setLogger()
+	applyMemoryLimit()
+	return nil
+}
+
+// reload reloads our config, recording whether it worked
+func reload() error {
+	err := reloadConfig()
+	lastReloadSuccessful.Set(boolToFloat(err == nil))
+	if err != nil {
+		return fmt.Errorf("error reloading config: %v", err)
+	}
+	lastReloadSuccess.SetToCurrentTime()
+	slog.Info("reloaded config", "config_hash", configHash)
+	return nil
+}
+
+// watchReloads reloads our config whenever we get a SIGHUP
+func watchReloads() {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	for range hup {
+		if err := reload(); err != nil {
+			slog.Error(err.Error())
+		}
+	}
+}
+
+// holdConfig keeps our config from being reloaded while h serves a request,
+// other than to our admin and streaming endpoints
+func holdConfig(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if lifecyclePaths[r.URL.Path] || streamPaths[r.URL.Path] {
+			h.ServeHTTP(w, r)
+			return
+		}
+		configMu.RLock()
+		defer configMu.RUnlock()
+		h.ServeHTTP(w, r)
+	})
+}
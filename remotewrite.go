@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// remoteWriteSample is a sample of a series to push
+type remoteWriteSample struct {
+	labels    map[string]string
+	value     float64
+	timestamp int64
+}
+
+// remoteWriteSamples flattens metric families into samples, expanding
+// summaries and histograms into their series as Prometheus would scrape them.
+// Samples without a timestamp are stamped with now.
+func remoteWriteSamples(mfs []*dto.MetricFamily, now time.Time) []remoteWriteSample {
+	var samples []remoteWriteSample
+	for _, mf := range mfs {
+		for _, m := range mf.Metric {
+			ts := now.UnixMilli()
+			if m.TimestampMs != nil {
+				ts = m.GetTimestampMs()
+			}
+			add := func(suffix string, v float64, extra ...string) {
+				l := map[string]string{"__name__": mf.GetName() + suffix}
+				for _, lp := range m.Label {
+					l[lp.GetName()] = lp.GetValue()
+				}
+				for i := 0; i+1 < len(extra); i += 2 {
+					l[extra[i]] = extra[i+1]
+				}
+				samples = append(samples, remoteWriteSample{labels: l, value: v, timestamp: ts})
+			}
+			switch mf.GetType() {
+			case dto.MetricType_COUNTER:
+				add("", m.GetCounter().GetValue())
+			case dto.MetricType_GAUGE:
+				add("", m.GetGauge().GetValue())
+			case dto.MetricType_UNTYPED:
+				add("", m.GetUntyped().GetValue())
+			case dto.MetricType_SUMMARY:
+				s := m.GetSummary()
+				for _, q := range s.Quantile {
+					add("", q.GetValue(), "quantile", strconv.FormatFloat(q.GetQuantile(), 'g', -1, 64))
+				}
+				add("_sum", s.GetSampleSum())
+				add("_count", float64(s.GetSampleCount()))
+			case dto.MetricType_HISTOGRAM:
+				h := m.GetHistogram()
+				for _, b := range h.Bucket {
+					add("_bucket", float64(b.GetCumulativeCount()), "le", strconv.FormatFloat(b.GetUpperBound(), 'g', -1, 64))
+				}
+				add("_bucket", float64(h.GetSampleCount()), "le", "+Inf")
+				add("_sum", h.GetSampleSum())
+				add("_count", float64(h.GetSampleCount()))
+			}
+		}
+	}
+	return samples
+}
+
+// encodeWriteRequest encodes samples as a remote write WriteRequest protobuf
+func encodeWriteRequest(samples []remoteWriteSample) []byte {
+	var req []byte
+	for _, s := range samples {
+		names := make([]string, 0, len(s.labels))
+		for name := range s.labels {
+			names = append(names, name)
+		}
+		// receivers require labels sorted by name
+		sort.Strings(names)
+		var ts []byte
+		for _, name := range names {
+			var l []byte
+			l = protowire.AppendTag(l, 1, protowire.BytesType)
+			l = protowire.AppendString(l, name)
+			l = protowire.AppendTag(l, 2, protowire.BytesType)
+			l = protowire.AppendString(l, s.labels[name])
+			ts = protowire.AppendTag(ts, 1, protowire.BytesType)
+			ts = protowire.AppendBytes(ts, l)
+		}
+		var sample []byte
+		sample = protowire.AppendTag(sample, 1, protowire.Fixed64Type)
+		sample = protowire.AppendFixed64(sample, math.Float64bits(s.value))
+		sample = protowire.AppendTag(sample, 2, protowire.VarintType)
+		sample = protowire.AppendVarint(sample, uint64(s.timestamp))
+		ts = protowire.AppendTag(ts, 2, protowire.BytesType)
+		ts = protowire.AppendBytes(ts, sample)
+		req = protowire.AppendTag(req, 1, protowire.BytesType)
+		req = protowire.AppendBytes(req, ts)
+	}
+	return req
+}
+
+// remoteWrite pushes metric families to a remote write endpoint
+func remoteWrite(client *http.Client, c remoteWriteConfig, mfs []*dto.MetricFamily) error {
+	samples := remoteWriteSamples(mfs, time.Now())
+	if len(samples) == 0 {
+		return nil
+	}
+	body := snappy.Encode(nil, encodeWriteRequest(samples))
+	req, err := http.NewRequest(http.MethodPost, c.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error creating remote write request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	req.Header.Set("User-Agent", "tempest-exporter")
+	for k, v := range c.Headers {
+		req.Header.Set(k, v)
+	}
+	if c.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+c.BearerToken)
+	} else if c.Username != "" {
+		req.SetBasicAuth(c.Username, c.Password)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error sending remote write: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("error sending remote write: %s", resp.Status)
+	}
+	return nil
+}
+
+// pushRemoteWrite pushes our weather metrics to our remote write endpoint
+// every interval, with observation readings at their observation's time
+func pushRemoteWrite(weather prometheus.Gatherer) {
+	defer reportPanic("remote_write")
+	for {
+		configMu.RLock()
+		c := cfg.RemoteWrite
+		mfs, err := exportGatherer(weather, cfg.Units, true).Gather()
+		configMu.RUnlock()
+		if err != nil {
+			err = fmt.Errorf("error gathering metrics to remote write: %v", err)
+		} else {
+			err = remoteWrite(&http.Client{Timeout: time.Duration(c.Timeout)}, c, mfs)
+		}
+		if err != nil {
+			slog.Error(err.Error())
+			sinkSends.WithLabelValues("remote_write", "failure").Inc()
+			reportFailure("remote_write", err)
+		} else {
+			sinkSends.WithLabelValues("remote_write", "success").Inc()
+			sinkLastSuccess.WithLabelValues("remote_write").SetToCurrentTime()
+			reportSuccess("remote_write")
+		}
+		time.Sleep(time.Duration(c.Interval))
+	}
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+)
+
+// protoField is a field decoded from a protobuf message
+type protoField struct {
+	num   protowire.Number
+	value uint64
+	bytes []byte
+}
+
+// decodeProto decodes the top level fields of a protobuf message
+func decodeProto(t *testing.T, b []byte) []protoField {
+	t.Helper()
+	var fields []protoField
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			t.Fatalf("invalid protobuf tag: %v", protowire.ParseError(n))
+		}
+		b = b[n:]
+		f := protoField{num: num}
+		switch typ {
+		case protowire.VarintType:
+			f.value, n = protowire.ConsumeVarint(b)
+		case protowire.Fixed64Type:
+			f.value, n = protowire.ConsumeFixed64(b)
+		case protowire.BytesType:
+			f.bytes, n = protowire.ConsumeBytes(b)
+		default:
+			t.Fatalf("unexpected protobuf wire type %d", typ)
+		}
+		if n < 0 {
+			t.Fatalf("invalid protobuf field %d: %v", num, protowire.ParseError(n))
+		}
+		b = b[n:]
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+// protoFields returns the fields of a decoded message with a field number
+func protoFields(fields []protoField, num protowire.Number) []protoField {
+	var out []protoField
+	for _, f := range fields {
+		if f.num == num {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+func TestRemoteWriteSamples(t *testing.T) {
+	now := time.UnixMilli(1700000000000)
+	label := []*dto.LabelPair{{Name: proto.String("station_id"), Value: proto.String("1")}}
+	mfs := []*dto.MetricFamily{
+		{
+			Name:   proto.String("tempest_station_uv"),
+			Type:   dto.MetricType_GAUGE.Enum(),
+			Metric: []*dto.Metric{{Label: label, Gauge: &dto.Gauge{Value: proto.Float64(3)}, TimestampMs: proto.Int64(1699999940000)}},
+		},
+		{
+			Name:   proto.String("tempest_lightning_strikes_total"),
+			Type:   dto.MetricType_COUNTER.Enum(),
+			Metric: []*dto.Metric{{Label: label, Counter: &dto.Counter{Value: proto.Float64(5)}}},
+		},
+		{
+			Name: proto.String("tempest_station_wind_avg_distribution"),
+			Type: dto.MetricType_HISTOGRAM.Enum(),
+			Metric: []*dto.Metric{{Label: label, Histogram: &dto.Histogram{
+				SampleCount: proto.Uint64(4),
+				SampleSum:   proto.Float64(10),
+				Bucket:      []*dto.Bucket{{UpperBound: proto.Float64(1), CumulativeCount: proto.Uint64(1)}, {UpperBound: proto.Float64(5), CumulativeCount: proto.Uint64(3)}},
+			}}},
+		},
+		{
+			Name: proto.String("tempest_exporter_scrape_seconds"),
+			Type: dto.MetricType_SUMMARY.Enum(),
+			Metric: []*dto.Metric{{Label: label, Summary: &dto.Summary{
+				SampleCount: proto.Uint64(2),
+				SampleSum:   proto.Float64(1.5),
+				Quantile:    []*dto.Quantile{{Quantile: proto.Float64(0.5), Value: proto.Float64(0.7)}},
+			}}},
+		},
+	}
+	type sample struct {
+		name, le, quantile string
+		value              float64
+		timestamp          int64
+	}
+	want := []sample{
+		{name: "tempest_station_uv", value: 3, timestamp: 1699999940000},
+		{name: "tempest_lightning_strikes_total", value: 5, timestamp: now.UnixMilli()},
+		{name: "tempest_station_wind_avg_distribution_bucket", le: "1", value: 1, timestamp: now.UnixMilli()},
+		{name: "tempest_station_wind_avg_distribution_bucket", le: "5", value: 3, timestamp: now.UnixMilli()},
+		{name: "tempest_station_wind_avg_distribution_bucket", le: "+Inf", value: 4, timestamp: now.UnixMilli()},
+		{name: "tempest_station_wind_avg_distribution_sum", value: 10, timestamp: now.UnixMilli()},
+		{name: "tempest_station_wind_avg_distribution_count", value: 4, timestamp: now.UnixMilli()},
+		{name: "tempest_exporter_scrape_seconds", quantile: "0.5", value: 0.7, timestamp: now.UnixMilli()},
+		{name: "tempest_exporter_scrape_seconds_sum", value: 1.5, timestamp: now.UnixMilli()},
+		{name: "tempest_exporter_scrape_seconds_count", value: 2, timestamp: now.UnixMilli()},
+	}
+	got := remoteWriteSamples(mfs, now)
+	if len(got) != len(want) {
+		t.Fatalf("remoteWriteSamples() returned %d samples, want %d", len(got), len(want))
+	}
+	for i, w := range want {
+		g := got[i]
+		s := sample{name: g.labels["__name__"], le: g.labels["le"], quantile: g.labels["quantile"], value: g.value, timestamp: g.timestamp}
+		if s != w {
+			t.Errorf("sample %d = %+v, want %+v", i, s, w)
+		}
+		if g.labels["station_id"] != "1" {
+			t.Errorf("sample %d labels = %v, want station_id 1", i, g.labels)
+		}
+	}
+}
+
+func TestEncodeWriteRequest(t *testing.T) {
+	samples := []remoteWriteSample{
+		{labels: map[string]string{"__name__": "tempest_station_uv", "station_id": "1", "a": "b"}, value: 3.5, timestamp: 1700000000000},
+	}
+	series := protoFields(decodeProto(t, encodeWriteRequest(samples)), 1)
+	if len(series) != 1 {
+		t.Fatalf("encoded %d time series, want 1", len(series))
+	}
+	ts := decodeProto(t, series[0].bytes)
+	var names []string
+	for _, l := range protoFields(ts, 1) {
+		pair := decodeProto(t, l.bytes)
+		names = append(names, string(protoFields(pair, 1)[0].bytes))
+	}
+	if want := []string{"__name__", "a", "station_id"}; len(names) != len(want) || names[0] != want[0] || names[1] != want[1] || names[2] != want[2] {
+		t.Errorf("label names = %v, want %v in order", names, want)
+	}
+	sample := decodeProto(t, protoFields(ts, 2)[0].bytes)
+	if v := math.Float64frombits(protoFields(sample, 1)[0].value); v != 3.5 {
+		t.Errorf("sample value = %v, want 3.5", v)
+	}
+	if ms := protoFields(sample, 2)[0].value; ms != 1700000000000 {
+		t.Errorf("sample timestamp = %d, want 1700000000000", ms)
+	}
+}
+
+func TestRemoteWrite(t *testing.T) {
+	mfs := []*dto.MetricFamily{{
+		Name:   proto.String("tempest_station_uv"),
+		Type:   dto.MetricType_GAUGE.Enum(),
+		Metric: []*dto.Metric{{Gauge: &dto.Gauge{Value: proto.Float64(3)}}},
+	}}
+	tests := []struct {
+		name   string
+		c      remoteWriteConfig
+		status int
+		auth   string
+		err    bool
+	}{
+		{name: "bearer token", c: remoteWriteConfig{BearerToken: "secret"}, status: http.StatusNoContent, auth: "Bearer secret"},
+		{name: "basic auth", c: remoteWriteConfig{Username: "user", Password: "pass"}, status: http.StatusNoContent, auth: "Basic dXNlcjpwYXNz"},
+		{name: "rejected", status: http.StatusBadRequest, err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var req *http.Request
+			var body []byte
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				req = r
+				body, _ = io.ReadAll(r.Body)
+				w.WriteHeader(tt.status)
+			}))
+			defer srv.Close()
+			tt.c.URL = srv.URL
+			tt.c.Headers = stringMap{"X-Scope-OrgID": "home"}
+			err := remoteWrite(srv.Client(), tt.c, mfs)
+			if (err != nil) != tt.err {
+				t.Fatalf("remoteWrite() error = %v, want error %v", err, tt.err)
+			}
+			if got := req.Header.Get("Authorization"); got != tt.auth {
+				t.Errorf("Authorization = %q, want %q", got, tt.auth)
+			}
+			if req.Header.Get("Content-Encoding") != "snappy" || req.Header.Get("X-Scope-OrgID") != "home" {
+				t.Errorf("headers = %v", req.Header)
+			}
+			b, err := snappy.Decode(nil, body)
+			if err != nil {
+				t.Fatalf("body isn't snappy encoded: %v", err)
+			}
+			if n := len(protoFields(decodeProto(t, b), 1)); n != 1 {
+				t.Errorf("body has %d time series, want 1", n)
+			}
+		})
+	}
+}
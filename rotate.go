@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// rotatedSuffix is the time format appended to the names of rotated log files
+const rotatedSuffix = "20060102T150405.000"
+
+// rotatingFile is a log file that's rotated once it reaches a maximum size or
+// age, keeping a limited number of rotated files
+type rotatingFile struct {
+	path       string
+	maxSize    int64
+	interval   time.Duration
+	maxBackups int
+	maxAge     time.Duration
+
+	mu     sync.Mutex
+	f      *os.File
+	size   int64
+	opened time.Time
+}
+
+// newRotatingFile opens a log file rotated as configured
+func newRotatingFile(c logConfig) (*rotatingFile, error) {
+	r := &rotatingFile{
+		path:       c.File,
+		maxSize:    int64(c.MaxSizeMB) << 20,
+		interval:   time.Duration(c.RotateInterval),
+		maxBackups: c.MaxBackups,
+		maxAge:     time.Duration(c.MaxAge),
+	}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// open opens our log file for appending
+func (r *rotatingFile) open() error {
+	f, err := os.OpenFile(r.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("error opening log file: %v", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("error opening log file: %v", err)
+	}
+	r.f, r.size, r.opened = f, info.Size(), time.Now()
+	return nil
+}
+
+// Write implements io.Writer, rotating the file first if writing p would take
+// it over its maximum size or it's been open for our rotation interval
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	full := r.maxSize > 0 && r.size > 0 && r.size+int64(len(p)) > r.maxSize
+	old := r.interval > 0 && time.Since(r.opened) >= r.interval
+	if r.f != nil && (full || old) {
+		if err := r.rotate(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	}
+	if r.f == nil {
+		if err := r.open(); err != nil {
+			// log to stderr rather than losing logs
+			fmt.Fprintln(os.Stderr, err)
+			return os.Stderr.Write(p)
+		}
+	}
+	n, err := r.f.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// rotate renames our log file aside, opens a new one and removes rotated files
+// beyond our retention
+func (r *rotatingFile) rotate() error {
+	r.f.Close()
+	r.f = nil
+	rotated := r.path + "." + time.Now().Format(rotatedSuffix)
+	if err := os.Rename(r.path, rotated); err != nil {
+		// carry on with the current file, trying again once it's grown by
+		// another file's worth
+		if err := r.open(); err == nil {
+			r.size = 0
+		}
+		return fmt.Errorf("error rotating log file: %v", err)
+	}
+	if err := r.open(); err != nil {
+		return err
+	}
+	return r.prune()
+}
+
+// prune removes the oldest rotated files beyond our maximum number of
+// backups, and any older than our maximum age
+func (r *rotatingFile) prune() error {
+	files, err := filepath.Glob(r.path + ".*")
+	if err != nil {
+		return err
+	}
+	var rotated []string
+	for _, f := range files {
+		if _, err := time.Parse(rotatedSuffix, f[len(r.path)+1:]); err == nil {
+			rotated = append(rotated, f)
+		}
+	}
+	// newest first, as the suffix sorts by time
+	sort.Sort(sort.Reverse(sort.StringSlice(rotated)))
+	for i, f := range rotated {
+		info, err := os.Stat(f)
+		if err != nil {
+			continue
+		}
+		expired := r.maxAge > 0 && time.Since(info.ModTime()) > r.maxAge
+		if (r.maxBackups > 0 && i >= r.maxBackups) || expired {
+			if err := os.Remove(f); err != nil {
+				return fmt.Errorf("error removing rotated log file: %v", err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// durationPattern matches the duration strings accepted by time.ParseDuration
+const durationPattern = `^(0|([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+)$`
+
+// textUnmarshaler is the type of values that can be parsed from a string
+var textUnmarshaler = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// schemaFor returns the JSON schema for a config value, using v (which may be
+// invalid) for defaults and the field's tags for constraints
+func schemaFor(t reflect.Type, v reflect.Value, tag reflect.StructTag) map[string]interface{} {
+	s := make(map[string]interface{})
+	if d := tag.Get("description"); d != "" {
+		s["description"] = d
+	}
+	if v.IsValid() && !v.IsZero() && t.Kind() != reflect.Struct {
+		s["default"] = v.Interface()
+	}
+	switch {
+	case t == reflect.TypeOf(duration(0)):
+		s["type"] = "string"
+		s["pattern"] = durationPattern
+	case t.Kind() == reflect.String:
+		s["type"] = "string"
+		if enum, ok := tag.Lookup("enum"); ok {
+			s["enum"] = strings.Split(enum, ",")
+		}
+	case t.Kind() == reflect.Int, t.Kind() == reflect.Float64:
+		s["type"] = "number"
+		if t.Kind() == reflect.Int {
+			s["type"] = "integer"
+		}
+		for _, k := range []string{"minimum", "maximum"} {
+			if n, err := strconv.ParseFloat(tag.Get(k), 64); err == nil {
+				s[k] = n
+			}
+		}
+	case t.Kind() == reflect.Bool:
+		s["type"] = "boolean"
+	case t.Kind() == reflect.Slice:
+		s["type"] = "array"
+		s["items"] = schemaFor(t.Elem(), reflect.Value{}, "")
+	case t.Kind() == reflect.Map:
+		s["type"] = "object"
+		s["additionalProperties"] = schemaFor(t.Elem(), reflect.Value{}, "")
+	case t.Kind() == reflect.Struct:
+		props := make(map[string]interface{})
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.PkgPath != "" {
+				continue
+			}
+			var fv reflect.Value
+			if v.IsValid() {
+				fv = v.Field(i)
+			}
+			props[f.Tag.Get("json")] = schemaFor(f.Type, fv, f.Tag)
+		}
+		s["type"] = "object"
+		s["properties"] = props
+		s["additionalProperties"] = false
+	}
+	// maps and lists parsed from strings, like floatMap, can also be given in
+	// the format of their environment variable
+	if (t.Kind() == reflect.Map || t.Kind() == reflect.Slice) && reflect.PtrTo(t).Implements(textUnmarshaler) {
+		native := make(map[string]interface{})
+		for _, k := range []string{"type", "items", "additionalProperties"} {
+			if x, ok := s[k]; ok {
+				native[k] = x
+				delete(s, k)
+			}
+		}
+		s["oneOf"] = []interface{}{map[string]interface{}{"type": "string"}, native}
+	}
+	return s
+}
+
+// configSchema returns the JSON schema for our config
+func configSchema() map[string]interface{} {
+	s := schemaFor(reflect.TypeOf(config{}), reflect.ValueOf(defaultConfig()), "")
+	s["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	s["title"] = "tempest-exporter config"
+	return s
+}
+
+// runConfigSchema implements the config-schema subcommand, returning the
+// process exit code
+func runConfigSchema() int {
+	b, err := json.MarshalIndent(configSchema(), "", "  ")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Println(string(b))
+	return 0
+}
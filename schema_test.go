@@ -0,0 +1,160 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// validate checks v against the parts of JSON schema configSchema uses
+func validate(s map[string]interface{}, v interface{}, path string) error {
+	if oneOf, ok := s["oneOf"].([]interface{}); ok {
+		matched := 0
+		for _, o := range oneOf {
+			if validate(o.(map[string]interface{}), v, path) == nil {
+				matched++
+			}
+		}
+		if matched != 1 {
+			return fmt.Errorf("%s matches %d schemas of oneOf, want 1", path, matched)
+		}
+	}
+	switch s["type"] {
+	case "string":
+		str, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("%s is %T, want a string", path, v)
+		}
+		if p, ok := s["pattern"].(string); ok && !regexp.MustCompile(p).MatchString(str) {
+			return fmt.Errorf("%s %q doesn't match %s", path, str, p)
+		}
+		if enum, ok := s["enum"].([]interface{}); ok {
+			for _, e := range enum {
+				if e == str {
+					return nil
+				}
+			}
+			return fmt.Errorf("%s %q isn't one of %v", path, str, enum)
+		}
+	case "number", "integer":
+		n, ok := v.(float64)
+		if !ok || s["type"] == "integer" && n != math.Trunc(n) {
+			return fmt.Errorf("%s is %v, want a %s", path, v, s["type"])
+		}
+		if min, ok := s["minimum"].(float64); ok && n < min {
+			return fmt.Errorf("%s is %v, below the minimum %v", path, n, min)
+		}
+		if max, ok := s["maximum"].(float64); ok && n > max {
+			return fmt.Errorf("%s is %v, above the maximum %v", path, n, max)
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return fmt.Errorf("%s is %T, want a boolean", path, v)
+		}
+	case "array":
+		a, ok := v.([]interface{})
+		if !ok {
+			return fmt.Errorf("%s is %T, want an array", path, v)
+		}
+		for i, item := range a {
+			if err := validate(s["items"].(map[string]interface{}), item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	case "object":
+		o, ok := v.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s is %T, want an object", path, v)
+		}
+		props, _ := s["properties"].(map[string]interface{})
+		for k, x := range o {
+			p, ok := props[k].(map[string]interface{})
+			if !ok {
+				if additional, ok := s["additionalProperties"].(map[string]interface{}); ok {
+					p = additional
+				} else if s["additionalProperties"] == false {
+					return fmt.Errorf("%s has unknown property %q", path, k)
+				}
+			}
+			if p == nil {
+				continue
+			}
+			if err := validate(p, x, path+"."+k); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// jsonValue returns v as it would be decoded from JSON
+func jsonValue(t *testing.T, v interface{}) interface{} {
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out interface{}
+	if err := json.Unmarshal(b, &out); err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+// validateYAML checks a config file against our config schema
+func validateYAML(t *testing.T, s string) error {
+	var v interface{}
+	if err := yaml.Unmarshal([]byte(s), &v); err != nil {
+		t.Fatal(err)
+	}
+	schema := jsonValue(t, configSchema()).(map[string]interface{})
+	return validate(schema, jsonValue(t, v), "config")
+}
+
+func TestConfigSchema(t *testing.T) {
+	tests := []struct {
+		name, yaml string
+		err        bool
+	}{
+		{name: "map as a string", yaml: "battery:\n  low_voltage: ST=2.4,AR=3\n"},
+		{name: "map as an object", yaml: "battery:\n  low_voltage: {ST: 2.4}\n"},
+		{name: "pairs as a string", yaml: "station_pairs: \"12345:67890\"\n"},
+		{name: "pairs as a list", yaml: "station_pairs: [{station_a: \"12345\", station_b: \"67890\"}]\n"},
+		{name: "relabel rules as json", yaml: "relabel: '[{\"action\": \"drop\", \"metric\": \"tempest_uv\"}]'\n"},
+		{name: "zero duration", yaml: "notify:\n  cooldown: {offline: \"0\"}\n"},
+		{name: "map of the wrong type", yaml: "battery:\n  low_voltage: [2.4]\n", err: true},
+		{name: "invalid duration", yaml: "poll_interval: soon\n", err: true},
+		{name: "unknown setting", yaml: "sauce: api\n", err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateYAML(t, tt.yaml); (err != nil) != tt.err {
+				t.Errorf("validate() error = %v, want error %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestConfigSchemaREADME(t *testing.T) {
+	b, err := os.ReadFile("README.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, section, ok := strings.Cut(string(b), "### Config file\n")
+	if !ok {
+		t.Fatal("README has no config file section")
+	}
+	_, example, ok := strings.Cut(section, "```yaml\n")
+	if !ok {
+		t.Fatal("README has no config file example")
+	}
+	example, _, _ = strings.Cut(example, "```")
+	if err := validateYAML(t, example); err != nil {
+		t.Errorf("README config file example doesn't match the schema: %v", err)
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/nalbury/tempest-exporter/pkg/weatherflow"
+)
+
+var (
+	// pollMu serializes polls triggered by concurrent scrapes
+	pollMu sync.Mutex
+	// lastPoll is when we last polled our stations on a scrape
+	lastPoll time.Time
+)
+
+// pollConfig is the config we fetch with, copied while holding our config
+// lock so we don't need to hold it while fetching
+type pollConfig struct {
+	client     *weatherflow.Client
+	forecast   forecastConfig
+	devices    devicesConfig
+	airQuality airQualityConfig
+}
+
+// currentPollConfig returns a copy of the config we fetch with
+func currentPollConfig() pollConfig {
+	return pollConfig{
+		client:     apiClient,
+		forecast:   cfg.Forecast,
+		devices:    cfg.Devices,
+		airQuality: cfg.AirQuality,
+	}
+}
+
+// stationPoll is the outcome of fetching a station's latest observations,
+// along with its forecast, devices and air quality when they're due
+type stationPoll struct {
+	station string
+	r       response
+	err     error
+	took    time.Duration
+	// forecast and airQuality are nil, and devices empty, when they weren't
+	// fetched
+	forecast   *forecastResponse
+	devices    []deviceReading
+	airQuality *aqReading
+	// fetched holds the outcome of each of those we tried to fetch, keyed by
+	// the component it's reported as
+	fetched map[string]error
+}
+
+// dueStations returns our stations that aren't backing off
+func dueStations(now time.Time) []string {
+	var due []string
+	for _, s := range stations() {
+		if shouldPoll(s, now) {
+			due = append(due, s)
+		}
+	}
+	return due
+}
+
+// fetchStations fetches the latest observations of each station, and the
+// forecast, devices and air quality of those that are due, as configured by c
+func fetchStations(stations []string, c pollConfig) []stationPoll {
+	slog.Debug("getting latest observations")
+	polls := make([]stationPoll, 0, len(stations))
+	for _, s := range stations {
+		start := time.Now()
+		r, err := fetchObservations(c.client, s)
+		p := stationPoll{station: s, r: r, err: err, took: time.Since(start), fetched: make(map[string]error)}
+		if err == nil {
+			if c.forecast.Enabled {
+				p.forecast, p.fetched["forecast"] = fetchForecast(c.client, c.forecast, s)
+			}
+			p.devices, p.fetched["devices"] = fetchDevices(c.client, c.devices, s, r)
+			if c.airQuality.Source != "" && s == c.airQuality.StationID {
+				aq, err := getAirQuality(c.airQuality)
+				if err == nil {
+					p.airQuality = &aq
+				}
+				p.fetched["airquality"] = err
+			}
+		}
+		polls = append(polls, p)
+	}
+	return polls
+}
+
+// updateStations updates our metrics from the observations we fetched at now.
+// A station that failed to fetch keeps its last metrics.
+func updateStations(polls []stationPoll, now time.Time) {
+	for _, p := range polls {
+		recordScrape(p.station, p.took, p.err)
+		if p.err != nil {
+			d := pollFailed(p.station, now, p.err)
+			slog.Error("error polling station", "station_id", p.station, "retry_in", d, "err", p.err)
+			reportFailure("api", p.err)
+			continue
+		}
+		updateStation(p)
+		pollSucceeded(p.station)
+		reportSuccess("api")
+	}
+	setDifferentials()
+	beat()
+}
+
+// pollAll polls each of our stations that isn't backing off and updates our
+// metrics
+func pollAll() {
+	now := time.Now()
+	updateStations(fetchStations(dueStations(now), currentPollConfig()), now)
+}
+
+// refresh polls our stations unless we already have for a scrape within the
+// scrape cache TTL
+func refresh() {
+	pollMu.Lock()
+	defer pollMu.Unlock()
+	if time.Since(lastPoll) < time.Duration(cfg.ScrapeCacheTTL) {
+		return
+	}
+	pollAll()
+	lastPoll = time.Now()
+}
+
+// scrapeGatherer returns a gatherer that refreshes our data before gathering
+// from g, so it's only fetched when we're scraped
+func scrapeGatherer(g prometheus.Gatherer) prometheus.Gatherer {
+	return prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		refresh()
+		return g.Gather()
+	})
+}
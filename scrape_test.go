@@ -0,0 +1,70 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/nalbury/tempest-exporter/pkg/weatherflow"
+)
+
+func TestFetchStationsWithoutConfigLock(t *testing.T) {
+	defer func(m map[string]stationDevices) { devices = m }(devices)
+	defer func(m map[string]time.Time) { forecastFetched = m }(forecastFetched)
+	devices = make(map[string]stationDevices)
+	forecastFetched = make(map[string]time.Time)
+	responses := map[string]string{
+		"/observations/station/1": `{"station_id":1,"obs":[{"timestamp":1700000000}]}`,
+		"/better_forecast":        `{}`,
+		"/stations/1":             `{"stations":[{"station_id":1,"devices":[{"device_id":2,"device_type":"ST"},{"device_id":3,"device_type":"HB"}]}]}`,
+		"/observations/device/2":  `{"type":"obs_st","obs":[[1700000000]]}`,
+		"/json":                   `{"pm2_5_atm":4}`,
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := responses[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(body))
+	}))
+	defer srv.Close()
+	client := weatherflow.NewClient("token")
+	client.BaseURL = srv.URL
+	c := pollConfig{
+		client:     client,
+		forecast:   forecastConfig{Enabled: true, Interval: duration(time.Hour)},
+		devices:    devicesConfig{Enabled: true, Interval: duration(time.Minute)},
+		airQuality: airQualityConfig{Source: "purpleair", URL: srv.URL, StationID: "1"},
+	}
+	// a reload holding our config lock mustn't hold up fetching
+	configMu.Lock()
+	defer configMu.Unlock()
+	polls := make(chan []stationPoll, 1)
+	go func() { polls <- fetchStations([]string{"1"}, c) }()
+	var p stationPoll
+	select {
+	case ps := <-polls:
+		p = ps[0]
+	case <-time.After(5 * time.Second):
+		t.Fatal("fetchStations() waited on our config lock")
+	}
+	if p.err != nil {
+		t.Fatalf("fetchStations() error = %v", p.err)
+	}
+	for component, err := range p.fetched {
+		if err != nil {
+			t.Errorf("fetchStations() %s error = %v", component, err)
+		}
+	}
+	if len(p.fetched) != 3 {
+		t.Errorf("fetchStations() fetched %v, want forecast, devices and airquality", p.fetched)
+	}
+	if p.forecast == nil || p.airQuality == nil || p.airQuality.PM25 != 4 {
+		t.Errorf("fetchStations() forecast = %v, air quality = %v", p.forecast, p.airQuality)
+	}
+	if len(p.devices) != 1 || p.devices[0].d.DeviceID != 2 {
+		t.Errorf("fetchStations() devices = %+v, want device 2", p.devices)
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// targetGroup is a group of scrape targets in Prometheus' http_sd format
+type targetGroup struct {
+	Targets []string          `json:"targets"`
+	Labels  map[string]string `json:"labels"`
+}
+
+// sdHandler lists each of our stations as a target for Prometheus' HTTP
+// service discovery, to be scraped through /probe
+func sdHandler(w http.ResponseWriter, req *http.Request) {
+	groups := []targetGroup{}
+	for _, s := range stations() {
+		groups = append(groups, targetGroup{
+			Targets: []string{s},
+			Labels:  map[string]string{"__meta_tempest_station_id": s},
+		})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(groups)
+}
+
+// stationGatherer keeps only the metrics of a single station from those
+// gathered from g
+type stationGatherer struct {
+	g       prometheus.Gatherer
+	station string
+}
+
+// Gather implements prometheus.Gatherer
+func (s stationGatherer) Gather() ([]*dto.MetricFamily, error) {
+	mfs, err := s.g.Gather()
+	var out []*dto.MetricFamily
+	for _, mf := range mfs {
+		var keep []*dto.Metric
+		for _, m := range mf.Metric {
+			if station, _ := labelValue(m, "station_id"); station == s.station {
+				keep = append(keep, m)
+			}
+		}
+		if len(keep) == 0 {
+			continue
+		}
+		mf.Metric = keep
+		out = append(out, mf)
+	}
+	return out, err
+}
+
+// probeHandler serves the metrics of the station given by the target query
+// parameter, from those gathered from g
+func probeHandler(g prometheus.Gatherer) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		target := req.URL.Query().Get("target")
+		if target == "" {
+			http.Error(w, "target parameter is missing", http.StatusBadRequest)
+			return
+		}
+		if !contains(stations(), target) {
+			http.Error(w, fmt.Sprintf("unknown station %q", target), http.StatusNotFound)
+			return
+		}
+		unitsHandler(stationGatherer{g, target}).ServeHTTP(w, req)
+	})
+}
@@ -0,0 +1,25 @@
+//go:build !windows
+
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// isWindowsService returns whether we were started by the Windows service
+// manager, which we never are here
+func isWindowsService() bool {
+	return false
+}
+
+// runWindowsService is only supported on Windows
+func runWindowsService(run func() error) {
+	fatal(fmt.Errorf("windows services are only supported on windows"))
+}
+
+// runServiceCommand is only supported on Windows
+func runServiceCommand(args []string) int {
+	fmt.Fprintln(os.Stderr, "the service command is only supported on windows")
+	return 1
+}
@@ -0,0 +1,176 @@
+//go:build windows
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+// serviceName is the name we're installed as a Windows service under
+const serviceName = "tempest-exporter"
+
+// isWindowsService returns whether we were started by the Windows service
+// manager
+func isWindowsService() bool {
+	ok, err := svc.IsWindowsService()
+	return err == nil && ok
+}
+
+// service runs the exporter under the Windows service manager
+type service struct {
+	run func() error
+}
+
+// Execute implements svc.Handler, running the exporter until the service
+// manager stops it or it fails
+func (s service) Execute(args []string, req <-chan svc.ChangeRequest, status chan<- svc.Status) (bool, uint32) {
+	status <- svc.Status{State: svc.StartPending}
+	done := make(chan error, 1)
+	go func() { done <- s.run() }()
+	status <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+	for {
+		select {
+		case err := <-done:
+			if err != nil {
+				fatal(err)
+			}
+			return false, 0
+		case c := <-req:
+			switch c.Cmd {
+			case svc.Interrogate:
+				status <- c.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				status <- svc.Status{State: svc.StopPending}
+				stopServer()
+				<-done
+				return false, 0
+			}
+		}
+	}
+}
+
+// runWindowsService runs the exporter with run under the Windows service
+// manager
+func runWindowsService(run func() error) {
+	if err := svc.Run(serviceName, service{run}); err != nil {
+		fatal(fmt.Errorf("error running service: %v", err))
+	}
+}
+
+// runServiceCommand installs, removes, starts or stops our Windows service
+func runServiceCommand(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: tempest-exporter service install|uninstall|start|stop [flags]")
+		return 2
+	}
+	m, err := mgr.Connect()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error connecting to the service manager: %v\n", err)
+		return 1
+	}
+	defer m.Disconnect()
+	switch args[0] {
+	case "install":
+		err = installService(m, args[1:])
+	case "uninstall":
+		err = uninstallService(m)
+	case "start":
+		err = startService(m)
+	case "stop":
+		err = stopService(m)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown service command %q\n", args[0])
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// installService installs us as an automatically started service, run with
+// flags
+func installService(m *mgr.Mgr, flags []string) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("error finding executable: %v", err)
+	}
+	if exe, err = filepath.Abs(exe); err != nil {
+		return fmt.Errorf("error finding executable: %v", err)
+	}
+	if s, err := m.OpenService(serviceName); err == nil {
+		s.Close()
+		return fmt.Errorf("service %s is already installed", serviceName)
+	}
+	s, err := m.CreateService(serviceName, exe, mgr.Config{
+		DisplayName: "Tempest Exporter",
+		Description: "Prometheus exporter for the WeatherFlow Tempest weather station",
+		StartType:   mgr.StartAutomatic,
+	}, flags...)
+	if err != nil {
+		return fmt.Errorf("error installing service: %v", err)
+	}
+	defer s.Close()
+	fmt.Printf("installed service %s\n", serviceName)
+	return nil
+}
+
+// uninstallService removes our service
+func uninstallService(m *mgr.Mgr) error {
+	s, err := m.OpenService(serviceName)
+	if err != nil {
+		return fmt.Errorf("service %s is not installed", serviceName)
+	}
+	defer s.Close()
+	if err := s.Delete(); err != nil {
+		return fmt.Errorf("error uninstalling service: %v", err)
+	}
+	fmt.Printf("uninstalled service %s\n", serviceName)
+	return nil
+}
+
+// startService starts our service
+func startService(m *mgr.Mgr) error {
+	s, err := m.OpenService(serviceName)
+	if err != nil {
+		return fmt.Errorf("service %s is not installed", serviceName)
+	}
+	defer s.Close()
+	if err := s.Start(); err != nil {
+		return fmt.Errorf("error starting service: %v", err)
+	}
+	fmt.Printf("started service %s\n", serviceName)
+	return nil
+}
+
+// stopService stops our service, waiting for it to stop
+func stopService(m *mgr.Mgr) error {
+	s, err := m.OpenService(serviceName)
+	if err != nil {
+		return fmt.Errorf("service %s is not installed", serviceName)
+	}
+	defer s.Close()
+	status, err := s.Control(svc.Stop)
+	if err != nil {
+		return fmt.Errorf("error stopping service: %v", err)
+	}
+	deadline := time.Now().Add(30 * time.Second)
+	for status.State != svc.Stopped {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out waiting for service %s to stop", serviceName)
+		}
+		time.Sleep(500 * time.Millisecond)
+		if status, err = s.Query(); err != nil {
+			return fmt.Errorf("error querying service: %v", err)
+		}
+	}
+	fmt.Printf("stopped service %s\n", serviceName)
+	return nil
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// sinkRecord is an observation or event pushed to our sinks
+type sinkRecord struct {
+	Kind      string             `json:"kind"`
+	StationID string             `json:"station_id"`
+	Labels    map[string]string  `json:"labels,omitempty"`
+	Values    map[string]float64 `json:"values,omitempty"`
+	Event     *webhookEvent      `json:"event,omitempty"`
+	Time      time.Time          `json:"time"`
+}
+
+// sink is a destination we push observations and events to
+type sink interface {
+	// name identifies the sink in logs and error reports
+	name() string
+	// send delivers a record to the sink
+	send(rec sinkRecord) error
+}
+
+// uploadTimeout is how long we allow for each upload to a weather network
+const uploadTimeout = 10 * time.Second
+
+// uploadParam is the parameter a weather network takes a reading as, and the
+// conversion from our units, if any
+type uploadParam struct {
+	name    string
+	convert func(float64) float64
+}
+
+// errSkipped is returned by sinks that chose not to send a record
+var errSkipped = errors.New("skipped")
+
+var (
+	// sinks are the sinks we've been configured with
+	sinks []sink
+	// sinkTimestamps holds the latest observation timestamp pushed to our
+	// sinks for each station
+	sinkTimestamps = make(map[string]float64)
+	// sinkSends counts the records sent to each sink by result
+	sinkSends = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: ns,
+			Subsystem: "exporter",
+			Name:      "sink_sends_total",
+			Help:      "Records sent to each sink, by result (success or failure)",
+		},
+		[]string{"sink", "result"},
+	)
+	// sinkLastSuccess is when each sink last sent a record successfully
+	sinkLastSuccess = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: ns,
+			Subsystem: "exporter",
+			Name:      "sink_last_success_timestamp_seconds",
+			Help:      "Unix timestamp of the sink's last successful send",
+		},
+		[]string{"sink"},
+	)
+)
+
+// throttledSink sends one station's observations to a sink no more often than
+// an interval, as uploaders to weather networks ask of their stations
+type throttledSink struct {
+	sink
+	station  string
+	interval time.Duration
+	mu       sync.Mutex
+	sent     time.Time
+}
+
+// send implements sink
+func (s *throttledSink) send(rec sinkRecord) error {
+	if rec.Kind != "observation" || rec.StationID != s.station {
+		return errSkipped
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if rec.Time.Sub(s.sent) < s.interval {
+		return errSkipped
+	}
+	if err := s.sink.send(rec); err != nil {
+		return err
+	}
+	s.sent = rec.Time
+	return nil
+}
+
+// setupSinks creates the sinks enabled in our config, replacing any we had
+func setupSinks() error {
+	var enabled []sink
+	if cfg.HTTPSink.URL != "" {
+		s, err := newHTTPSink(cfg.HTTPSink)
+		if err != nil {
+			return err
+		}
+		enabled = append(enabled, s)
+	}
+	if cfg.CWOP.Callsign != "" {
+		enabled = append(enabled, &throttledSink{sink: newCWOPSink(cfg.CWOP), station: cfg.CWOP.Station, interval: time.Duration(cfg.CWOP.Interval)})
+	}
+	if cfg.PWSWeather.StationID != "" {
+		enabled = append(enabled, &throttledSink{sink: newPWSWeatherSink(cfg.PWSWeather), station: cfg.PWSWeather.Station, interval: time.Duration(cfg.PWSWeather.Interval)})
+	}
+	if cfg.Windy.APIKey != "" {
+		enabled = append(enabled, &throttledSink{sink: newWindySink(cfg.Windy), station: cfg.Windy.Station, interval: time.Duration(cfg.Windy.Interval)})
+	}
+	if cfg.InfluxDB.URL != "" {
+		s, err := newInfluxSink(cfg.InfluxDB)
+		if err != nil {
+			return err
+		}
+		enabled = append(enabled, s)
+	}
+	if cfg.Graphite.Address != "" {
+		s, err := newGraphiteSink(cfg.Graphite)
+		if err != nil {
+			return err
+		}
+		enabled = append(enabled, s)
+	}
+	if cfg.StatsD.Address != "" {
+		enabled = append(enabled, &statsdSink{c: cfg.StatsD})
+	}
+	if cfg.MQTT.Broker != "" {
+		// our MQTT sinks share a connection, as brokers only allow one per
+		// client ID
+		client := newMQTTClient(cfg.MQTT)
+		if cfg.MQTT.Publish != "none" {
+			s, err := newMQTTSink(cfg.MQTT, client)
+			if err != nil {
+				return err
+			}
+			enabled = append(enabled, s)
+		}
+		if cfg.MQTT.HomeAssistant {
+			enabled = append(enabled, newHomeAssistantSink(cfg.MQTT, client))
+		}
+	}
+	closeSinks()
+	sinks = enabled
+	return nil
+}
+
+// closeSinks closes any of our sinks that hold connections open
+func closeSinks() {
+	for _, s := range sinks {
+		if c, ok := s.(io.Closer); ok {
+			if err := c.Close(); err != nil {
+				slog.Warn(err.Error(), "sink", s.name())
+			}
+		}
+	}
+}
+
+// getUpload uploads to a weather network whose API takes observations as the
+// query of a GET request. Errors leave out the URL, which holds credentials.
+func getUpload(client *http.Client, u string) error {
+	resp, err := client.Get(u)
+	if err != nil {
+		var uerr *url.Error
+		if errors.As(err, &uerr) {
+			err = uerr.Err
+		}
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s", resp.Status)
+	}
+	return nil
+}
+
+// publish sends a record to each of our sinks in the background
+func publish(rec sinkRecord) {
+	for _, s := range sinks {
+		go func(s sink) {
+			err := s.send(rec)
+			switch {
+			case errors.Is(err, errSkipped):
+			case err != nil:
+				slog.Error(err.Error(), "sink", s.name())
+				sinkSends.WithLabelValues(s.name(), "failure").Inc()
+				reportFailure(s.name(), err)
+			default:
+				sinkSends.WithLabelValues(s.name(), "success").Inc()
+				sinkLastSuccess.WithLabelValues(s.name()).SetToCurrentTime()
+				reportSuccess(s.name())
+			}
+		}(s)
+	}
+}
+
+// publishObservation sends a station's observation to our sinks if we haven't
+// already sent it
+func publishObservation(stationID string, o observation, labels prometheus.Labels) {
+	if len(sinks) == 0 || o.Timestamp == sinkTimestamps[stationID] {
+		return
+	}
+	sinkTimestamps[stationID] = o.Timestamp
+	publish(observationRecord(stationID, o, labels))
+}
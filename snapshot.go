@@ -0,0 +1,22 @@
+package main
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// metricsMu is held for writing while our weather metrics are updated from an
+// observation, and for reading while they're gathered, so a scrape never sees
+// some readings from one observation and the rest from the one before
+var metricsMu sync.RWMutex
+
+// snapshotGatherer gathers from g between updates to our weather metrics
+func snapshotGatherer(g prometheus.Gatherer) prometheus.Gatherer {
+	return prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		metricsMu.RLock()
+		defer metricsMu.RUnlock()
+		return g.Gather()
+	})
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// staleExempt are the metrics still exported for a stale station, as they
+// describe the station or its health rather than its latest readings
+var staleExempt = map[string]bool{
+	ns + "_up":                                  true,
+	ns + "_last_scrape_error":                   true,
+	ns + "_scrape_duration_seconds":             true,
+	ns + "_observation_timestamp_seconds":       true,
+	ns + "_observation_age_seconds":             true,
+	ns + "_" + ss + "_info":                     true,
+	ns + "_" + ss + "_record_value":             true,
+	ns + "_" + ss + "_record_timestamp_seconds": true,
+	ns + "_" + ss + "_record_season_info":       true,
+}
+
+// staleStations returns the stations whose latest observation is older than
+// our stale threshold, as of now
+func staleStations(now time.Time) map[string]bool {
+	stale := make(map[string]bool)
+	observationMu.Lock()
+	defer observationMu.Unlock()
+	for station, t := range observationTimes {
+		if now.Sub(time.Unix(int64(t), 0)) > time.Duration(cfg.StaleAfter) {
+			stale[station] = true
+		}
+	}
+	return stale
+}
+
+// staleGatherer drops the readings of stations that have stopped reporting
+// from the metrics gathered from g, so dead stations don't look healthy
+type staleGatherer struct {
+	g prometheus.Gatherer
+}
+
+// Gather implements prometheus.Gatherer
+func (s staleGatherer) Gather() ([]*dto.MetricFamily, error) {
+	mfs, err := s.g.Gather()
+	if cfg.StaleAfter <= 0 {
+		return mfs, err
+	}
+	stale := staleStations(time.Now())
+	if len(stale) == 0 {
+		return mfs, err
+	}
+	var out []*dto.MetricFamily
+	for _, mf := range mfs {
+		if staleExempt[mf.GetName()] {
+			out = append(out, mf)
+			continue
+		}
+		var keep []*dto.Metric
+		for _, m := range mf.Metric {
+			if station, ok := labelValue(m, "station_id"); !ok || !stale[station] {
+				keep = append(keep, m)
+			}
+		}
+		if len(keep) == 0 {
+			continue
+		}
+		mf.Metric = keep
+		out = append(out, mf)
+	}
+	return out, err
+}
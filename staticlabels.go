@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// labelNamePattern matches valid Prometheus label names
+var labelNamePattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// checkStaticLabels validates our static label names, which mustn't clash
+// with the labels we export ourselves
+func checkStaticLabels(labels stringMap) error {
+	var r response
+	known := r.allLabels()
+	for k := range labels {
+		if !labelNamePattern.MatchString(k) {
+			return fmt.Errorf("invalid static label name %q", k)
+		}
+		if _, ok := known[k]; ok {
+			return fmt.Errorf("static label %q clashes with a station label", k)
+		}
+	}
+	return nil
+}
+
+// staticLabelsFor returns the static labels for a station, which are our
+// global static labels overridden by any set for the station
+func staticLabelsFor(station string) stringMap {
+	labels := make(stringMap)
+	for k, v := range cfg.StaticLabels {
+		labels[k] = v
+	}
+	for k, v := range cfg.StationStaticLabels[station] {
+		labels[k] = v
+	}
+	return labels
+}
+
+// staticLabelsGatherer adds our static labels to the metrics gathered from g.
+// Metrics for a station get that station's static labels, and any others just
+// the global ones. Labels a metric already has are left alone.
+type staticLabelsGatherer struct {
+	g prometheus.Gatherer
+}
+
+// Gather implements prometheus.Gatherer
+func (s staticLabelsGatherer) Gather() ([]*dto.MetricFamily, error) {
+	mfs, err := s.g.Gather()
+	if len(cfg.StaticLabels) == 0 && len(cfg.StationStaticLabels) == 0 {
+		return mfs, err
+	}
+	for _, mf := range mfs {
+		for _, m := range mf.Metric {
+			station, _ := labelValue(m, "station_id")
+			for k, v := range staticLabelsFor(station) {
+				if _, ok := labelValue(m, k); ok {
+					continue
+				}
+				k, v := k, v
+				m.Label = append(m.Label, &dto.LabelPair{Name: &k, Value: &v})
+			}
+			sort.Slice(m.Label, func(i, j int) bool {
+				return m.Label[i].GetName() < m.Label[j].GetName()
+			})
+		}
+	}
+	return mfs, err
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// statsdMaxPacket is the most we put in one UDP packet, to stay under the
+// MTU of most networks
+const statsdMaxPacket = 1432
+
+// statsdTagEscaper replaces the characters that separate DogStatsD tags
+var statsdTagEscaper = strings.NewReplacer(",", "_", "|", "_", "#", "_", "\n", "_")
+
+// statsdSink sends each reading of an observation to a StatsD or DogStatsD
+// agent as a gauge
+type statsdSink struct {
+	c statsdConfig
+}
+
+// name implements sink
+func (s *statsdSink) name() string {
+	return "statsd"
+}
+
+// tags returns the DogStatsD tags of an observation, its station's labels
+// along with any configured tags
+func (s *statsdSink) tags(rec sinkRecord) string {
+	var tags []string
+	for k, v := range rec.Labels {
+		// empty tags only add noise
+		if v == "" {
+			continue
+		}
+		tags = append(tags, statsdTagEscaper.Replace(k+":"+v))
+	}
+	for k, v := range s.c.Tags {
+		tags = append(tags, statsdTagEscaper.Replace(k+":"+v))
+	}
+	sort.Strings(tags)
+	return strings.Join(tags, ",")
+}
+
+// lines formats an observation as a gauge line per reading. Plain StatsD has
+// no tags, so the station is part of each name instead, and reads gauges
+// with a sign as changes, so negative readings are sent after a reset to 0.
+func (s *statsdSink) lines(rec sinkRecord) []string {
+	fields := make([]string, 0, len(rec.Values))
+	for k := range rec.Values {
+		fields = append(fields, k)
+	}
+	sort.Strings(fields)
+	tags := s.tags(rec)
+	var lines []string
+	for _, k := range fields {
+		v := strconv.FormatFloat(rec.Values[k], 'f', -1, 64)
+		if s.c.Flavor == "dogstatsd" {
+			line := fmt.Sprintf("%s.%s:%s|g", s.c.Prefix, k, v)
+			if tags != "" {
+				line += "|#" + tags
+			}
+			lines = append(lines, line)
+			continue
+		}
+		name := fmt.Sprintf("%s.%s.%s", s.c.Prefix, graphiteNode(rec.StationID), k)
+		if rec.Values[k] < 0 {
+			lines = append(lines, name+":0|g")
+		}
+		lines = append(lines, name+":"+v+"|g")
+	}
+	return lines
+}
+
+// send implements sink
+func (s *statsdSink) send(rec sinkRecord) error {
+	if rec.Kind != "observation" || len(rec.Values) == 0 {
+		return errSkipped
+	}
+	conn, err := net.Dial("udp", s.c.Address)
+	if err != nil {
+		return fmt.Errorf("error connecting to statsd: %v", err)
+	}
+	defer conn.Close()
+	var packet bytes.Buffer
+	flush := func() error {
+		if packet.Len() == 0 {
+			return nil
+		}
+		_, err := conn.Write(packet.Bytes())
+		packet.Reset()
+		if err != nil {
+			return fmt.Errorf("error writing to statsd: %v", err)
+		}
+		return nil
+	}
+	for _, line := range s.lines(rec) {
+		if packet.Len() > 0 && packet.Len()+1+len(line) > statsdMaxPacket {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+		if packet.Len() > 0 {
+			packet.WriteByte('\n')
+		}
+		packet.WriteString(line)
+	}
+	return flush()
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStatsdLines(t *testing.T) {
+	rec := sinkRecord{
+		StationID: "1",
+		Labels:    map[string]string{"station_name": "Back Yard", "agl": "", "region": "a,b"},
+		Values:    map[string]float64{"uv": 3, "air_temperature": -1.5},
+	}
+	tests := []struct {
+		name string
+		c    statsdConfig
+		want []string
+	}{
+		{
+			name: "dogstatsd",
+			c:    statsdConfig{Flavor: "dogstatsd", Prefix: "tempest", Tags: stringMap{"env": "home"}},
+			want: []string{
+				"tempest.air_temperature:-1.5|g|#env:home,region:a_b,station_name:Back Yard",
+				"tempest.uv:3|g|#env:home,region:a_b,station_name:Back Yard",
+			},
+		},
+		{
+			name: "statsd",
+			c:    statsdConfig{Flavor: "statsd", Prefix: "tempest"},
+			want: []string{
+				"tempest.1.air_temperature:0|g",
+				"tempest.1.air_temperature:-1.5|g",
+				"tempest.1.uv:3|g",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &statsdSink{c: tt.c}
+			if got := s.lines(rec); strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("lines() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
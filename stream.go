@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// streamBuffer is how many records we hold for each streaming client before
+// dropping records it's too slow to take
+const streamBuffer = 64
+
+// streamKeepAlive is how often we write to idle streams, so proxies don't
+// close them
+const streamKeepAlive = 30 * time.Second
+
+var (
+	// streamMu guards streamClients
+	streamMu sync.Mutex
+	// streamClients are the channels of the clients streaming our records,
+	// which are closed when our server shuts down
+	streamClients = make(map[chan sinkRecord]bool)
+	// streamPaths are the paths of our streaming endpoints, whose requests
+	// don't hold our config as they last as long as the client stays connected
+	streamPaths = map[string]bool{"/events": true, "/ws": true}
+)
+
+func init() {
+	server.RegisterOnShutdown(closeStreams)
+}
+
+// subscribe returns a channel that receives each record we stream
+func subscribe() chan sinkRecord {
+	ch := make(chan sinkRecord, streamBuffer)
+	streamMu.Lock()
+	defer streamMu.Unlock()
+	streamClients[ch] = true
+	return ch
+}
+
+// subscribeLatest subscribes to our records, also returning the latest
+// observation of each station to start streaming with
+func subscribeLatest() (chan sinkRecord, []sinkRecord) {
+	// observations are streamed while our metrics are locked for writing, so
+	// subscribing with them locked for reading streams each one just once
+	metricsMu.RLock()
+	defer metricsMu.RUnlock()
+	ch := subscribe()
+	var recs []sinkRecord
+	for _, id := range sortedStations() {
+		recs = append(recs, latestRecords[id])
+	}
+	return ch, recs
+}
+
+// unsubscribe stops streaming records to a channel
+func unsubscribe(ch chan sinkRecord) {
+	streamMu.Lock()
+	defer streamMu.Unlock()
+	if streamClients[ch] {
+		delete(streamClients, ch)
+		close(ch)
+	}
+}
+
+// closeStreams ends every stream, so our server can shut down
+func closeStreams() {
+	streamMu.Lock()
+	defer streamMu.Unlock()
+	for ch := range streamClients {
+		delete(streamClients, ch)
+		close(ch)
+	}
+}
+
+// broadcast streams a record to each client, skipping clients whose buffer
+// is full rather than holding up the caller
+func broadcast(rec sinkRecord) {
+	streamMu.Lock()
+	defer streamMu.Unlock()
+	for ch := range streamClients {
+		select {
+		case ch <- rec:
+		default:
+		}
+	}
+}
+
+// broadcastStrike streams a lightning strike from an evt_strike broadcast
+func broadcastStrike(m udpMessage) {
+	broadcast(sinkRecord{
+		Kind:      "lightning_strike",
+		StationID: m.HubSN,
+		Labels:    udpLabels(m.HubSN),
+		Values:    map[string]float64{"distance": m.Evt[1], "energy": m.Evt[2]},
+		Time:      time.Unix(int64(m.Evt[0]), 0),
+	})
+}
+
+// broadcastRainStart streams the start of rain from an evt_precip broadcast
+func broadcastRainStart(m udpMessage) {
+	broadcast(sinkRecord{
+		Kind:      "rain_start",
+		StationID: m.HubSN,
+		Labels:    udpLabels(m.HubSN),
+		Time:      time.Unix(int64(m.Evt[0]), 0),
+	})
+}
+
+// eventsHandler streams each new observation and event as Server-Sent
+// Events, starting with the latest observations, only from the station given
+// by the station query parameter if it's set
+func eventsHandler(w http.ResponseWriter, req *http.Request) {
+	station := req.URL.Query().Get("station")
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	write := func(rec sinkRecord) error {
+		if station != "" && rec.StationID != station {
+			return nil
+		}
+		b, err := json.Marshal(rec)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", rec.Kind, b); err != nil {
+			return err
+		}
+		return rc.Flush()
+	}
+	ch, recs := subscribeLatest()
+	defer unsubscribe(ch)
+	for _, rec := range recs {
+		if err := write(rec); err != nil {
+			return
+		}
+	}
+	if err := rc.Flush(); err != nil {
+		return
+	}
+	ticker := time.NewTicker(streamKeepAlive)
+	defer ticker.Stop()
+	for {
+		select {
+		case rec, ok := <-ch:
+			if !ok {
+				return
+			}
+			if err := write(rec); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		case <-req.Context().Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// syslogFacility is the syslog facility we log as, daemon
+const syslogFacility = 3
+
+// syslogSeverity returns the syslog severity of a log level
+func syslogSeverity(l slog.Level) int {
+	switch {
+	case l >= slog.LevelError:
+		return 3
+	case l >= slog.LevelWarn:
+		return 4
+	case l >= slog.LevelInfo:
+		return 6
+	default:
+		return 7
+	}
+}
+
+// localSyslogSockets are where we look for the local syslog daemon
+var localSyslogSockets = []string{"/dev/log", "/var/run/syslog", "/var/run/log"}
+
+// syslogWriter sends RFC 5424 messages to a local or remote syslog daemon,
+// reconnecting if a send fails
+type syslogWriter struct {
+	network  string
+	addr     string
+	hostname string
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+// newSyslogWriter connects to the syslog daemon at addr, which is local for
+// the local daemon or a udp://, tcp:// or unix:// URL
+func newSyslogWriter(addr string) (*syslogWriter, error) {
+	w := &syslogWriter{}
+	w.hostname, _ = os.Hostname()
+	if w.hostname == "" {
+		w.hostname = "-"
+	}
+	if addr != "local" {
+		u, err := url.Parse(addr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid syslog address: %v", err)
+		}
+		switch u.Scheme {
+		case "udp", "tcp":
+			w.network, w.addr = u.Scheme, u.Host
+		case "unix":
+			w.network, w.addr = "unixgram", u.Path
+		default:
+			return nil, fmt.Errorf("invalid syslog address %q, expected local or a udp://, tcp:// or unix:// URL", addr)
+		}
+	}
+	if err := w.connect(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// connect connects to our syslog daemon, finding the local one if we weren't
+// given an address
+func (w *syslogWriter) connect() error {
+	if w.addr != "" {
+		conn, err := net.DialTimeout(w.network, w.addr, 5*time.Second)
+		if err != nil {
+			return fmt.Errorf("error connecting to syslog: %v", err)
+		}
+		w.conn = conn
+		return nil
+	}
+	for _, path := range localSyslogSockets {
+		for _, network := range []string{"unixgram", "unix"} {
+			if conn, err := net.Dial(network, path); err == nil {
+				w.network, w.addr, w.conn = network, path, conn
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("error connecting to syslog: no local syslog daemon found")
+}
+
+// send sends msg at severity, as a single message
+func (w *syslogWriter) send(severity int, msg []byte) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	line := fmt.Sprintf("<%d>1 %s %s tempest-exporter %d - - %s",
+		syslogFacility*8+severity, time.Now().Format(time.RFC3339Nano), w.hostname, os.Getpid(),
+		strings.TrimRight(string(msg), "\n"))
+	if w.network == "tcp" || w.network == "unix" {
+		// stream transports frame messages by octet counting, as in RFC 6587
+		line = fmt.Sprintf("%d %s", len(line), line)
+	}
+	var err error
+	for i := 0; i < 2; i++ {
+		if w.conn == nil {
+			if err = w.connect(); err != nil {
+				continue
+			}
+		}
+		if _, err = w.conn.Write([]byte(line)); err == nil {
+			return nil
+		}
+		w.conn.Close()
+		w.conn = nil
+	}
+	return err
+}
+
+// Write implements io.Writer, sending p as an info message
+func (w *syslogWriter) Write(p []byte) (int, error) {
+	if err := w.send(syslogSeverity(slog.LevelInfo), p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// syslogHandler formats records with its embedded handler and sends them to w
+// at the severity of their level
+type syslogHandler struct {
+	slog.Handler
+	// buf is where the embedded handler writes each record, guarded by mu
+	buf *bytes.Buffer
+	mu  *sync.Mutex
+	w   *syslogWriter
+}
+
+// newSyslogHandler returns a handler sending records to w in our configured
+// format
+func newSyslogHandler(w *syslogWriter) slog.Handler {
+	buf := new(bytes.Buffer)
+	return syslogHandler{newHandler(buf), buf, new(sync.Mutex), w}
+}
+
+// Handle implements slog.Handler
+func (h syslogHandler) Handle(ctx context.Context, r slog.Record) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.buf.Reset()
+	if err := h.Handler.Handle(ctx, r); err != nil {
+		return err
+	}
+	return h.w.send(syslogSeverity(r.Level), h.buf.Bytes())
+}
+
+// WithAttrs implements slog.Handler
+func (h syslogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return syslogHandler{h.Handler.WithAttrs(attrs), h.buf, h.mu, h.w}
+}
+
+// WithGroup implements slog.Handler
+func (h syslogHandler) WithGroup(name string) slog.Handler {
+	return syslogHandler{h.Handler.WithGroup(name), h.buf, h.mu, h.w}
+}
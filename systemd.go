@@ -0,0 +1,76 @@
+package main
+
+import (
+	"log/slog"
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+// sdNotify sends state to systemd's notify socket, if we were started by
+// systemd with Type=notify
+func sdNotify(state string) {
+	path := os.Getenv("NOTIFY_SOCKET")
+	if path == "" {
+		return
+	}
+	if path[0] == '@' {
+		// an abstract socket
+		path = "\x00" + path[1:]
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		slog.Warn("error notifying systemd", "err", err)
+		return
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte(state)); err != nil {
+		slog.Warn("error notifying systemd", "err", err)
+	}
+}
+
+// watchdogInterval returns systemd's watchdog timeout for us, or 0 if it isn't
+// watching us
+func watchdogInterval() time.Duration {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond
+}
+
+// setupWatchdog warns if systemd's watchdog would restart us between polls,
+// or pings it on a timer if we only fetch when scraped, so have no poller to
+// ping it for us
+func setupWatchdog() {
+	d := watchdogInterval()
+	switch {
+	case d <= 0:
+	case onDemand():
+		go func() {
+			for range time.Tick(d / 2) {
+				sdNotify("WATCHDOG=1")
+			}
+		}()
+	case cfg.Source == "api" && d <= time.Duration(cfg.PollInterval):
+		slog.Warn("systemd watchdog timeout is shorter than the poll interval", "watchdog", d, "poll_interval", time.Duration(cfg.PollInterval))
+	}
+}
+
+// notifyReady tells systemd we're ready, the first time it's called
+func notifyReady() {
+	if !ready.Swap(true) {
+		sdNotify("READY=1")
+	}
+}
+
+// pingWatchdog tells systemd's watchdog we're still running
+func pingWatchdog() {
+	if watchdogInterval() > 0 {
+		sdNotify("WATCHDOG=1")
+	}
+}
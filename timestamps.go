@@ -0,0 +1,54 @@
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// observedFamilies returns the names of the metric families taken straight
+// from, or derived from, each station's latest observation
+func observedFamilies() map[string]bool {
+	names := make(map[string]bool)
+	for _, m := range []MetricsMap{metrics, derivedMetrics} {
+		for name := range m {
+			names[ns+"_"+ss+"_"+name] = true
+		}
+	}
+	return names
+}
+
+// timestampGatherer stamps the observation readings gathered from g with the
+// time of their station's latest observation, rather than leaving Prometheus
+// to use the scrape time
+type timestampGatherer struct {
+	g prometheus.Gatherer
+	// always stamps readings even when sample timestamps are disabled
+	always bool
+}
+
+// Gather implements prometheus.Gatherer
+func (t timestampGatherer) Gather() ([]*dto.MetricFamily, error) {
+	mfs, err := t.g.Gather()
+	if !t.always && !cfg.SampleTimestamps {
+		return mfs, err
+	}
+	observationMu.Lock()
+	times := make(map[string]int64, len(observationTimes))
+	for station, ts := range observationTimes {
+		times[station] = int64(ts * 1000)
+	}
+	observationMu.Unlock()
+	observed := observedFamilies()
+	for _, mf := range mfs {
+		if !observed[mf.GetName()] {
+			continue
+		}
+		for _, m := range mf.Metric {
+			station, _ := labelValue(m, "station_id")
+			if ms, ok := times[station]; ok {
+				m.TimestampMs = &ms
+			}
+		}
+	}
+	return mfs, err
+}
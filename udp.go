@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// udpPort is the port tempest hubs broadcast local observations on
+const udpPort = 50222
+
+// udpMessage is a single message broadcast by a hub on the local network
+type udpMessage struct {
+	SerialNumber string      `json:"serial_number"`
+	Type         string      `json:"type"`
+	HubSN        string      `json:"hub_sn"`
+	Obs          [][]float64 `json:"obs"`
+	Ob           []float64   `json:"ob"`
+	Evt          []float64   `json:"evt"`
+	Timestamp    float64     `json:"timestamp"`
+	Uptime       float64     `json:"uptime"`
+	Voltage      float64     `json:"voltage"`
+	RSSI         float64     `json:"rssi"`
+	HubRSSI      float64     `json:"hub_rssi"`
+	SensorStatus float64     `json:"sensor_status"`
+}
+
+// udpFieldCounts is the minimum number of values we expect in the data array
+// of each message type we know how to decode
+var udpFieldCounts = map[string]int{
+	"obs_st":        18,
+	"obs_air":       8,
+	"obs_sky":       14,
+	"rapid_wind":    3,
+	"evt_precip":    1,
+	"evt_strike":    3,
+	"device_status": 0,
+	"hub_status":    0,
+}
+
+// decodeUDP parses and validates a raw hub broadcast
+func decodeUDP(b []byte) (udpMessage, error) {
+	var m udpMessage
+	if err := json.Unmarshal(b, &m); err != nil {
+		return m, fmt.Errorf("error parsing udp message: %v", err)
+	}
+	want, ok := udpFieldCounts[m.Type]
+	if !ok {
+		return m, fmt.Errorf("unknown udp message type %q", m.Type)
+	}
+	var got int
+	switch m.Type {
+	case "obs_st", "obs_air", "obs_sky":
+		if len(m.Obs) == 0 {
+			return m, fmt.Errorf("%s message from %s has no observations", m.Type, m.SerialNumber)
+		}
+		got = len(m.Obs[0])
+	case "rapid_wind":
+		got = len(m.Ob)
+	case "evt_precip", "evt_strike":
+		got = len(m.Evt)
+	}
+	if got < want {
+		return m, fmt.Errorf("%s message from %s has %d fields, expected %d", m.Type, m.SerialNumber, got, want)
+	}
+	return m, nil
+}
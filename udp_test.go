@@ -0,0 +1,78 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDecodeUDP(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  string
+		typ  string
+		err  string
+	}{
+		{
+			name: "obs_st",
+			msg:  `{"serial_number":"ST-1","type":"obs_st","hub_sn":"HB-1","obs":[[1700000000,0.1,0.3,0.5,270,3,1012.5,20.1,55,50000,3,300,0,0,0,0,2.6,1]]}`,
+			typ:  "obs_st",
+		},
+		{
+			name: "rapid_wind",
+			msg:  `{"serial_number":"ST-1","type":"rapid_wind","hub_sn":"HB-1","ob":[1700000000,2.3,128]}`,
+			typ:  "rapid_wind",
+		},
+		{
+			name: "evt_strike",
+			msg:  `{"serial_number":"ST-1","type":"evt_strike","hub_sn":"HB-1","evt":[1700000000,27,3848]}`,
+			typ:  "evt_strike",
+		},
+		{
+			name: "hub_status without data",
+			msg:  `{"serial_number":"HB-1","type":"hub_status","uptime":1000,"rssi":-60}`,
+			typ:  "hub_status",
+		},
+		{
+			name: "invalid json",
+			msg:  `{"type":`,
+			err:  "error parsing udp message",
+		},
+		{
+			name: "unknown type",
+			msg:  `{"type":"obs_unknown"}`,
+			err:  `unknown udp message type "obs_unknown"`,
+		},
+		{
+			name: "observation without obs",
+			msg:  `{"serial_number":"ST-1","type":"obs_st","obs":[]}`,
+			err:  "obs_st message from ST-1 has no observations",
+		},
+		{
+			name: "short observation",
+			msg:  `{"serial_number":"AR-1","type":"obs_air","obs":[[1700000000,835,20]]}`,
+			err:  "obs_air message from AR-1 has 3 fields, expected 8",
+		},
+		{
+			name: "short event",
+			msg:  `{"serial_number":"ST-1","type":"evt_strike","evt":[1700000000]}`,
+			err:  "evt_strike message from ST-1 has 1 fields, expected 3",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := decodeUDP([]byte(tt.msg))
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("decodeUDP() error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("decodeUDP() error = %v", err)
+			}
+			if m.Type != tt.typ {
+				t.Errorf("decodeUDP() type = %q, want %q", m.Type, tt.typ)
+			}
+		})
+	}
+}
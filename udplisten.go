@@ -0,0 +1,231 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"net"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/nalbury/tempest-exporter/pkg/weatherflow"
+)
+
+var (
+	// hubObservations holds the latest observation built from each hub's
+	// broadcasts, merging readings from separate Air and Sky devices
+	hubObservations = make(map[string]observation)
+	// hubMetrics are the gauges exporting hub_status messages
+	hubMetrics = make(MetricsMap)
+	// rapidWindMetrics are the gauges exporting rapid_wind messages
+	rapidWindMetrics = make(MetricsMap)
+)
+
+// registerHubMetrics creates and registers the gauges for hub status messages
+func registerHubMetrics() {
+	help := map[string]string{
+		"uptime_seconds": "Seconds since the hub last restarted",
+		"rssi":           "Signal strength of the hub's wifi connection",
+	}
+	for name, h := range help {
+		hubMetrics[name] = prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: ns,
+				Subsystem: "hub",
+				Name:      name,
+				Help:      h,
+			},
+			[]string{"hub_sn"},
+		)
+		weatherRegistry.MustRegister(hubMetrics[name])
+	}
+}
+
+// registerRapidWind creates and registers the gauges for rapid_wind messages
+func registerRapidWind(labelNames []string) {
+	help := map[string]string{
+		"rapid_wind_speed":     "Instantaneous wind speed from the latest 3 second rapid wind message",
+		"rapid_wind_direction": "Instantaneous wind direction from the latest 3 second rapid wind message",
+		"rapid_wind_timestamp": "Timestamp of the latest rapid wind message",
+	}
+	for name, h := range help {
+		rapidWindMetrics[name] = prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: ns,
+				Subsystem: ss,
+				Name:      name,
+				Help:      h,
+			},
+			labelNames,
+		)
+		weatherRegistry.MustRegister(rapidWindMetrics[name])
+	}
+}
+
+// udpResponse returns a stand-in station response for a hub, used to label
+// the observations broadcast by its devices
+func udpResponse(hub string) response {
+	return response{StationObservations: weatherflow.StationObservations{
+		StationName: hub,
+		Timezone:    cfg.UDP.Timezone,
+	}}
+}
+
+// udpLabels returns the station labels for observations broadcast by a hub,
+// which is identified by its serial number as we don't know its station
+func udpLabels(hub string) prometheus.Labels {
+	r := udpResponse(hub)
+	labels := r.parseLabels()
+	labels["station_id"] = hub
+	return labels
+}
+
+// mergeUDPObservation adds the readings in a device observation message to a
+// hub's latest observation, applying our plausibility bounds
+func mergeUDPObservation(o observation, m udpMessage) observation {
+	ob := m.Obs[0]
+	if o.fields == nil {
+		o.fields = make(map[string]bool)
+	}
+	set := func(name string, field *float64, i int) {
+		*field = ob[i]
+		o.fields[name] = true
+	}
+	switch m.Type {
+	case "obs_st":
+		set("wind_lull", &o.WindLull, 1)
+		set("wind_avg", &o.WindAvg, 2)
+		set("wind_gust", &o.WindGust, 3)
+		set("wind_direction", &o.WindDirection, 4)
+		set("station_pressure", &o.StationPressure, 6)
+		set("air_temperature", &o.AirTemperature, 7)
+		set("relative_humidity", &o.RelativeHumidity, 8)
+		set("brightness", &o.Brightness, 9)
+		set("uv", &o.Uv, 10)
+		set("solar_radiation", &o.SolarRadiation, 11)
+		set("precip", &o.Precip, 12)
+		set("lightning_strike_count", &o.LightningStrikeCount, 15)
+		if ob[15] > 0 {
+			set("lightning_strike_last_distance", &o.LightningStrikeLastDistance, 14)
+		}
+	case "obs_air":
+		set("station_pressure", &o.StationPressure, 1)
+		set("air_temperature", &o.AirTemperature, 2)
+		set("relative_humidity", &o.RelativeHumidity, 3)
+		set("lightning_strike_count", &o.LightningStrikeCount, 4)
+		if ob[4] > 0 {
+			set("lightning_strike_last_distance", &o.LightningStrikeLastDistance, 5)
+		}
+		// rain is reported per interval by the sky, so leave it out rather
+		// than count the sky's last interval twice
+		delete(o.fields, "precip")
+	case "obs_sky":
+		set("brightness", &o.Brightness, 1)
+		set("uv", &o.Uv, 2)
+		set("precip", &o.Precip, 3)
+		set("wind_lull", &o.WindLull, 4)
+		set("wind_avg", &o.WindAvg, 5)
+		set("wind_gust", &o.WindGust, 6)
+		set("wind_direction", &o.WindDirection, 7)
+		set("solar_radiation", &o.SolarRadiation, 10)
+		// lightning is reported per interval by the air, so leave it out
+		// rather than count the air's last interval twice
+		delete(o.fields, "lightning_strike_count")
+	}
+	o.Timestamp = ob[0]
+	o.fields["timestamp"] = true
+	o.dropped = nil
+	o.applyBounds()
+	if o.fields["air_temperature"] && o.fields["relative_humidity"] {
+		o.DewPoint = dewPoint(o.AirTemperature, o.RelativeHumidity)
+		o.fields["dew_point"] = true
+	}
+	return o
+}
+
+// dewPoint returns the dew point in °C using the Magnus formula
+func dewPoint(t, rh float64) float64 {
+	const b, c = 17.625, 243.04
+	g := math.Log(rh/100) + b*t/(c+t)
+	return c * g / (b - g)
+}
+
+// setRapidWind updates our rapid wind metrics from a hub's rapid wind
+// message
+func setRapidWind(m udpMessage) {
+	metricsMu.Lock()
+	defer metricsMu.Unlock()
+	labels := udpLabels(m.HubSN)
+	rapidWindMetrics["rapid_wind_timestamp"].With(labels).Set(m.Ob[0])
+	rapidWindMetrics["rapid_wind_speed"].With(labels).Set(m.Ob[1])
+	rapidWindMetrics["rapid_wind_direction"].With(labels).Set(m.Ob[2])
+}
+
+// handleBroadcast updates our metrics from a hub broadcast, returning the
+// push of an observation to our Pushgateway to make once configMu is released
+func handleBroadcast(m udpMessage) func() {
+	switch m.Type {
+	case "obs_st", "obs_air", "obs_sky":
+		hub := m.HubSN
+		o := mergeUDPObservation(hubObservations[hub], m)
+		hubObservations[hub] = o
+		setObservation(hub, udpResponse(hub), o, udpLabels(hub))
+		beat()
+		return gatherPush()
+	case "rapid_wind":
+		setRapidWind(m)
+	case "evt_strike":
+		handleStrike(m)
+		broadcastStrike(m)
+	case "evt_precip":
+		handleRainStart(m)
+		broadcastRainStart(m)
+	case "hub_status":
+		hubMetrics["uptime_seconds"].WithLabelValues(m.SerialNumber).Set(m.Uptime)
+		hubMetrics["rssi"].WithLabelValues(m.SerialNumber).Set(m.RSSI)
+	case "device_status":
+		// we don't know device ids from broadcasts, only serial numbers
+		labels := []string{m.HubSN, "", m.SerialNumber, deviceType(m.SerialNumber)}
+		deviceMetrics["battery_voltage"].WithLabelValues(labels...).Set(m.Voltage)
+		deviceMetrics["rssi"].WithLabelValues(labels...).Set(m.RSSI)
+		deviceMetrics["hub_rssi"].WithLabelValues(labels...).Set(m.HubRSSI)
+		deviceMetrics["uptime_seconds"].WithLabelValues(labels...).Set(m.Uptime)
+		deviceMetrics["sensor_status"].WithLabelValues(labels...).Set(m.SensorStatus)
+	}
+	return func() {}
+}
+
+// listenBroadcasts exports the observations broadcast by hubs on the local
+// network
+func listenBroadcasts() {
+	defer reportPanic("udp")
+	conn, err := net.ListenPacket("udp4", cfg.UDP.Listen)
+	if err != nil {
+		err = fmt.Errorf("error listening on %s: %v", cfg.UDP.Listen, err)
+		reportFatal("udp", err)
+		fatal(err)
+	}
+	defer conn.Close()
+	polling.Store(true)
+	defer polling.Store(false)
+	slog.Info("listening for hub broadcasts", "address", cfg.UDP.Listen)
+	buf := make([]byte, 4096)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			slog.Error("error reading udp packet", "err", err)
+			time.Sleep(time.Second)
+			continue
+		}
+		m, err := decodeUDP(buf[:n])
+		if err != nil {
+			slog.Warn(err.Error())
+			continue
+		}
+		configMu.RLock()
+		push := handleBroadcast(m)
+		configMu.RUnlock()
+		push()
+	}
+}
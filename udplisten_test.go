@@ -0,0 +1,58 @@
+package main
+
+import "testing"
+
+func TestMergeUDPObservation(t *testing.T) {
+	air := udpMessage{Type: "obs_air", Obs: [][]float64{{1700000060, 1012.5, 20, 50, 2, 12, 3.4, 1}}}
+	sky := udpMessage{Type: "obs_sky", Obs: [][]float64{{1700000120, 50000, 3, 0.25, 1, 2, 3, 270, 3.4, 1, 300, 0, 2, 3}}}
+	tests := []struct {
+		name    string
+		msgs    []udpMessage
+		want    map[string]float64
+		missing []string
+	}{
+		{
+			name:    "air",
+			msgs:    []udpMessage{air},
+			want:    map[string]float64{"air_temperature": 20, "relative_humidity": 50, "lightning_strike_count": 2, "lightning_strike_last_distance": 12, "timestamp": 1700000060},
+			missing: []string{"precip", "wind_avg"},
+		},
+		{
+			name:    "sky",
+			msgs:    []udpMessage{sky},
+			want:    map[string]float64{"precip": 0.25, "wind_avg": 2, "uv": 3, "timestamp": 1700000120},
+			missing: []string{"lightning_strike_count", "air_temperature"},
+		},
+		{
+			name:    "air after sky leaves out the sky's rain",
+			msgs:    []udpMessage{sky, air},
+			want:    map[string]float64{"air_temperature": 20, "lightning_strike_count": 2, "wind_avg": 2, "timestamp": 1700000060},
+			missing: []string{"precip"},
+		},
+		{
+			name:    "sky after air leaves out the air's lightning",
+			msgs:    []udpMessage{air, sky},
+			want:    map[string]float64{"precip": 0.25, "air_temperature": 20, "wind_avg": 2, "timestamp": 1700000120},
+			missing: []string{"lightning_strike_count"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var o observation
+			for _, m := range tt.msgs {
+				o = mergeUDPObservation(o, m)
+			}
+			got := o.values()
+			for name, v := range tt.want {
+				if g, ok := got[name]; !ok || g != v {
+					t.Errorf("values()[%s] = %v, %v, want %v", name, g, ok, v)
+				}
+			}
+			for _, name := range tt.missing {
+				if _, ok := got[name]; ok {
+					t.Errorf("values() has %s, want it left out", name)
+				}
+			}
+		})
+	}
+}
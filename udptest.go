@@ -0,0 +1,177 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// udpDeviceStats tracks what we've seen from a single device during a udp test
+type udpDeviceStats struct {
+	hub     string
+	packets int
+	types   map[string]int
+}
+
+// udpTestResult is everything collected while listening for hub broadcasts
+type udpTestResult struct {
+	packets  int
+	decoded  int
+	errors   map[string]int
+	senders  map[string]bool
+	hubs     map[string]bool
+	devices  map[string]*udpDeviceStats
+	duration time.Duration
+}
+
+// listenUDP listens for hub broadcasts on addr for d and records what it sees
+func listenUDP(addr string, d time.Duration) (*udpTestResult, error) {
+	res := &udpTestResult{
+		errors:   make(map[string]int),
+		senders:  make(map[string]bool),
+		hubs:     make(map[string]bool),
+		devices:  make(map[string]*udpDeviceStats),
+		duration: d,
+	}
+	conn, err := net.ListenPacket("udp4", addr)
+	if err != nil {
+		return nil, fmt.Errorf("error listening on %s: %v", addr, err)
+	}
+	defer conn.Close()
+
+	deadline := time.Now().Add(d)
+	if err := conn.SetReadDeadline(deadline); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 4096)
+	for {
+		n, from, err := conn.ReadFrom(buf)
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				return res, nil
+			}
+			return res, fmt.Errorf("error reading udp packet: %v", err)
+		}
+		res.packets++
+		if h, _, err := net.SplitHostPort(from.String()); err == nil {
+			res.senders[h] = true
+		}
+		m, err := decodeUDP(buf[:n])
+		if err != nil {
+			res.errors[err.Error()]++
+			continue
+		}
+		res.decoded++
+		if m.Type == "hub_status" {
+			res.hubs[m.SerialNumber] = true
+			continue
+		}
+		if m.HubSN != "" {
+			res.hubs[m.HubSN] = true
+		}
+		dev, ok := res.devices[m.SerialNumber]
+		if !ok {
+			dev = &udpDeviceStats{hub: m.HubSN, types: make(map[string]int)}
+			res.devices[m.SerialNumber] = dev
+		}
+		dev.packets++
+		dev.types[m.Type]++
+	}
+}
+
+// print writes a human readable report of the udp test
+func (r *udpTestResult) print() {
+	mins := r.duration.Minutes()
+	fmt.Printf("listened for %s\n", r.duration)
+	fmt.Printf("packets received: %d (%.1f/min)\n", r.packets, float64(r.packets)/mins)
+	fmt.Printf("packets decoded:  %d\n", r.decoded)
+	fmt.Printf("decode failures:  %d\n", r.packets-r.decoded)
+	fmt.Printf("senders:          %s\n", joinKeys(r.senders))
+	fmt.Printf("hubs:             %s\n", joinKeys(r.hubs))
+
+	if len(r.devices) > 0 {
+		fmt.Println()
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "DEVICE\tHUB\tTYPE\tPACKETS\tRATE/MIN")
+		serials := make([]string, 0, len(r.devices))
+		for serial := range r.devices {
+			serials = append(serials, serial)
+		}
+		sort.Strings(serials)
+		for _, serial := range serials {
+			dev := r.devices[serial]
+			for _, t := range countKeys(dev.types) {
+				n := dev.types[t]
+				fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%.1f\n", serial, dev.hub, t, n, float64(n)/mins)
+			}
+		}
+		w.Flush()
+	}
+	if len(r.errors) > 0 {
+		fmt.Println()
+		fmt.Println("decode errors:")
+		for _, e := range countKeys(r.errors) {
+			fmt.Printf("  %dx %s\n", r.errors[e], e)
+		}
+	}
+}
+
+// runUDPTest implements the udp-test subcommand, returning the process exit code
+func runUDPTest(args []string) int {
+	fs := flag.NewFlagSet("udp-test", flag.ExitOnError)
+	d := fs.Duration("duration", time.Minute, "how long to listen for hub broadcasts")
+	addr := fs.String("listen", ":"+strconv.Itoa(udpPort), "udp address to listen on")
+	fs.Parse(args)
+	// rates are per minute of listening, so we need to listen for a while
+	if *d <= 0 {
+		fmt.Fprintf(os.Stderr, "invalid value %q for flag -duration: must be positive\n", d.String())
+		fs.Usage()
+		return 2
+	}
+
+	fmt.Printf("listening for tempest hub broadcasts on %s for %s...\n", *addr, *d)
+	res, err := listenUDP(*addr, *d)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	res.print()
+	if res.decoded == 0 {
+		fmt.Println()
+		fmt.Println("no hub broadcasts were decoded. check that the hub is on the same network/VLAN")
+		fmt.Printf("as this host and that udp broadcasts to port %d are not filtered.\n", udpPort)
+		return 1
+	}
+	return 0
+}
+
+// countKeys returns the keys of a map of counts in sorted order
+func countKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// joinKeys returns a sorted, comma separated list of the keys in m, or "none"
+func joinKeys(m map[string]bool) string {
+	if len(m) == 0 {
+		return "none"
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, ", ")
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// unitConversion converts a metric from our base (metric) units
+type unitConversion struct {
+	suffix  string
+	convert func(float64) float64
+}
+
+var (
+	fahrenheit      = unitConversion{"fahrenheit", cToF}
+	fahrenheitDelta = unitConversion{"fahrenheit", func(c float64) float64 { return c * 9 / 5 }}
+	mph             = unitConversion{"mph", func(ms float64) float64 { return ms * 2.236936 }}
+	inches          = unitConversion{"inches", func(mm float64) float64 { return mm / 25.4 }}
+	inchesPerHour   = unitConversion{"inches_per_hour", func(mm float64) float64 { return mm / 25.4 }}
+	inHg            = unitConversion{"inhg", func(mb float64) float64 { return mb * 0.0295300 }}
+	miles           = unitConversion{"miles", func(km float64) float64 { return km * 0.621371 }}
+)
+
+// imperialUnits are the conversions applied to each metric in imperial mode,
+// keyed by metric name without the namespace
+var imperialUnits = map[string]unitConversion{
+	"station_air_temperature":                    fahrenheit,
+	"station_air_temperature_avg":                fahrenheit,
+	"station_air_temperature_min":                fahrenheit,
+	"station_air_temperature_max":                fahrenheit,
+	"station_dew_point":                          fahrenheit,
+	"station_feels_like":                         fahrenheit,
+	"station_feels_like_local":                   fahrenheit,
+	"station_feels_like_divergence":              fahrenheitDelta,
+	"station_heat_index":                         fahrenheit,
+	"station_heat_index_local":                   fahrenheit,
+	"station_wind_chill":                         fahrenheit,
+	"station_wind_chill_local":                   fahrenheit,
+	"station_wet_bulb_temperature":               fahrenheit,
+	"station_wet_bulb_globe_temperature":         fahrenheit,
+	"station_delta_t":                            fahrenheitDelta,
+	"station_barometric_pressure":                inHg,
+	"station_sea_level_pressure":                 inHg,
+	"station_station_pressure":                   inHg,
+	"station_wind_avg":                           mph,
+	"station_wind_gust":                          mph,
+	"station_wind_lull":                          mph,
+	"station_rapid_wind_speed":                   mph,
+	"station_wind_gust_max":                      mph,
+	"station_precip":                             inches,
+	"station_precip_total":                       inches,
+	"station_precip_accum_last_1hr":              inches,
+	"station_precip_accum_local_day":             inches,
+	"station_precip_accum_local_yesterday":       inches,
+	"station_precip_accum_local_yesterday_final": inches,
+	"station_growing_degree_days":                fahrenheitDelta,
+	"station_heating_degree_days":                fahrenheitDelta,
+	"station_cooling_degree_days":                fahrenheitDelta,
+	"station_evapotranspiration_rate":            inchesPerHour,
+	"station_evapotranspiration_local_day":       inches,
+	"station_lightning_strike_last_distance":     miles,
+	"lightning_last_strike_distance":             miles,
+	"station_pair_air_temperature_delta":         fahrenheitDelta,
+	"station_pair_dew_point_delta":               fahrenheitDelta,
+	"station_pair_sea_level_pressure_delta":      {"inhg", inHg.convert},
+	"station_pair_station_pressure_delta":        {"inhg", inHg.convert},
+	"station_pair_wind_avg_delta":                mph,
+	"device_air_temperature":                     fahrenheit,
+	"device_station_pressure":                    inHg,
+	"device_wind_avg":                            mph,
+	"device_wind_gust":                           mph,
+	"device_wind_lull":                           mph,
+	"device_precip":                              inches,
+	"device_lightning_strike_avg_distance":       miles,
+	"forecast_air_temperature_min_tonight":       fahrenheit,
+	"forecast_max_gust_next_24h":                 mph,
+	"forecast_hourly_air_temperature":            fahrenheit,
+	"forecast_hourly_precip":                     inches,
+	"forecast_hourly_wind_avg":                   mph,
+	"forecast_hourly_wind_gust":                  mph,
+	"forecast_daily_air_temperature_high":        fahrenheit,
+	"forecast_daily_air_temperature_low":         fahrenheit,
+}
+
+// imperialRecords are the conversions applied to record values in imperial
+// mode, keyed by record. Records share a metric, so only values are converted.
+var imperialRecords = map[string]unitConversion{
+	"air_temperature_max": fahrenheit,
+	"air_temperature_min": fahrenheit,
+	"wind_gust_max":       mph,
+	"precip_day_max":      inches,
+}
+
+// imperialGatherer converts the metrics gathered from g to imperial units,
+// reflecting the unit in the metric name
+type imperialGatherer struct {
+	g prometheus.Gatherer
+}
+
+// Gather implements prometheus.Gatherer
+func (i imperialGatherer) Gather() ([]*dto.MetricFamily, error) {
+	mfs, err := i.g.Gather()
+	for _, mf := range mfs {
+		name := mf.GetName()
+		if name == ns+"_"+ss+"_record_value" {
+			for _, m := range mf.Metric {
+				for _, l := range m.Label {
+					if c, ok := imperialRecords[l.GetValue()]; ok && l.GetName() == "record" {
+						convertMetric(m, c)
+					}
+				}
+			}
+			continue
+		}
+		c, ok := imperialUnits[strings.TrimPrefix(name, ns+"_")]
+		if !ok {
+			continue
+		}
+		name += "_" + c.suffix
+		mf.Name = &name
+		for _, m := range mf.Metric {
+			convertMetric(m, c)
+		}
+	}
+	return mfs, err
+}
+
+// convertMetric converts the value of a gathered gauge
+func convertMetric(m *dto.Metric, c unitConversion) {
+	if m.Gauge == nil {
+		return
+	}
+	v := c.convert(m.Gauge.GetValue())
+	m.Gauge.Value = &v
+}
+
+// exportGatherer wraps g with the processing our weather metrics go through
+// before they leave the exporter, converting them to units. Observation
+// readings are stamped with their observation's time if stamp is set or
+// sample timestamps are enabled.
+func exportGatherer(g prometheus.Gatherer, units string, stamp bool) prometheus.Gatherer {
+	g = staticLabelsGatherer{timestampGatherer{staleGatherer{g}, stamp}}
+	if units == "imperial" {
+		g = imperialGatherer{g}
+	}
+	return relabelGatherer{namingGatherer{g}}
+}
+
+// imperialRecord returns a copy of a record with its readings converted to
+// imperial units, reflecting the unit in each reading's name like our metrics
+func imperialRecord(rec sinkRecord) sinkRecord {
+	values := make(map[string]float64, len(rec.Values))
+	for name, v := range rec.Values {
+		if c, ok := imperialUnits[ss+"_"+name]; ok {
+			name, v = name+"_"+c.suffix, c.convert(v)
+		}
+		values[name] = v
+	}
+	rec.Values = values
+	return rec
+}
+
+// requestUnits returns the units requested by the units query parameter, or
+// else our configured units
+func requestUnits(req *http.Request) (string, error) {
+	units := req.URL.Query().Get("units")
+	if units == "" {
+		units = cfg.Units
+	}
+	if units != "metric" && units != "imperial" {
+		return "", fmt.Errorf("unknown units %q, expected metric or imperial", units)
+	}
+	return units, nil
+}
+
+// unitsHandler serves metrics from g, in the units requested by the units
+// query parameter or else our configured units, with our relabel rules applied
+func unitsHandler(g prometheus.Gatherer) http.Handler {
+	opts := promhttp.HandlerOpts{EnableOpenMetrics: cfg.SampleTimestamps, DisableCompression: cfg.DisableCompression}
+	metric := promhttp.HandlerFor(exportGatherer(g, "metric", false), opts)
+	imperial := promhttp.HandlerFor(exportGatherer(g, "imperial", false), opts)
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		units, err := requestUnits(req)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if units == "imperial" {
+			imperial.ServeHTTP(w, req)
+			return
+		}
+		metric.ServeHTTP(w, req)
+	})
+}
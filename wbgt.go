@@ -0,0 +1,89 @@
+package main
+
+import (
+	"math"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// stefanBoltzmann is the Stefan-Boltzmann constant in W/m²/K⁴
+const stefanBoltzmann = 5.67e-8
+
+// wbgtMetric exports our wet bulb globe temperature estimate
+var wbgtMetric *prometheus.GaugeVec
+
+// stullWetBulb returns the psychrometric wet bulb temperature in celsius for
+// an air temperature in celsius and relative humidity in percent, by Stull's
+// (2011) empirical formula
+func stullWetBulb(tempC, rh float64) float64 {
+	return tempC*math.Atan(0.151977*math.Sqrt(rh+8.313659)) + math.Atan(tempC+rh) -
+		math.Atan(rh-1.676331) + 0.00391838*math.Pow(rh, 1.5)*math.Atan(0.023101*rh) - 4.686035
+}
+
+// globeTemperature returns the black globe temperature in celsius for an air
+// temperature in celsius, relative humidity in percent, wind speed in m/s,
+// solar radiation in W/m² and solar elevation in degrees at time t, by
+// Dimiceli et al. (2011), splitting the radiation into its direct and
+// diffuse parts as Liljegren et al. (2008) do
+func globeTemperature(tempC, rh, wind, solar, sunElevation float64, t time.Time) float64 {
+	var direct float64
+	cosZ := math.Sin(rad(sunElevation))
+	if cosZ > 0.01 && solar > 0 {
+		dr := 1 + 0.033*math.Cos(2*math.Pi*float64(t.YearDay())/365)
+		s := math.Min(solar/(1367*cosZ*dr), 0.85)
+		direct = math.Max(0, math.Min(0.9, math.Exp(3-1.34*s-1.65/s)))
+	}
+	ta := tempC + 273.15
+	ea := saturationVaporPressure(tempC) * rh / 100 * 10
+	emissivity := 0.575 * math.Pow(ea, 1.0/7)
+	b := emissivity * math.Pow(ta, 4)
+	if direct > 0 {
+		b += solar * direct / (4 * stefanBoltzmann * cosZ)
+	}
+	b += solar * 1.2 * (1 - direct) / stefanBoltzmann
+	// wind in meters per hour, kept above calm to avoid a runaway estimate
+	c := 0.315 * math.Pow(math.Max(wind, 0.5)*3600, 0.58) / 5.3865e-8
+	return (b+c*ta+7680000)/(c+256000) - 273.15
+}
+
+// wetBulbGlobeTemperature returns an estimate of the outdoor wet bulb globe
+// temperature in celsius, from the globe temperature and a natural wet bulb
+// temperature estimated from the psychrometric one by Hunter and Minyard (1999)
+func wetBulbGlobeTemperature(tempC, rh, wind, solar, sunElevation float64, t time.Time) float64 {
+	tw := stullWetBulb(tempC, rh)
+	tnwb := math.Max(tw, math.Min(tempC, tw+0.0021*solar-0.42*wind+1.93))
+	tg := globeTemperature(tempC, rh, wind, solar, sunElevation, t)
+	return 0.7*tnwb + 0.2*tg + 0.1*tempC
+}
+
+// registerWBGT creates and registers our wet bulb globe temperature gauge
+func registerWBGT(labelNames []string) {
+	wbgtMetric = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: ns,
+			Subsystem: ss,
+			Name:      "wet_bulb_globe_temperature",
+			Help:      "Outdoor wet bulb globe temperature estimated from air temperature, humidity, wind and solar radiation",
+		},
+		labelNames,
+	)
+	weatherRegistry.MustRegister(wbgtMetric)
+}
+
+// setWBGT updates a station's wet bulb globe temperature estimate from an
+// observation. It needs the station's coordinates, so stations heard over UDP
+// are skipped.
+func setWBGT(r response, o observation, labels prometheus.Labels) {
+	for _, name := range []string{"air_temperature", "relative_humidity", "wind_avg", "solar_radiation"} {
+		if !o.has(name) {
+			return
+		}
+	}
+	if r.Latitude == 0 && r.Longitude == 0 {
+		return
+	}
+	t := time.Unix(int64(o.Timestamp), 0)
+	sun := solarElevation(t, r.Latitude, r.Longitude)
+	wbgtMetric.With(labels).Set(wetBulbGlobeTemperature(o.AirTemperature, o.RelativeHumidity, o.WindAvg, o.SolarRadiation, sun, t))
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestStullWetBulb(t *testing.T) {
+	tests := []struct {
+		tempC, rh, want float64
+	}{
+		// Stull (2011)'s worked example
+		{tempC: 20, rh: 50, want: 13.7},
+		// saturated air is at its wet bulb temperature
+		{tempC: 25, rh: 99, want: 24.9},
+	}
+	for _, tt := range tests {
+		if got := stullWetBulb(tt.tempC, tt.rh); math.Abs(got-tt.want) > 0.3 {
+			t.Errorf("stullWetBulb(%v, %v) = %v, want %v", tt.tempC, tt.rh, got, tt.want)
+		}
+	}
+}
+
+func TestWetBulbGlobeTemperature(t *testing.T) {
+	summer := time.Date(2026, 7, 1, 13, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name                                 string
+		tempC, rh, wind, solar, sunElevation float64
+		want                                 float64
+	}{
+		{name: "full sun", tempC: 32, rh: 50, wind: 2, solar: 900, sunElevation: 70, want: 31.5},
+		{name: "calm full sun", tempC: 32, rh: 50, wind: 0, solar: 900, sunElevation: 70, want: 35.8},
+		{name: "night", tempC: 25, rh: 50, wind: 2, sunElevation: -10, want: 22.2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := wetBulbGlobeTemperature(tt.tempC, tt.rh, tt.wind, tt.solar, tt.sunElevation, summer)
+			if math.Abs(got-tt.want) > 0.1 {
+				t.Errorf("wetBulbGlobeTemperature() = %v, want %v", got, tt.want)
+			}
+			if tw := stullWetBulb(tt.tempC, tt.rh); got < tw {
+				t.Errorf("wetBulbGlobeTemperature() = %v, want at least the wet bulb temperature %v", got, tw)
+			}
+		})
+	}
+}
+
+func TestGlobeTemperature(t *testing.T) {
+	summer := time.Date(2026, 7, 1, 13, 0, 0, 0, time.UTC)
+	shade := globeTemperature(32, 50, 2, 0, 70, summer)
+	sun := globeTemperature(32, 50, 2, 900, 70, summer)
+	windy := globeTemperature(32, 50, 6, 900, 70, summer)
+	if sun <= shade {
+		t.Errorf("globeTemperature() in sun = %v, want above %v in shade", sun, shade)
+	}
+	if windy >= sun {
+		t.Errorf("globeTemperature() in wind = %v, want below %v in light wind", windy, sun)
+	}
+	// calm air is treated as a light breeze
+	if calm, breeze := globeTemperature(32, 50, 0, 900, 70, summer), globeTemperature(32, 50, 0.5, 900, 70, summer); calm != breeze {
+		t.Errorf("globeTemperature() in calm air = %v, want %v as in a 0.5 m/s breeze", calm, breeze)
+	}
+}
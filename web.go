@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/handlers"
+	"github.com/prometheus/exporter-toolkit/web"
+)
+
+var (
+	// server is our HTTP server
+	server = &http.Server{}
+	// reservedPaths are the fixed paths our other handlers are served on
+	reservedPaths = map[string]bool{"/internal/metrics": true, "/probe": true, "/sd": true, "/metar": true, "/events": true, "/ws": true, "/export.csv": true, "/healthz": true, "/readyz": true, "/-/reload": true, "/-/quit": true}
+)
+
+// serve serves our handlers on our listen address, behind basic auth if it's
+// configured, with TLS and any other settings from our web config file
+func serve() error {
+	if cfg.WebConfigFile != "" {
+		if err := web.Validate(cfg.WebConfigFile); err != nil {
+			return fmt.Errorf("invalid web config file: %v", err)
+		}
+	}
+	systemdSocket := false
+	flags := &web.FlagConfig{
+		WebListenAddresses: &cfg.ListenAddresses,
+		WebSystemdSocket:   &systemdSocket,
+		WebConfigFile:      &cfg.WebConfigFile,
+	}
+	handler := hidePprof(http.DefaultServeMux)
+	if cfg.BasicAuth.Username != "" {
+		handler = basicAuth(handler)
+	}
+	server.Handler = holdConfig(handler)
+	err := web.ListenAndServe(server, flags, kitLogger())
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// stopServer stops our HTTP server, letting requests in flight finish
+func stopServer() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	server.Shutdown(ctx)
+}
+
+// compress gzips responses from h for clients that accept it, unless
+// compression is disabled. Metrics handlers compress their own responses.
+func compress(h http.Handler) http.Handler {
+	if cfg.DisableCompression {
+		return h
+	}
+	return handlers.CompressHandler(h)
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/websocket"
+)
+
+// streamFilter limits the records streamed to a WebSocket client to some
+// stations and readings. Empty lists let everything through.
+type streamFilter struct {
+	Stations []string `json:"stations"`
+	Metrics  []string `json:"metrics"`
+}
+
+// queryList returns the values of a query parameter, which may be repeated
+// or comma separated
+func queryList(values []string) []string {
+	var list []string
+	for _, v := range values {
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+	}
+	return list
+}
+
+// apply returns the part of a record that passes the filter, and whether
+// any of it does. Observations keep only the filtered readings, and are
+// dropped if they have none of them.
+func (f streamFilter) apply(rec sinkRecord) (sinkRecord, bool) {
+	if len(f.Stations) > 0 && !contains(f.Stations, rec.StationID) {
+		return rec, false
+	}
+	if len(f.Metrics) == 0 || rec.Kind != "observation" {
+		return rec, true
+	}
+	values := make(map[string]float64)
+	for _, name := range f.Metrics {
+		if v, ok := rec.Values[name]; ok {
+			values[name] = v
+		}
+	}
+	rec.Values = values
+	return rec, len(values) > 0
+}
+
+// wsHandler pushes each new observation and event to WebSocket clients as
+// JSON, starting with the latest observations. Clients filter what they're
+// sent with the station and metric query parameters, and can replace their
+// filter at any time by sending one as JSON.
+var wsHandler = websocket.Server{
+	// accept pages from any origin, as /events and our JSON API do
+	Handshake: func(*websocket.Config, *http.Request) error { return nil },
+	Handler: func(ws *websocket.Conn) {
+		defer ws.Close()
+		q := ws.Request().URL.Query()
+		var mu sync.Mutex
+		filter := streamFilter{Stations: queryList(q["station"]), Metrics: queryList(q["metric"])}
+		ch, recs := subscribeLatest()
+		defer unsubscribe(ch)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for {
+				var f streamFilter
+				if err := websocket.JSON.Receive(ws, &f); err != nil {
+					return
+				}
+				mu.Lock()
+				filter = f
+				mu.Unlock()
+			}
+		}()
+		send := func(rec sinkRecord) error {
+			mu.Lock()
+			rec, ok := filter.apply(rec)
+			mu.Unlock()
+			if !ok {
+				return nil
+			}
+			return websocket.JSON.Send(ws, rec)
+		}
+		for _, rec := range recs {
+			if err := send(rec); err != nil {
+				return
+			}
+		}
+		for {
+			select {
+			case rec, ok := <-ch:
+				if !ok {
+					return
+				}
+				if err := send(rec); err != nil {
+					return
+				}
+			case <-done:
+				return
+			}
+		}
+	},
+}
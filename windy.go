@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// windyURL is the Windy PWS API endpoint, which takes the API key as its last
+// path element
+const windyURL = "https://stations.windy.com/pws/update/"
+
+// windyParams are the query parameters Windy takes each reading as, keyed by
+// metric name. Windy takes our units as they are.
+var windyParams = map[string]string{
+	"air_temperature":       "temp",
+	"dew_point":             "dewpoint",
+	"relative_humidity":     "rh",
+	"wind_avg":              "wind",
+	"wind_gust":             "gust",
+	"wind_direction":        "winddir",
+	"sea_level_pressure":    "mbar",
+	"precip_accum_last_1hr": "precip",
+	"solar_radiation":       "solarradiation",
+	"uv":                    "uv",
+}
+
+// windySink uploads observations to Windy
+type windySink struct {
+	c      windyConfig
+	client *http.Client
+}
+
+// newWindySink creates a Windy sink
+func newWindySink(c windyConfig) *windySink {
+	return &windySink{c: c, client: &http.Client{Timeout: uploadTimeout}}
+}
+
+// name implements sink
+func (s *windySink) name() string {
+	return "windy"
+}
+
+// send implements sink
+func (s *windySink) send(rec sinkRecord) error {
+	q := url.Values{
+		"station": {strconv.Itoa(s.c.StationIndex)},
+		"ts":      {strconv.FormatInt(rec.Time.Unix(), 10)},
+	}
+	for name, p := range windyParams {
+		if v, ok := rec.Values[name]; ok {
+			q.Set(p, strconv.FormatFloat(v, 'f', 2, 64))
+		}
+	}
+	if err := getUpload(s.client, windyURL+url.PathEscape(s.c.APIKey)+"?"+q.Encode()); err != nil {
+		return fmt.Errorf("error uploading to windy: %v", err)
+	}
+	return nil
+}